@@ -0,0 +1,157 @@
+// Command benchcmp compares go test -bench results of two runs, reports which changes are
+// statistically significant and exits with status 1 if ns/op, B/op or allocs/op regressed beyond
+// a threshold. Run the benchmarks several times (-count) so that there is something to test:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./pkg/... > old.txt
+//	go test -run '^$' -bench . -benchmem -count 10 ./pkg/... > new.txt
+//	go run ./cmd/benchcmp old.txt new.txt
+//	go run ./cmd/benchcmp -threshold ns/op=10,allocs/op=0 old.txt new.txt
+//
+// With -history a single run is compared with the latest run in the history file and then appended
+// to it, and -trend shows how the benchmarks changed across the history:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./pkg/... | go run ./cmd/benchcmp -history bench-history.jsonl -label "$(git rev-parse --short HEAD)" -
+//	go run ./cmd/benchcmp -history bench-history.jsonl -trend -bench Search
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/benchcmp"
+)
+
+func main() {
+	history := flag.String("history", "", "history file; a single run is compared with its latest entry and appended to it")
+	label := flag.String("label", "", "label of the new run in the history, e.g. a git revision")
+	save := flag.Bool("save", true, "append the new run to the -history file")
+	trend := flag.Bool("trend", false, "show the trend of the runs in the -history file instead of comparing")
+	alpha := flag.Float64("alpha", 0.05, "p-value below which a change is significant")
+	threshold := flag.String("threshold", "5", "increase in percent beyond which a significant change is a regression, for all units or as unit=percent pairs")
+	bench := flag.String("bench", "", "only benchmarks matching this regular expression")
+	unit := flag.String("unit", "ns/op", "unit shown by -trend")
+	format := flag.String("format", "text", "comparison format: text or json")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: benchcmp [flags] old.txt new.txt\n       benchcmp [flags] -history file new.txt\n       benchcmp -history file -trend\n\nA file name of - reads standard input.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown -format %q, must be text or json", *format)
+	}
+	thresholds, err := benchcmp.ParseThresholds(*threshold)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var filter *regexp.Regexp
+	if *bench != "" {
+		if filter, err = regexp.Compile(*bench); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *trend {
+		if *history == "" {
+			log.Fatal("-trend needs -history")
+		}
+		runs, err := benchcmp.ReadHistory(*history)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := benchcmp.WriteTrend(os.Stdout, runs, *unit, filter); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var old, new *benchcmp.Run
+	switch {
+	case flag.NArg() == 2:
+		old, new = parseFile(flag.Arg(0)), parseFile(flag.Arg(1))
+	case flag.NArg() == 1 && *history != "":
+		new = parseFile(flag.Arg(0))
+		runs, err := benchcmp.ReadHistory(*history)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(runs) > 0 {
+			old = runs[len(runs)-1]
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if len(new.Benchmarks) == 0 {
+		log.Fatal("no benchmark results in the new run")
+	}
+	new.Time, new.Label = time.Now(), *label
+
+	var comparisons []benchcmp.Comparison
+	if old != nil {
+		comparisons = benchcmp.Compare(only(old, filter), only(new, filter), *alpha, thresholds)
+	} else {
+		log.Printf("%s is empty, nothing to compare with", *history)
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(comparisons)
+	} else {
+		err = benchcmp.WriteTable(os.Stdout, comparisons)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The run is kept even if it regressed, so that the history shows it
+	if *history != "" && *save {
+		if err := benchcmp.AppendHistory(*history, new); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if regressions := benchcmp.Regressions(comparisons); len(regressions) > 0 {
+		for _, c := range regressions {
+			log.Printf("regression: %s %s %+.2f%% (p=%.3f)", c.Key(), c.Unit, c.Delta, c.P)
+		}
+		os.Exit(1)
+	}
+}
+
+func parseFile(path string) *benchcmp.Run {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	run, err := benchcmp.Parse(r)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return run
+}
+
+// only returns run with the benchmarks matching filter, or run itself if filter is nil.
+func only(run *benchcmp.Run, filter *regexp.Regexp) *benchcmp.Run {
+	if filter == nil {
+		return run
+	}
+	r := *run
+	r.Benchmarks = nil
+	for _, b := range run.Benchmarks {
+		if filter.MatchString(b.Key()) {
+			r.Benchmarks = append(r.Benchmarks, b)
+		}
+	}
+	return &r
+}
@@ -0,0 +1,123 @@
+// Command loadgen sends /api/search load at a constant rate (open model) or from a fixed number
+// of workers (closed model) and reports latency percentiles corrected for coordinated omission:
+//
+//	go run ./cmd/loadgen -mode open -rate 200 -duration 30s
+//	go run ./cmd/loadgen -mode closed -c 50 -duration 10s -terms terms.txt
+//	go run ./cmd/loadgen -rate 500 -label worker-pool -format csv -out results.csv
+//
+// Without -terms the queries are words of the catalog's product names, picked with a Zipf
+// distribution so that a few terms are hot and most are rare, like real search traffic.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/loadgen"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+func main() {
+	target := flag.String("url", "http://localhost:8080/api/search?itemCount=10", "URL to load; the term query parameter is set on every request")
+	mode := flag.String("mode", loadgen.ModeOpen, "load model: open (constant -rate) or closed (-c workers back to back)")
+	rate := flag.Float64("rate", 100, "requests per second of the open model")
+	concurrency := flag.Int("c", 50, "workers of the closed model, requests in flight at most in the open model")
+	duration := flag.Duration("duration", 10*time.Second, "length of the run (unlimited if 0, then -n must be set)")
+	requests := flag.Int("n", 0, "number of requests to send (unlimited if 0)")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of a request")
+	expectedInterval := flag.Duration("expected-interval", 0, "closed model: time between a worker's requests assumed by the coordinated omission correction (mean service time if 0)")
+	termsFile := flag.String("terms", "", "file of search terms, one per line, most popular first (catalog words if empty)")
+	zipf := flag.Float64("zipf", 1.1, "Zipf exponent of the term popularity, greater than 1, or 0 to pick terms uniformly")
+	seed := flag.Int64("seed", 0, "seed of the term picker (0 picks a time-based seed)")
+	label := flag.String("label", "", "label of the run in JSON and CSV results, e.g. the strategy under test")
+	format := flag.String("format", "text", "result format: text, json or csv")
+	out := flag.String("out", "", "write the result to this file instead of stdout; CSV rows are appended")
+	flag.Parse()
+
+	if *format != "text" && *format != "json" && *format != "csv" {
+		log.Fatalf("unknown -format %q, must be text, json or csv", *format)
+	}
+	u, err := url.Parse(*target)
+	if err != nil || u.Host == "" {
+		log.Fatalf("invalid -url %q", *target)
+	}
+	terms := loadgen.CatalogTerms()
+	if *termsFile != "" {
+		if terms, err = loadgen.LoadTerms(*termsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	picker, err := loadgen.NewPicker(terms, *zipf, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	query := u.Query()
+	newRequest := func(int) (*http.Request, error) {
+		query.Set("term", picker.Next())
+		u.RawQuery = query.Encode()
+		return http.NewRequest(http.MethodGet, u.String(), nil)
+	}
+
+	// Ctrl-C ends the run early but still reports it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Loading %s (%s model, %d terms, -seed=%d)", *target, *mode, len(terms), *seed)
+	result, err := loadgen.Run(ctx, loadgen.Options{
+		Mode:             *mode,
+		Rate:             *rate,
+		Concurrency:      *concurrency,
+		Duration:         *duration,
+		Requests:         *requests,
+		ExpectedInterval: *expectedInterval,
+		Client:           util.NewHTTPClient(*concurrency, *timeout),
+	}, newRequest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	result.Label = *label
+	result.URL = *target
+
+	if err := write(result, *format, *out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// write writes result to out or stdout. CSV rows are appended to out, with a header if it is new.
+func write(result *loadgen.Result, format, out string) error {
+	w, header := os.Stdout, true
+	if out != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if format == "csv" {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(out, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			header = false
+		}
+		w = f
+	}
+
+	switch format {
+	case "json":
+		return loadgen.WriteJSON(w, result)
+	case "csv":
+		return loadgen.WriteCSV(w, result, header)
+	default:
+		return loadgen.WriteText(w, result)
+	}
+}
@@ -7,52 +7,246 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime/trace"
 	"syscall"
 	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/debugserver"
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+	"github.com/idagdelen/go-bottlenecks/internal/metrics"
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/profiler"
+	"github.com/idagdelen/go-bottlenecks/internal/replay"
+	"github.com/idagdelen/go-bottlenecks/internal/runtimetrace"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
 	"github.com/idagdelen/go-bottlenecks/pkg/api"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	/*
-		Create a trace file
+		Structured logging; the log package is routed through it too
 	*/
-	f, err := os.Create("macro-trace.out")
+	logLevel, _ := logging.ParseLevel(cfg.Logging.Level) // Validated by config.Load
+	logger, err := logging.New(os.Stderr, logging.Options{
+		Level:            logLevel,
+		Format:           cfg.Logging.Format,
+		SampleInitial:    cfg.Logging.SampleInitial,
+		SampleThereafter: cfg.Logging.SampleThereafter,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := trace.Start(f); err != nil {
+	slog.SetDefault(logger)
+
+	/*
+		Configure the simulated services, log the seed so the run can be replayed
+	*/
+	if cfg.Simulation.Seed == 0 {
+		cfg.Simulation.Seed = time.Now().UnixNano()
+	}
+	util.SetSeed(cfg.Simulation.Seed)
+	sim := api.SimulationConfig{
+		Seed:                cfg.Simulation.Seed,
+		Stateful:            cfg.Simulation.Stateful,
+		StockShards:         cfg.Simulation.StockShards,
+		ReservationTTL:      time.Duration(cfg.Simulation.ReservationTTL),
+		ErrorRate:           cfg.Simulation.ErrorRate,
+		AdSlotInterval:      cfg.Ads.SlotInterval,
+		ProductServiceURL:   cfg.Services.ProductURL,
+		StockServiceURL:     cfg.Services.StockURL,
+		ServiceMaxIdleConns: cfg.Services.MaxIdleConns,
+		ServiceTimeout:      time.Duration(cfg.Services.Timeout),
+	}
+	if cfg.Simulation.LatencyFile != "" {
+		models, err := util.LoadLatencyConfig(cfg.Simulation.LatencyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sim.SetLatencies(models); err != nil {
+			log.Fatal(err)
+		}
+	}
+	latencySpecs := map[string]string{
+		"product": cfg.Simulation.ProductLatency,
+		"stock":   cfg.Simulation.StockLatency,
+		"ads":     cfg.Simulation.AdsLatency,
+	}
+	for name, spec := range latencySpecs {
+		if spec == "" {
+			continue
+		}
+		model, err := util.ParseLatencyModel(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sim.SetLatencies(map[string]util.LatencyModel{name: model}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	services, err := api.NewServices(sim)
+	if err != nil {
 		log.Fatal(err)
 	}
+	apiMetrics := api.NewMetrics(metrics.NewRegistry())
+	services.Instrument(apiMetrics)
+	log.Printf("Simulated services seeded with -seed=%d", cfg.Simulation.Seed)
 
 	/*
-		Start pprof server
+		Runtime tracing is off unless started here, via /debug/trace/start or by the flight recorder
 	*/
-	go func() {
-		log.Println("pprof started on :6060")
-		log.Println(http.ListenAndServe("localhost:6060", nil))
-	}()
+	traces := runtimetrace.NewController(cfg.Debug.TraceDir, cfg.Debug.TraceMaxFiles)
+	if cfg.Debug.FlightRecorder > 0 {
+		if err := traces.StartFlightRecorder(time.Duration(cfg.Debug.FlightRecorder), 0); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Flight recorder keeps the last %s of runtime trace", cfg.Debug.FlightRecorder)
+	}
+	if cfg.Debug.TraceOnStart > 0 {
+		capture, err := traces.Start(time.Duration(cfg.Debug.TraceOnStart))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Tracing into %s until %s", capture.File, capture.Until.Format(time.TimeOnly))
+	}
+
+	/*
+		Continuous profiling into rotating snapshot directories, listed at /debug/profiles
+	*/
+	var prof *profiler.Profiler
+	if cfg.Debug.ProfileInterval > 0 {
+		labels := profiler.BuildLabels()
+		labels["config"] = cfg.Hash()
+		labels["backend"] = cfg.Search.Backend
+		labels["strategy"] = cfg.Search.Strategy
+		prof, err = profiler.Start(profiler.Options{
+			Dir:                  cfg.Debug.ProfileDir,
+			Interval:             time.Duration(cfg.Debug.ProfileInterval),
+			CPUDuration:          time.Duration(cfg.Debug.ProfileCPUDuration),
+			MaxSnapshots:         cfg.Debug.ProfileMaxSnapshots,
+			Labels:               labels,
+			MutexProfileFraction: cfg.Debug.ProfileMutexFraction,
+			BlockProfileRate:     int(time.Duration(cfg.Debug.ProfileBlockRate)),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Profiling into %s every %s", cfg.Debug.ProfileDir, cfg.Debug.ProfileInterval)
+	}
+
+	/*
+		Goroutine leak watchdog, logging the largest goroutine groups when the count keeps rising
+	*/
+	var watchdog *leakcheck.Watchdog
+	if cfg.Debug.WatchdogInterval > 0 {
+		watchdog = leakcheck.StartWatchdog(leakcheck.WatchdogOptions{
+			Interval: time.Duration(cfg.Debug.WatchdogInterval),
+			Window:   cfg.Debug.WatchdogWindow,
+			Min:      cfg.Debug.WatchdogMin,
+			OnAlert: func(a leakcheck.Alert) {
+				logger.Warn("goroutine count keeps rising, possible leak",
+					"goroutines", a.Counts[len(a.Counts)-1], "counts", a.Counts, "groups", a.Groups)
+			},
+		})
+	}
+
+	/*
+		Request spans go to an OTLP collector or a file if either is configured
+	*/
+	spanExporter, err := tracing.OpenExporter(cfg.Tracing.OTLPEndpoint, cfg.Tracing.File, cfg.Tracing.ServiceName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tracer *tracing.Tracer
+	if spanExporter != nil {
+		tracer = tracing.NewTracer(spanExporter, tracing.Options{SampleRatio: cfg.Tracing.SampleRatio})
+		log.Printf("Exporting request spans (sample ratio %g)", cfg.Tracing.SampleRatio)
+	}
+
+	/*
+		Request recording for cmd/replay
+	*/
+	var recorder *replay.Recorder
+	if cfg.Server.RecordFile != "" {
+		if recorder, err = replay.Create(cfg.Server.RecordFile, "/api/"); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Recording /api requests into %s", cfg.Server.RecordFile)
+	}
+
+	/*
+		Ad event log with optional file spillover
+	*/
+	var adEventsSpill *os.File
+	var adEvents *ads.EventLog
+	if cfg.Ads.EventsFile != "" {
+		adEventsSpill, err = os.OpenFile(cfg.Ads.EventsFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		adEvents = ads.NewEventLog(cfg.Ads.EventsCapacity, adEventsSpill)
+	} else {
+		adEvents = ads.NewEventLog(cfg.Ads.EventsCapacity, nil)
+	}
+
+	var rates *money.RateTable
+	if cfg.Pricing.ExchangeRatesFile != "" {
+		rates, err = money.LoadRates(cfg.Pricing.ExchangeRatesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	/*
+		Wire the API server
+	*/
+	searcher, err := api.SearcherByName(cfg.Search.Backend, cfg.Search.QdrantURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enricher, err := api.EnricherByName(cfg.Search.Strategy, cfg.Search.EnrichWorkers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiServer := api.NewServer(services, searcher, enricher, api.ServerOptions{
+		Config:   cfg,
+		AdEvents: adEvents,
+		Rates:    rates,
+		Traces:   traces,
+		Metrics:  apiMetrics,
+		Logger:   logger,
+		Tracer:   tracer,
+		Recorder: recorder,
+		Backend:  cfg.Search.Backend,
+		Strategy: cfg.Search.Strategy,
+	})
 
 	log.Println("Starting Go-Bottlenecks demonstration")
 	fmt.Println("Welcome to Go performance bottlenecks demonstration")
 
-	r := chi.NewRouter()
-
 	/*
-		Middleware
+		API routes behind the middleware (request IDs, metrics, spans, logs, recording, recovery, timeout)
 	*/
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r := apiServer.Router()
 
 	/*
 		Routes
@@ -69,28 +263,39 @@ func main() {
 	))
 
 	/*
-		Serve swagger.json directly from pkg/docs
+		Serve swagger.json directly from pkg/docs (or server.swaggerFile)
 	*/
 	r.Get("/swagger.json", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "pkg/docs/swagger.json")
-	})
-
-	/*
-		API routes
-	*/
-	r.Route("/api", func(r chi.Router) {
-		r.Get("/health", api.HandleHealthCheck)
-		r.Get("/search", api.HandleSearch)
+		http.ServeFile(w, r, cfg.Server.SwaggerFile)
 	})
 
 	/*
 		Create a new HTTP server
 	*/
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Server.Addr,
 		Handler: r,
 	}
 
+	/*
+		Debug server: pprof, expvar, runtime metrics and trace controls on their own address
+	*/
+	var debugServer *http.Server
+	if cfg.Debug.Addr != "" {
+		debugServer = debugserver.New(cfg.Debug.Addr, debugserver.Options{
+			Username: cfg.Debug.Username,
+			Password: cfg.Debug.Password,
+			Routes:   apiServer.DebugRoutes,
+			Admin:    apiServer.AdminRoutes,
+		})
+		go func() {
+			log.Printf("Debug server starting on %s (pprof at /debug/pprof/)", cfg.Debug.Addr)
+			if err := debugServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Printf("Debug server: %v", err)
+			}
+		}()
+	}
+
 	/*
 		Graceful shutdown
 	*/
@@ -101,19 +306,38 @@ func main() {
 		<-c
 		log.Println("Shutting down server...")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
 		defer cancel()
 		server.Shutdown(ctx)
+		if debugServer != nil {
+			// Running CPU profiles and traces do not end early, so cut them off after the grace period
+			if err := debugServer.Shutdown(ctx); err != nil {
+				debugServer.Close()
+			}
+		}
 
-		trace.Stop()
-		f.Close()
+		traces.Close()
+		prof.Stop()
+		watchdog.Stop()
+		if err := tracer.Shutdown(ctx); err != nil {
+			log.Printf("Exporting the last spans: %v", err)
+		}
+		if adEventsSpill != nil {
+			adEventsSpill.Close()
+		}
+		if err := recorder.Close(); err != nil {
+			log.Printf("Recording requests: %v", err)
+		}
+		if err := services.Close(); err != nil {
+			log.Printf("Closing service connections: %v", err)
+		}
 		close(idleConnsClosed)
 	}()
 
 	/*
 		Start the server
 	*/
-	log.Println("Server starting on http://localhost:8080")
+	log.Printf("Server starting on %s", cfg.Server.Addr)
 	if err := server.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("ListenAndServe(): %v", err)
 	}
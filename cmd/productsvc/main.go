@@ -0,0 +1,108 @@
+// Command productsvc serves the simulated product service over HTTP and gRPC, so that the search API
+// can be load tested with real network overhead:
+//
+//	go run ./cmd/productsvc -addr :8081 -stateful
+//	go run ./cmd/main.go -product-service-url http://localhost:8081
+//	go run ./cmd/main.go -product-service-url grpc://localhost:9081
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/catalog"
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "HTTP address to listen on")
+	grpcAddr := flag.String("grpc-addr", ":9081", "gRPC address to listen on (disabled if empty)")
+	seed := flag.Int64("seed", 0, "seed for the simulated service (0 picks a time-based seed)")
+	latency := flag.String("latency", "uniform:0ms,40ms", "latency model of the service, e.g. lognormal:15ms,0.6")
+	stateful := flag.Bool("stateful", false, "serve products from an in-memory store seeded from the search catalog")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "export request spans to this OTLP/HTTP collector, e.g. http://localhost:4318 (disabled if empty)")
+	spansFile := flag.String("spans-file", "", "write request spans as JSON lines to this file, or stdout (disabled if empty)")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	model, err := util.ParseLatencyModel(*latency)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var backend product.ProductService
+	if *stateful {
+		products, _ := catalog.Generate(*seed)
+		backend = product.NewInMemoryProductService(products)
+	}
+	svc := product.NewSimulatedProductServiceWithBackend(util.NewRand(util.DeriveSeed(*seed, "product")), model, backend)
+
+	spanExporter, err := tracing.OpenExporter(*otlpEndpoint, *spansFile, "productsvc")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tracer *tracing.Tracer
+	if spanExporter != nil {
+		tracer = tracing.NewTracer(spanExporter, tracing.Options{SampleRatio: 1})
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: tracer.Middleware(product.NewHTTPHandler(svc)),
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcServer = grpc.NewServer()
+		pb.RegisterProductServiceServer(grpcServer, product.NewGRPCServer(svc))
+		go func() {
+			log.Printf("Product gRPC service starting on %s", *grpcAddr)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("grpc Serve(): %v", err)
+			}
+		}()
+	}
+
+	/*
+		Graceful shutdown
+	*/
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		log.Println("Shutting down product service...")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		tracer.Shutdown(ctx)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(idleConnsClosed)
+	}()
+
+	log.Printf("Product HTTP service starting on %s (-seed=%d, latency %s)", *addr, *seed, model)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("ListenAndServe(): %v", err)
+	}
+	<-idleConnsClosed
+}
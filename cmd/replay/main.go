@@ -0,0 +1,75 @@
+// Command replay re-issues requests recorded by the server's -record-requests option against a
+// server and compares the status codes and latency distribution with the recording:
+//
+//	go run ./cmd/main.go -record-requests traffic.jsonl
+//	go run ./cmd/replay -in traffic.jsonl -url http://localhost:8080
+//	go run ./cmd/replay -in traffic.jsonl -speed 4 -format json -out replay.json
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/replay"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+func main() {
+	in := flag.String("in", "", "recorded requests, JSON lines written by -record-requests")
+	target := flag.String("url", "http://localhost:8080", "scheme and host to replay the requests against")
+	speed := flag.Float64("speed", 1, "replay speed: 1 keeps the recorded timing, 2 is twice as fast, 0 sends back to back")
+	concurrency := flag.Int("c", 100, "requests in flight at most")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of a request")
+	format := flag.String("format", "text", "result format: text or json")
+	out := flag.String("out", "", "write the comparison to this file instead of stdout")
+	flag.Parse()
+
+	if *in == "" {
+		log.Fatal("-in is required")
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown -format %q, must be text or json", *format)
+	}
+	records, err := replay.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ctrl-C ends the replay early but still compares what was replayed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Replaying %d requests against %s", len(records), *target)
+	comparison, err := replay.Replay(ctx, records, replay.Options{
+		BaseURL:     *target,
+		Speed:       *speed,
+		Concurrency: *concurrency,
+		Client:      util.NewHTTPClient(*concurrency, *timeout),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if comparison.Requests < len(records) {
+		log.Printf("Stopped after %d of %d requests, comparing those", comparison.Requests, len(records))
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatal(err)
+		}
+		defer w.Close()
+	}
+	if *format == "json" {
+		err = replay.WriteJSON(w, comparison)
+	} else {
+		err = replay.WriteText(w, comparison)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,108 @@
+// Command stocksvc serves the simulated stock service over HTTP and gRPC, so that the search API
+// can be load tested with real network overhead:
+//
+//	go run ./cmd/stocksvc -addr :8082 -stateful
+//	go run ./cmd/main.go -stock-service-url http://localhost:8082
+//	go run ./cmd/main.go -stock-service-url grpc://localhost:9082
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/catalog"
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":8082", "HTTP address to listen on")
+	grpcAddr := flag.String("grpc-addr", ":9082", "gRPC address to listen on (disabled if empty)")
+	seed := flag.Int64("seed", 0, "seed for the simulated service (0 picks a time-based seed)")
+	latency := flag.String("latency", "uniform:0ms,40ms", "latency model of the service, e.g. lognormal:15ms,0.6")
+	stateful := flag.Bool("stateful", false, "serve stock levels from an in-memory store seeded from the search catalog")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "export request spans to this OTLP/HTTP collector, e.g. http://localhost:4318 (disabled if empty)")
+	spansFile := flag.String("spans-file", "", "write request spans as JSON lines to this file, or stdout (disabled if empty)")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	model, err := util.ParseLatencyModel(*latency)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var backend stock.StockService
+	if *stateful {
+		_, quantities := catalog.Generate(*seed)
+		backend = stock.NewInMemoryStockService(quantities)
+	}
+	svc := stock.NewSimulatedStockServiceWithBackend(util.NewRand(util.DeriveSeed(*seed, "stock")), model, backend)
+
+	spanExporter, err := tracing.OpenExporter(*otlpEndpoint, *spansFile, "stocksvc")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tracer *tracing.Tracer
+	if spanExporter != nil {
+		tracer = tracing.NewTracer(spanExporter, tracing.Options{SampleRatio: 1})
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: tracer.Middleware(stock.NewHTTPHandler(svc)),
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcServer = grpc.NewServer()
+		pb.RegisterStockServiceServer(grpcServer, stock.NewGRPCServer(svc))
+		go func() {
+			log.Printf("Stock gRPC service starting on %s", *grpcAddr)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("grpc Serve(): %v", err)
+			}
+		}()
+	}
+
+	/*
+		Graceful shutdown
+	*/
+	idleConnsClosed := make(chan struct{})
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		log.Println("Shutting down stock service...")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		tracer.Shutdown(ctx)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(idleConnsClosed)
+	}()
+
+	log.Printf("Stock HTTP service starting on %s (-seed=%d, latency %s)", *addr, *seed, model)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("ListenAndServe(): %v", err)
+	}
+	<-idleConnsClosed
+}
@@ -5,8 +5,12 @@ package ads
 // This is a PoC for demonstrating bottlenecks in sequential vs concurrent code in Go.
 
 import (
+	"context"
 	"math/rand"
+	"slices"
+	"time"
 
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
 	"github.com/idagdelen/go-bottlenecks/internal/product"
 	"github.com/idagdelen/go-bottlenecks/internal/stock"
 	"github.com/idagdelen/go-bottlenecks/internal/util"
@@ -19,38 +23,77 @@ type RecommendedProduct struct {
 	Stock   *stock.Stock
 }
 
+// SponsoredProduct is a recommended product together with the position
+// at which it should be interleaved into the organic search results.
+type SponsoredProduct struct {
+	RecommendedProduct
+	Position int
+}
+
+// DefaultSlotInterval is the number of organic results between two sponsored slots.
+const DefaultSlotInterval = 4
+
 // AdsService provides product recommendations based on IDs
 // It uses product and stock services to fetch data.
 type AdsService struct {
 	ProductService product.ProductService
 	StockService   stock.StockService
+	// Faults, if set, applies the "ads" fault rules to recommendation lookups
+	Faults *fault.Injector
+	// ErrorRate is the probability that a recommendation lookup fails (util.DefaultErrorRate by default)
+	ErrorRate float64
+	// SlotInterval is the number of organic results between two sponsored slots (DefaultSlotInterval by default)
+	SlotInterval int
+	// Inventory holds the product IDs of running ad campaigns. Sponsored products are drawn from
+	// them as well as from the organic hits, so ads can promote products the search did not find.
+	Inventory []int
+
+	rng     *rand.Rand
+	latency util.LatencyModel
 }
 
 // NewAdsService creates a new AdsService with given product and stock services
 func NewAdsService(productService product.ProductService, stockService stock.StockService) *AdsService {
+	return NewAdsServiceWithRand(productService, stockService, util.NewRand(time.Now().UnixNano()))
+}
+
+// NewAdsServiceWithRand creates a new AdsService that draws ad picks, latencies and errors from rng
+func NewAdsServiceWithRand(productService product.ProductService, stockService stock.StockService, rng *rand.Rand) *AdsService {
+	return NewAdsServiceWithLatency(productService, stockService, rng, util.DefaultLatency)
+}
+
+// NewAdsServiceWithLatency creates a new AdsService whose recommendation lookups take
+// as long as sampled from the given latency model
+func NewAdsServiceWithLatency(productService product.ProductService, stockService stock.StockService, rng *rand.Rand, latency util.LatencyModel) *AdsService {
 	return &AdsService{
 		ProductService: productService,
 		StockService:   stockService,
+		ErrorRate:      util.DefaultErrorRate,
+		SlotInterval:   DefaultSlotInterval,
+		rng:            rng,
+		latency:        latency,
 	}
 }
 
 // RecommendProductByIDs fetches product and stock info for each ID and returns the first available product
-func (a *AdsService) RecommendProductByIDs(ids []int) (*RecommendedProduct, error) {
+func (a *AdsService) RecommendProductByIDs(ctx context.Context, ids []int) (*RecommendedProduct, error) {
 
-	id, err := a.GetRandomRecommendedID(ids)
+	id, err := a.GetRandomRecommendedID(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
-	// 5% error simulation (generic)
-	if err := util.SimulateError(0.05, "network error: failed to fetch product"); err != nil {
+	if err := a.Faults.Inject(ctx, fault.ServiceAds, id); err != nil {
+		return nil, err
+	}
+	if err := util.SimulateErrorWithRand(a.rng, a.ErrorRate, "network error: failed to fetch product"); err != nil {
 		return nil, err
 	}
 
-	prod, err := a.ProductService.GetProductByID(id)
+	prod, err := a.ProductService.GetProductByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	stk, err := a.StockService.GetStockByProductID(id)
+	stk, err := a.StockService.GetStockByProductID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -65,13 +108,89 @@ func (a *AdsService) RecommendProductByIDs(ids []int) (*RecommendedProduct, erro
 	return nil, nil // No available product found
 }
 
+// RecommendProductsByIDs picks up to slots distinct products from ids and the Inventory and
+// returns the ones that are in stock as sponsored products. Positions are indexes into the
+// merged results, assigned in order: the first slot tops the list and SlotInterval organic
+// results follow every slot.
+func (a *AdsService) RecommendProductsByIDs(ctx context.Context, ids []int, slots int) ([]SponsoredProduct, error) {
+	candidates, err := a.GetRandomRecommendedIDs(ctx, append(slices.Clip(ids), a.Inventory...), slots)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Faults.Inject(ctx, fault.ServiceAds, candidates...); err != nil {
+		return nil, err
+	}
+	if err := util.SimulateErrorWithRand(a.rng, a.ErrorRate, "network error: failed to fetch product"); err != nil {
+		return nil, err
+	}
+
+	sponsored := make([]SponsoredProduct, 0, len(candidates))
+	for _, id := range candidates {
+		prod, err := a.ProductService.GetProductByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		stk, err := a.StockService.GetStockByProductID(ctx, id)
+		if err != nil {
+			continue
+		}
+
+		// Only recommend if stock is available
+		if stk.Quantity > 0 {
+			sponsored = append(sponsored, SponsoredProduct{
+				RecommendedProduct: RecommendedProduct{
+					Product: prod,
+					Stock:   stk,
+				},
+				Position: len(sponsored) * (a.SlotInterval + 1),
+			})
+		}
+	}
+	return sponsored, nil
+}
+
+// GetRandomRecommendedIDs simulates a network call and returns up to n distinct random IDs from the list
+func (s *AdsService) GetRandomRecommendedIDs(ctx context.Context, ids []int, n int) ([]int, error) {
+	if len(ids) == 0 || n <= 0 {
+		return nil, nil
+	}
+	if err := util.SimulateLatency(ctx, s.rng, s.latency); err != nil {
+		return nil, err
+	}
+
+	if n > len(ids) {
+		n = len(ids)
+	}
+	picked := make([]int, 0, n)
+	for _, idx := range util.OrDefaultRand(s.rng).Perm(len(ids)) {
+		if len(picked) == n {
+			break
+		}
+		if !containsID(picked, ids[idx]) {
+			picked = append(picked, ids[idx])
+		}
+	}
+	return picked, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRandomRecommendedID simulates a network call and returns a random ID from the list
-func (s *AdsService) GetRandomRecommendedID(ids []int) (int, error) {
+func (s *AdsService) GetRandomRecommendedID(ctx context.Context, ids []int) (int, error) {
 	if len(ids) == 0 {
 		return 0, nil // or error if you prefer
 	}
-	util.SimulateIO(40)
+	if err := util.SimulateLatency(ctx, s.rng, s.latency); err != nil {
+		return 0, err
+	}
 
-	idx := rand.Intn(len(ids))
+	idx := util.OrDefaultRand(s.rng).Intn(len(ids))
 	return ids[idx], nil
 }
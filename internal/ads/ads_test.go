@@ -0,0 +1,36 @@
+package ads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+func TestRecommendProductsByIDsDrawsFromInventory(t *testing.T) {
+	products := []product.Product{{ID: 1}, {ID: 2}, {ID: 100}, {ID: 101}, {ID: 102}}
+	quantities := map[int]int{1: 0, 2: 0, 100: 5, 101: 5, 102: 5}
+	a := NewAdsServiceWithLatency(product.NewInMemoryProductService(products), stock.NewInMemoryStockService(quantities),
+		util.NewRand(1), util.ConstantLatency{})
+	a.ErrorRate = 0
+	a.Inventory = []int{100, 101, 102}
+
+	// The hits are out of stock, so every sponsored product comes from the inventory
+	sponsored, err := a.RecommendProductsByIDs(context.Background(), []int{1, 2}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sponsored) != 3 {
+		t.Fatalf("expected the 3 inventory products, got %d", len(sponsored))
+	}
+	for i, sp := range sponsored {
+		if sp.Product.ID < 100 {
+			t.Errorf("slot %d: out of stock hit %d sponsored", i, sp.Product.ID)
+		}
+		if want := i * (DefaultSlotInterval + 1); sp.Position != want {
+			t.Errorf("slot %d at position %d, want %d", i, sp.Position, want)
+		}
+	}
+}
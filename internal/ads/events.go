@@ -0,0 +1,205 @@
+package ads
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of interaction recorded for a sponsored product.
+type EventType string
+
+const (
+	EventImpression EventType = "impression"
+	EventClick      EventType = "click"
+)
+
+// ErrInvalidEvent is returned by Record when an event fails validation.
+var ErrInvalidEvent = errors.New("invalid ad event")
+
+// ErrAdNotServed is returned by Record for an event on a product that its search request did not
+// serve as a sponsored product, or for a request too old to be remembered (see Served).
+var ErrAdNotServed = errors.New("ad was not served")
+
+// ErrDuplicateEvent is returned by Record for an impression or click that was already recorded for
+// the same request and product. The event is not counted again, so a client may safely retry.
+var ErrDuplicateEvent = errors.New("ad event already recorded")
+
+// ErrSpill is returned by Record when an evicted event could not be appended to the spill writer.
+// The new event is recorded all the same.
+var ErrSpill = errors.New("spill ad event")
+
+// Event is a single impression or click linked to the search request that served the ad.
+type Event struct {
+	Type      EventType `json:"type"`
+	RequestID string    `json:"requestId"`
+	ProductID int       `json:"productId"`
+	Time      time.Time `json:"time"`
+}
+
+// ProductStats holds the aggregated counters for one sponsored product.
+type ProductStats struct {
+	ProductID   int     `json:"productId"`
+	Impressions int     `json:"impressions"`
+	Clicks      int     `json:"clicks"`
+	CTR         float64 `json:"ctr"` // Clicks / Impressions, 0 if there are no impressions
+}
+
+// EventLog keeps the most recent ad events in a fixed-size ring buffer.
+// When the buffer is full the oldest event is overwritten; if a spill writer is set,
+// the overwritten event is appended to it as a JSON line first.
+// Per-product counters are kept separately so stats survive eviction.
+// Events are only accepted for ads that were served; the sponsored products of the most recent
+// searches, as many as events fit in the buffer, are remembered for that.
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+	spill  *json.Encoder
+	counts map[int]*ProductStats
+
+	served      map[string]*servedAds // Ads by search request ID, see Served
+	servedOrder []string              // Request IDs in served, a ring like events
+	servedNext  int
+}
+
+// servedAds are the sponsored products one search request served and the events recorded on them.
+type servedAds struct {
+	products []int
+	recorded map[adEvent]bool
+}
+
+// adEvent identifies an event within a request, which counts at most once.
+type adEvent struct {
+	productID int
+	eventType EventType
+}
+
+// NewEventLog creates an EventLog holding up to capacity events. spill may be nil.
+func NewEventLog(capacity int, spill io.Writer) *EventLog {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	l := &EventLog{
+		events:      make([]Event, capacity),
+		counts:      make(map[int]*ProductStats),
+		served:      make(map[string]*servedAds),
+		servedOrder: make([]string, capacity),
+	}
+	if spill != nil {
+		l.spill = json.NewEncoder(spill)
+	}
+	return l
+}
+
+// Served records that the search request requestID served productIDs as sponsored products,
+// so that Record accepts impressions and clicks on them. Once as many later searches have served
+// ads as the log holds events, the request is forgotten.
+func (l *EventLog) Served(requestID string, productIDs []int) {
+	if requestID == "" || len(productIDs) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.served[requestID]; !ok {
+		if evicted := l.servedOrder[l.servedNext]; evicted != "" {
+			delete(l.served, evicted)
+		}
+		l.servedOrder[l.servedNext] = requestID
+		l.servedNext = (l.servedNext + 1) % len(l.servedOrder)
+	}
+	l.served[requestID] = &servedAds{products: slices.Clone(productIDs), recorded: make(map[adEvent]bool)}
+}
+
+// Record validates and stores an event on an ad recorded with Served. A zero Time is replaced with the current time.
+// Each request counts at most one impression and one click per product; repeats return ErrDuplicateEvent.
+// If spilling an evicted event fails, the new event is still stored and an error wrapping ErrSpill is returned.
+func (l *EventLog) Record(e Event) error {
+	if e.Type != EventImpression && e.Type != EventClick {
+		return fmt.Errorf("%w: unknown event type %q", ErrInvalidEvent, e.Type)
+	}
+	if e.RequestID == "" {
+		return fmt.Errorf("%w: requestId is required", ErrInvalidEvent)
+	}
+	if e.ProductID <= 0 {
+		return fmt.Errorf("%w: productId must be positive", ErrInvalidEvent)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	served := l.served[e.RequestID]
+	if served == nil || !slices.Contains(served.products, e.ProductID) {
+		return fmt.Errorf("%w: product %d by request %q", ErrAdNotServed, e.ProductID, e.RequestID)
+	}
+	key := adEvent{productID: e.ProductID, eventType: e.Type}
+	if served.recorded[key] {
+		return fmt.Errorf("%w: %s of product %d by request %q", ErrDuplicateEvent, e.Type, e.ProductID, e.RequestID)
+	}
+	served.recorded[key] = true
+
+	var spillErr error
+	if l.full && l.spill != nil {
+		if err := l.spill.Encode(l.events[l.next]); err != nil {
+			spillErr = fmt.Errorf("%w: %w", ErrSpill, err)
+		}
+	}
+	l.events[l.next] = e
+	l.next++
+	if l.next == len(l.events) {
+		l.next = 0
+		l.full = true
+	}
+
+	st, ok := l.counts[e.ProductID]
+	if !ok {
+		st = &ProductStats{ProductID: e.ProductID}
+		l.counts[e.ProductID] = st
+	}
+	if e.Type == EventImpression {
+		st.Impressions++
+	} else {
+		st.Clicks++
+	}
+	return spillErr
+}
+
+// Events returns the events currently held in memory, oldest first.
+func (l *EventLog) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]Event(nil), l.events[:l.next]...)
+	}
+	out := make([]Event, 0, len(l.events))
+	out = append(out, l.events[l.next:]...)
+	return append(out, l.events[:l.next]...)
+}
+
+// Stats returns impression, click and CTR counters per product, ordered by product ID.
+func (l *EventLog) Stats() []ProductStats {
+	l.mu.Lock()
+	out := make([]ProductStats, 0, len(l.counts))
+	for _, st := range l.counts {
+		s := *st
+		if s.Impressions > 0 {
+			s.CTR = float64(s.Clicks) / float64(s.Impressions)
+		}
+		out = append(out, s)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ProductID < out[j].ProductID })
+	return out
+}
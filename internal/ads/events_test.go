@@ -0,0 +1,130 @@
+package ads
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEventLogRingAndSpill(t *testing.T) {
+	var spill bytes.Buffer
+	l := NewEventLog(2, &spill)
+	l.Served("req", []int{1, 2, 3})
+
+	for i := 1; i <= 3; i++ {
+		if err := l.Record(Event{Type: EventImpression, RequestID: "req", ProductID: i}); err != nil {
+			t.Fatalf("Record(%d): %v", i, err)
+		}
+	}
+
+	events := l.Events()
+	if len(events) != 2 || events[0].ProductID != 2 || events[1].ProductID != 3 {
+		t.Fatalf("unexpected events in ring: %+v", events)
+	}
+
+	var spilled Event
+	if err := json.NewDecoder(&spill).Decode(&spilled); err != nil {
+		t.Fatalf("decode spilled event: %v", err)
+	}
+	if spilled.ProductID != 1 {
+		t.Fatalf("expected product 1 to be spilled, got %d", spilled.ProductID)
+	}
+}
+
+func TestEventLogStats(t *testing.T) {
+	l := NewEventLog(1, nil)
+	for _, e := range []Event{
+		{Type: EventImpression, RequestID: "a", ProductID: 7},
+		{Type: EventImpression, RequestID: "b", ProductID: 7},
+		{Type: EventClick, RequestID: "b", ProductID: 7},
+		{Type: EventImpression, RequestID: "c", ProductID: 3},
+	} {
+		l.Served(e.RequestID, []int{e.ProductID})
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := l.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats for 2 products, got %d", len(stats))
+	}
+	if stats[0].ProductID != 3 || stats[0].CTR != 0 {
+		t.Fatalf("unexpected stats for product 3: %+v", stats[0])
+	}
+	if stats[1].ProductID != 7 || stats[1].Impressions != 2 || stats[1].Clicks != 1 || stats[1].CTR != 0.5 {
+		t.Fatalf("unexpected stats for product 7: %+v", stats[1])
+	}
+
+	if err := l.Record(Event{Type: "view", RequestID: "x", ProductID: 1}); err == nil {
+		t.Fatal("expected error for unknown event type")
+	}
+}
+
+func TestEventLogOnlyAcceptsServedAds(t *testing.T) {
+	l := NewEventLog(2, nil)
+	l.Served("a", []int{7, 9})
+	l.Served("no-ads", nil)
+
+	for _, e := range []Event{
+		{Type: EventImpression, RequestID: "a", ProductID: 7},
+		{Type: EventClick, RequestID: "a", ProductID: 9},
+	} {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record(%+v): %v", e, err)
+		}
+	}
+	for _, e := range []Event{
+		{Type: EventImpression, RequestID: "a", ProductID: 8},
+		{Type: EventClick, RequestID: "made-up", ProductID: 7},
+		{Type: EventImpression, RequestID: "no-ads", ProductID: 7},
+	} {
+		if err := l.Record(e); !errors.Is(err, ErrAdNotServed) {
+			t.Fatalf("Record(%+v): expected ErrAdNotServed, got %v", e, err)
+		}
+	}
+
+	// Two later searches with ads make the log forget a
+	l.Served("b", []int{1})
+	l.Served("c", []int{2})
+	if err := l.Record(Event{Type: EventClick, RequestID: "a", ProductID: 7}); !errors.Is(err, ErrAdNotServed) {
+		t.Fatalf("expected ErrAdNotServed for a forgotten request, got %v", err)
+	}
+	if err := l.Record(Event{Type: EventClick, RequestID: "b", ProductID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := l.Stats(); len(stats) != 3 {
+		t.Fatalf("rejected events were counted: %+v", stats)
+	}
+}
+
+// failingWriter fails every write, like a spill file on a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestEventLogCountsEachEventOnce(t *testing.T) {
+	l := NewEventLog(1, failingWriter{})
+	l.Served("a", []int{7})
+
+	if err := l.Record(Event{Type: EventImpression, RequestID: "a", ProductID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	// The ring is full now, so the next event evicts the impression, which cannot be spilled
+	if err := l.Record(Event{Type: EventClick, RequestID: "a", ProductID: 7}); !errors.Is(err, ErrSpill) {
+		t.Fatalf("expected ErrSpill, got %v", err)
+	}
+	for _, typ := range []EventType{EventImpression, EventClick} {
+		if err := l.Record(Event{Type: typ, RequestID: "a", ProductID: 7}); !errors.Is(err, ErrDuplicateEvent) {
+			t.Fatalf("repeated %s: expected ErrDuplicateEvent, got %v", typ, err)
+		}
+	}
+	if events := l.Events(); len(events) != 1 || events[0].Type != EventClick {
+		t.Fatalf("expected the click to be stored despite the spill error, got %+v", events)
+	}
+	stats := l.Stats()
+	if len(stats) != 1 || stats[0].Impressions != 1 || stats[0].Clicks != 1 || stats[0].CTR != 1 {
+		t.Fatalf("expected one impression and one click, got %+v", stats)
+	}
+}
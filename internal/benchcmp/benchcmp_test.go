@@ -0,0 +1,179 @@
+package benchcmp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const oldOutput = `goos: linux
+goarch: amd64
+pkg: github.com/idagdelen/go-bottlenecks/pkg/api
+cpu: AMD EPYC 7B13
+BenchmarkSearch/strategy=sequential-8         	     100	  10000000 ns/op	    2048 B/op	      20 allocs/op
+BenchmarkSearch/strategy=sequential-8         	     100	  10100000 ns/op	    2048 B/op	      20 allocs/op
+BenchmarkSearch/strategy=sequential-8         	     100	  10200000 ns/op	    2048 B/op	      20 allocs/op
+BenchmarkSearch/strategy=sequential-8         	     100	  10300000 ns/op	    2048 B/op	      20 allocs/op
+BenchmarkSearch/strategy=sequential-8         	     100	  10400000 ns/op	    2048 B/op	      20 allocs/op
+BenchmarkEncode-8   	  500000	      2000 ns/op
+BenchmarkEncode-8   	  500000	      2100 ns/op
+--- FAIL: BenchmarkBroken
+PASS
+ok  	github.com/idagdelen/go-bottlenecks/pkg/api	12.345s
+`
+
+func TestParse(t *testing.T) {
+	run, err := Parse(strings.NewReader(oldOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Config["goarch"] != "amd64" || run.Config["cpu"] != "AMD EPYC 7B13" {
+		t.Errorf("config = %v", run.Config)
+	}
+	if len(run.Benchmarks) != 2 {
+		t.Fatalf("benchmarks = %+v", run.Benchmarks)
+	}
+	b := run.Find("github.com/idagdelen/go-bottlenecks/pkg/api.Search/strategy=sequential")
+	if b == nil {
+		t.Fatalf("search benchmark not found in %+v", run.Benchmarks)
+	}
+	if len(b.Samples["ns/op"]) != 5 || b.Samples["ns/op"][4] != 10400000 || b.Samples["allocs/op"][0] != 20 {
+		t.Errorf("samples = %v", b.Samples)
+	}
+	if _, err := Parse(strings.NewReader("BenchmarkX-8  10  fast ns/op\n")); err == nil {
+		t.Error("bad value parsed")
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	for _, c := range []struct {
+		a, b []float64
+		want float64
+	}{
+		// Completely separated samples: 2 of the 252 arrangements of 5+5 values are as extreme
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		{[]float64{6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5}, 2.0 / 252},
+		{[]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 0.690},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+		{[]float64{1}, []float64{100, 200}, 1},
+	} {
+		if got := MannWhitney(c.a, c.b); math.Abs(got-c.want) > 0.001 {
+			t.Errorf("MannWhitney(%v, %v) = %.4f, want %.4f", c.a, c.b, got, c.want)
+		}
+	}
+	// Ties use the normal approximation, which still separates clearly different samples
+	if p := MannWhitney([]float64{20, 20, 20, 20, 20}, []float64{25, 25, 25, 25, 25}); p > 0.05 {
+		t.Errorf("tied samples p = %.4f", p)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old, err := Parse(strings.NewReader(oldOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var newOutput strings.Builder
+	newOutput.WriteString("pkg: github.com/idagdelen/go-bottlenecks/pkg/api\n")
+	for i := range 5 {
+		// 10% slower and two more allocations, same bytes
+		fmt.Fprintf(&newOutput, "BenchmarkSearch/strategy=sequential-8  100  %d ns/op  2048 B/op  22 allocs/op\n", 11000000+i*100000)
+	}
+	newOutput.WriteString("BenchmarkEncode-8  500000  2050 ns/op\nBenchmarkEncode-8  500000  1990 ns/op\n")
+	new, err := Parse(strings.NewReader(newOutput.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thresholds, err := ParseThresholds("ns/op=15,allocs/op=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := Compare(old, new, 0.05, thresholds)
+	if len(cs) != 4 {
+		t.Fatalf("comparisons = %+v", cs)
+	}
+	byUnit := map[string]Comparison{}
+	for _, c := range cs[:3] {
+		byUnit[c.Unit] = c
+	}
+	if c := byUnit["ns/op"]; !c.Significant || c.Regression || math.Abs(c.Delta-9.8) > 0.01 {
+		t.Errorf("ns/op = %+v, want a significant change below the threshold", c)
+	}
+	if c := byUnit["allocs/op"]; !c.Regression || c.Delta != 10 {
+		t.Errorf("allocs/op = %+v, want a regression", c)
+	}
+	if c := byUnit["B/op"]; c.Significant || c.Delta != 0 {
+		t.Errorf("B/op = %+v, want no change", c)
+	}
+	if c := cs[3]; c.Significant || c.P < 0.5 {
+		t.Errorf("encode = %+v, two samples are too few to tell", c)
+	}
+	if rs := Regressions(cs); len(rs) != 1 || rs[0].Unit != "allocs/op" {
+		t.Errorf("regressions = %+v", rs)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTable(&buf, cs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"old ns/op", "10.20ms ± 2%", "11.20ms ± 2%", "+9.80%", "2.00KiB", "pkg: github.com/idagdelen/go-bottlenecks/pkg/api", "\nEncode ", "~ (p=0.667 n=2+2)", "REGRESSION"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("table lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	th, err := ParseThresholds("7.5%")
+	if err != nil || th["ns/op"] != 7.5 || th["B/op"] != 7.5 || th["allocs/op"] != 7.5 {
+		t.Errorf("ParseThresholds(7.5%%) = %v, %v", th, err)
+	}
+	for _, bad := range []string{"ns/op", "ns/op=fast", "=5", "ns/op=-1"} {
+		if _, err := ParseThresholds(bad); err == nil {
+			t.Errorf("ParseThresholds(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if runs, err := ReadHistory(path); err != nil || len(runs) != 0 {
+		t.Fatalf("missing history = %v, %v", runs, err)
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, ns := range []float64{1000, 1100, 990} {
+		run := &Run{Time: start.Add(time.Duration(i) * time.Hour), Label: fmt.Sprintf("rev%d", i), Benchmarks: []*Benchmark{
+			{Name: "Search", Samples: map[string][]float64{"ns/op": {ns, ns}}},
+			{Name: "Encode", Samples: map[string][]float64{"ns/op": {10}}},
+		}}
+		if err := AppendHistory(path, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := ReadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[2].Label != "rev2" || !runs[2].Time.Equal(start.Add(2*time.Hour)) || runs[1].Find("Search").Samples["ns/op"][0] != 1100 {
+		t.Fatalf("runs = %+v", runs)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTrend(&buf, runs, "ns/op", regexp.MustCompile("Search")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"2026-01-01 13:00 rev1", "1.10µs", "+10.00%", "-10.00%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trend lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Encode") {
+		t.Errorf("trend is not filtered:\n%s", out)
+	}
+}
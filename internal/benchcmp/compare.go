@@ -0,0 +1,195 @@
+package benchcmp
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Units compared by default, in the order they are reported. Lower is better for all of them.
+var Units = []string{"ns/op", "B/op", "allocs/op"}
+
+// Thresholds are the changes in percent beyond which a significant increase of a unit is a
+// regression, e.g. {"ns/op": 5, "allocs/op": 0}. Units without a threshold are never regressions.
+type Thresholds map[string]float64
+
+// ParseThresholds parses a percentage that applies to all of Units, like "5", or a list of
+// unit=percentage pairs, like "ns/op=10,B/op=5,allocs/op=0".
+func ParseThresholds(s string) (Thresholds, error) {
+	t := Thresholds{}
+	if v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err == nil {
+		for _, unit := range Units {
+			t[unit] = v
+		}
+		return t, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		unit, pct, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		v, err := strconv.ParseFloat(strings.TrimSuffix(pct, "%"), 64)
+		if !ok || unit == "" || err != nil || v < 0 {
+			return nil, fmt.Errorf("bad threshold %q, want a percentage or unit=percentage pairs", pair)
+		}
+		t[unit] = v
+	}
+	return t, nil
+}
+
+// Comparison compares one unit of a benchmark between two runs.
+type Comparison struct {
+	Pkg         string  `json:"pkg,omitempty"`
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Old         Summary `json:"old"`
+	New         Summary `json:"new"`
+	Delta       float64 `json:"delta"` // Change of the median in percent
+	P           float64 `json:"p"`
+	Significant bool    `json:"significant"`
+	Regression  bool    `json:"regression"`
+}
+
+// Key identifies the compared benchmark, like Benchmark.Key.
+func (c Comparison) Key() string {
+	return keyOf(c.Pkg, c.Name)
+}
+
+// Compare compares the benchmarks and units that old and new have in common, in the order of new.
+// A change is significant if its p-value is below alpha, and a regression if it is also an
+// increase beyond the unit's threshold.
+func Compare(old, new *Run, alpha float64, thresholds Thresholds) []Comparison {
+	var cs []Comparison
+	for _, nb := range new.Benchmarks {
+		ob := old.Find(nb.Key())
+		if ob == nil {
+			continue
+		}
+		for _, unit := range units(nb) {
+			os, ns := ob.Samples[unit], nb.Samples[unit]
+			if len(os) == 0 {
+				continue
+			}
+			c := Comparison{Pkg: nb.Pkg, Name: nb.Name, Unit: unit, Old: Summarize(os), New: Summarize(ns), P: MannWhitney(os, ns)}
+			if c.Old.Median != 0 {
+				c.Delta = (c.New.Median - c.Old.Median) / math.Abs(c.Old.Median) * 100
+			} else if c.New.Median != 0 {
+				c.Delta = math.Inf(1)
+			}
+			c.Significant = c.P < alpha && c.Delta != 0
+			if limit, ok := thresholds[unit]; ok && c.Significant && c.Delta > limit {
+				c.Regression = true
+			}
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// units returns the units of b, Units first and the others, e.g. custom metrics, sorted after them.
+func units(b *Benchmark) []string {
+	var us, others []string
+	for _, u := range Units {
+		if _, ok := b.Samples[u]; ok {
+			us = append(us, u)
+		}
+	}
+	for u := range b.Samples {
+		if !slices.Contains(Units, u) {
+			others = append(others, u)
+		}
+	}
+	slices.Sort(others)
+	return append(us, others...)
+}
+
+// Regressions returns the comparisons that are regressions.
+func Regressions(cs []Comparison) []Comparison {
+	var rs []Comparison
+	for _, c := range cs {
+		if c.Regression {
+			rs = append(rs, c)
+		}
+	}
+	return rs
+}
+
+// WriteTable writes cs grouped by package and unit, like benchstat: medians ± spread, the change
+// of the median or ~ if it is not significant, and the p-value and sample counts.
+func WriteTable(w io.Writer, cs []Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	var pkgs, unitOrder []string
+	for _, c := range cs {
+		if !slices.Contains(pkgs, c.Pkg) {
+			pkgs = append(pkgs, c.Pkg)
+		}
+		if !slices.Contains(unitOrder, c.Unit) {
+			unitOrder = append(unitOrder, c.Unit)
+		}
+	}
+	first := true
+	for _, pkg := range pkgs {
+		for _, unit := range unitOrder {
+			header := false
+			for _, c := range cs {
+				if c.Pkg != pkg || c.Unit != unit {
+					continue
+				}
+				if !header {
+					if !first {
+						fmt.Fprintln(tw)
+					}
+					if pkg != "" {
+						fmt.Fprintf(tw, "pkg: %s\n", pkg)
+					}
+					fmt.Fprintf(tw, "name\told %s\tnew %s\tdelta\n", unit, unit)
+					header, first = true, false
+				}
+				delta := "~"
+				if c.Significant {
+					delta = fmt.Sprintf("%+.2f%%", c.Delta)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s (p=%.3f n=%d+%d)", c.Name, FormatValue(c.Old, unit), FormatValue(c.New, unit), delta, c.P, c.Old.N, c.New.N)
+				if c.Regression {
+					fmt.Fprint(tw, "  REGRESSION")
+				}
+				fmt.Fprintln(tw)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// FormatValue formats a median with its spread, scaling times and sizes to readable units.
+func FormatValue(s Summary, unit string) string {
+	return fmt.Sprintf("%s ± %.0f%%", Scale(s.Median, unit), s.Spread)
+}
+
+// Scale formats v of unit, e.g. 1.5e6 ns/op as 1.50ms and 2048 B/op as 2.00KiB.
+func Scale(v float64, unit string) string {
+	switch unit {
+	case "ns/op":
+		for _, s := range []struct {
+			div    float64
+			suffix string
+		}{{1e9, "s"}, {1e6, "ms"}, {1e3, "µs"}} {
+			if math.Abs(v) >= s.div {
+				return fmt.Sprintf("%.2f%s", v/s.div, s.suffix)
+			}
+		}
+		return fmt.Sprintf("%.2fns", v)
+	case "B/op":
+		for _, s := range []struct {
+			div    float64
+			suffix string
+		}{{1 << 30, "GiB"}, {1 << 20, "MiB"}, {1 << 10, "KiB"}} {
+			if math.Abs(v) >= s.div {
+				return fmt.Sprintf("%.2f%s", v/s.div, s.suffix)
+			}
+		}
+		return fmt.Sprintf("%.0fB", v)
+	default:
+		return strconv.FormatFloat(v, 'g', 4, 64)
+	}
+}
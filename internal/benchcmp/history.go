@@ -0,0 +1,104 @@
+package benchcmp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// AppendHistory appends run as one JSON line to the history file at path, which is created if needed.
+func AppendHistory(path string, run *Run) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(run); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory reads the runs of the history file at path, oldest first. A missing file is an
+// empty history.
+func ReadHistory(path string) ([]*Run, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var runs []*Run
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal(sc.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, line, err)
+		}
+		runs = append(runs, &run)
+	}
+	return runs, sc.Err()
+}
+
+// WriteTrend writes the median of unit for every benchmark matching filter (all if nil) in each
+// of runs, with the change to the previous run it appeared in.
+func WriteTrend(w io.Writer, runs []*Run, unit string, filter *regexp.Regexp) error {
+	var keys []string
+	seen := map[string]bool{}
+	for _, run := range runs {
+		for _, b := range run.Benchmarks {
+			if key := b.Key(); !seen[key] && len(b.Samples[unit]) > 0 && (filter == nil || filter.MatchString(key)) {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		_, err := fmt.Fprintf(w, "no %s results in %d runs\n", unit, len(runs))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s\t%s\tchange\n", key, unit)
+		var prev float64
+		for _, run := range runs {
+			b := run.Find(key)
+			if b == nil || len(b.Samples[unit]) == 0 {
+				continue
+			}
+			s := Summarize(b.Samples[unit])
+			change := ""
+			if prev != 0 {
+				change = fmt.Sprintf("%+.2f%%", (s.Median-prev)/prev*100)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", runName(run), FormatValue(s, unit), change)
+			prev = s.Median
+		}
+	}
+	return tw.Flush()
+}
+
+func runName(run *Run) string {
+	name := run.Time.Format("2006-01-02 15:04")
+	if run.Label != "" {
+		name += " " + run.Label
+	}
+	return name
+}
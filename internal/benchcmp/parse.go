@@ -0,0 +1,99 @@
+// Package benchcmp compares go test -bench results the way benchstat does: every benchmark is run
+// several times (-count), the medians of two runs are compared and a difference only counts when
+// a Mann-Whitney U test says it is unlikely to be noise. Differences in ns/op, B/op and allocs/op
+// beyond a threshold are regressions, and runs can be kept in a history file to follow trends.
+package benchcmp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run is the output of one go test -bench invocation, possibly covering several packages.
+type Run struct {
+	Time       time.Time         `json:"time"`
+	Label      string            `json:"label,omitempty"`  // e.g. a git revision
+	Config     map[string]string `json:"config,omitempty"` // goos, goarch and cpu
+	Benchmarks []*Benchmark      `json:"benchmarks"`
+}
+
+// Benchmark holds the measurements of one benchmark across repeated runs.
+type Benchmark struct {
+	Pkg     string               `json:"pkg,omitempty"`
+	Name    string               `json:"name"`    // Without the Benchmark prefix and the -GOMAXPROCS suffix
+	Samples map[string][]float64 `json:"samples"` // Values by unit, e.g. ns/op
+}
+
+// Key identifies a benchmark across runs.
+func (b *Benchmark) Key() string {
+	if b.Pkg == "" {
+		return b.Name
+	}
+	return b.Pkg + "." + b.Name
+}
+
+// Find returns the benchmark with the given key, or nil.
+func (r *Run) Find(key string) *Benchmark {
+	for _, b := range r.Benchmarks {
+		if b.Key() == key {
+			return b
+		}
+	}
+	return nil
+}
+
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// Parse reads go test -bench output. Lines that are not benchmark results or the goos, goarch,
+// cpu and pkg headers are ignored, so that the output of go test -v or a whole CI log can be given.
+func Parse(r io.Reader) (*Run, error) {
+	run := &Run{Config: map[string]string{}}
+	var pkg string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			switch key {
+			case "pkg":
+				pkg = value
+				continue
+			case "goos", "goarch", "cpu":
+				run.Config[key] = value
+				continue
+			}
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue // e.g. "BenchmarkFoo    --- FAIL"
+		}
+		name := procsSuffix.ReplaceAllString(strings.TrimPrefix(fields[0], "Benchmark"), "")
+		b := run.Find(keyOf(pkg, name))
+		if b == nil {
+			b = &Benchmark{Pkg: pkg, Name: name, Samples: map[string][]float64{}}
+			run.Benchmarks = append(run.Benchmarks, b)
+		}
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad value %q", fields[0], fields[i])
+			}
+			b.Samples[fields[i+1]] = append(b.Samples[fields[i+1]], v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func keyOf(pkg, name string) string {
+	return (&Benchmark{Pkg: pkg, Name: name}).Key()
+}
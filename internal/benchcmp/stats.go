@@ -0,0 +1,129 @@
+package benchcmp
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// Summary describes the samples of one benchmark and unit.
+type Summary struct {
+	N      int     `json:"n"`
+	Median float64 `json:"median"`
+	Spread float64 `json:"spread"` // Largest deviation from the median, in percent of it
+}
+
+// Summarize returns the median and spread of samples.
+func Summarize(samples []float64) Summary {
+	if len(samples) == 0 {
+		return Summary{}
+	}
+	s := slices.Sorted(slices.Values(samples))
+	median := s[len(s)/2]
+	if len(s)%2 == 0 {
+		median = (s[len(s)/2-1] + s[len(s)/2]) / 2
+	}
+	var spread float64
+	if median != 0 {
+		spread = max(median-s[0], s[len(s)-1]-median) / math.Abs(median) * 100
+	}
+	return Summary{N: len(s), Median: median, Spread: spread}
+}
+
+// MannWhitney returns the two-sided p-value of the Mann-Whitney U test, the probability of seeing
+// samples at least this differently ranked if both came from the same distribution. Without ties
+// and for small samples the p-value is exact, otherwise it uses the normal approximation with tie
+// correction. Fewer than two samples on either side never differ.
+func MannWhitney(a, b []float64) float64 {
+	n1, n2 := len(a), len(b)
+	if n1 < 2 || n2 < 2 {
+		return 1
+	}
+
+	type value struct {
+		v     float64
+		first bool
+	}
+	all := make([]value, 0, n1+n2)
+	for _, v := range a {
+		all = append(all, value{v, true})
+	}
+	for _, v := range b {
+		all = append(all, value{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank sum of a, with tied values sharing the mean of their ranks
+	var ranks, tieTerm float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // Ranks i+1..j
+		for k := i; k < j; k++ {
+			if all[k].first {
+				ranks += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u := ranks - float64(n1*(n1+1))/2
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return exactP(u, n1, n2)
+	}
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 1 // All values are equal
+	}
+	z := max(math.Abs(u-mean)-0.5, 0) / math.Sqrt(variance)
+	return min(math.Erfc(z/math.Sqrt2), 1)
+}
+
+// exactP returns the two-sided p-value of U from its exact distribution, counting the
+// arrangements of n1 and n2 values that give each U.
+func exactP(u float64, n1, n2 int) float64 {
+	// counts[j][x] is the number of arrangements of i values of a and j of b with U = x, built up over i
+	maxU := n1 * n2
+	counts := make([][]float64, n2+1)
+	for j := range counts {
+		counts[j] = make([]float64, maxU+1)
+		counts[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		next := make([][]float64, n2+1)
+		next[0] = make([]float64, maxU+1)
+		next[0][0] = 1
+		for j := 1; j <= n2; j++ {
+			next[j] = make([]float64, maxU+1)
+			for x := 0; x <= i*j; x++ {
+				// The largest value is either from a (beating all j values of b) or from b
+				if x >= j {
+					next[j][x] += counts[j][x-j]
+				}
+				next[j][x] += next[j-1][x]
+			}
+		}
+		counts = next
+	}
+	dist := counts[n2]
+	var total, lower, upper float64
+	for x, c := range dist {
+		total += c
+		if float64(x) <= u {
+			lower += c
+		}
+		if float64(x) >= u {
+			upper += c
+		}
+	}
+	return min(2*min(lower, upper)/total, 1)
+}
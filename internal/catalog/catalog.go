@@ -0,0 +1,47 @@
+// Package catalog generates the stable product and stock data that the stateful stores are seeded with.
+// The in-process server and the standalone product and stock services all use it, so that
+// the same seed produces the same prices and stock levels in every process.
+package catalog
+
+import (
+	"fmt"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+// Generate returns a product and a stock quantity for every product in the search catalog.
+// Prices, descriptions and stock levels are drawn once from seed.
+func Generate(seed int64) ([]product.Product, map[int]int) {
+	rng := util.NewRand(util.DeriveSeed(seed, "catalog"))
+	entries := search.Catalog()
+
+	products := make([]product.Product, len(entries))
+	quantities := make(map[int]int, len(entries))
+	for i, c := range entries {
+		products[i] = product.Product{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: fmt.Sprintf("Catalog product %d.", c.ID),
+			Price:       money.FromMajor(rng.Float64()*100+1, money.DefaultCurrency), // Price between 1 and 100
+		}
+		quantities[c.ID] = rng.Intn(101)
+	}
+	return products, quantities
+}
+
+// AdInventorySize is the number of catalog products with a running ad campaign.
+const AdInventorySize = 50
+
+// AdInventory returns the IDs of the catalog products with a running ad campaign, drawn once from seed.
+func AdInventory(seed int64) []int {
+	rng := util.NewRand(util.DeriveSeed(seed, "ad-inventory"))
+	entries := search.Catalog()
+	ids := make([]int, min(AdInventorySize, len(entries)))
+	for i, idx := range rng.Perm(len(entries))[:len(ids)] {
+		ids[i] = entries[idx].ID
+	}
+	return ids
+}
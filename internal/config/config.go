@@ -0,0 +1,356 @@
+// Package config holds the server's tunables in one typed struct. Values come from defaults,
+// a YAML or JSON file, BOTTLENECKS_* environment variables and command-line flags, each
+// source overriding the previous one (see Load).
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration.
+type Config struct {
+	Server     ServerConfig     `json:"server" yaml:"server"`
+	Logging    LoggingConfig    `json:"logging" yaml:"logging"`
+	Debug      DebugConfig      `json:"debug" yaml:"debug"`
+	Tracing    TracingConfig    `json:"tracing" yaml:"tracing"`
+	Search     SearchConfig     `json:"search" yaml:"search"`
+	Ads        AdsConfig        `json:"ads" yaml:"ads"`
+	Simulation SimulationConfig `json:"simulation" yaml:"simulation"`
+	Services   ServicesConfig   `json:"services" yaml:"services"`
+	Pricing    PricingConfig    `json:"pricing" yaml:"pricing"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Addr            string   `json:"addr" yaml:"addr"`                       // Address to listen on
+	RequestTimeout  Duration `json:"requestTimeout" yaml:"requestTimeout"`   // Per-request timeout
+	ShutdownTimeout Duration `json:"shutdownTimeout" yaml:"shutdownTimeout"` // Time given to in-flight requests on shutdown
+	SwaggerFile     string   `json:"swaggerFile" yaml:"swaggerFile"`         // swagger.json served at /swagger.json
+	RecordFile      string   `json:"recordFile" yaml:"recordFile"`           // Append /api requests as JSON lines for cmd/replay (disabled if empty)
+}
+
+// LoggingConfig configures the structured log.
+type LoggingConfig struct {
+	Level            string `json:"level" yaml:"level"`                       // debug, info, warn or error
+	Format           string `json:"format" yaml:"format"`                     // json or text
+	SampleInitial    int    `json:"sampleInitial" yaml:"sampleInitial"`       // Lines per message and second logged before sampling (no sampling if 0)
+	SampleThereafter int    `json:"sampleThereafter" yaml:"sampleThereafter"` // Then every n-th line is logged (none if 0)
+}
+
+// DebugConfig configures the debug server, profiling and tracing.
+type DebugConfig struct {
+	Addr           string   `json:"addr" yaml:"addr"`                     // Debug server (pprof, expvar, runtime metrics) listen address (disabled if empty)
+	Username       string   `json:"username" yaml:"username"`             // Basic auth user of the debug server (no auth if empty)
+	Password       string   `json:"password" yaml:"password"`             // Basic auth password of the debug server
+	TraceDir       string   `json:"traceDir" yaml:"traceDir"`             // Directory of runtime trace captures and flight recorder dumps
+	TraceMaxFiles  int      `json:"traceMaxFiles" yaml:"traceMaxFiles"`   // Trace files kept in TraceDir (0 keeps all)
+	TraceOnStart   Duration `json:"traceOnStart" yaml:"traceOnStart"`     // Capture a runtime trace for this long after startup (disabled if 0)
+	FlightRecorder Duration `json:"flightRecorder" yaml:"flightRecorder"` // Keep this much recent runtime trace in memory (disabled if 0)
+
+	ProfileDir           string   `json:"profileDir" yaml:"profileDir"`                     // Directory of continuous profiling snapshots
+	ProfileInterval      Duration `json:"profileInterval" yaml:"profileInterval"`           // Time between snapshots (continuous profiling disabled if 0)
+	ProfileCPUDuration   Duration `json:"profileCpuDuration" yaml:"profileCpuDuration"`     // Length of each snapshot's CPU profile
+	ProfileMaxSnapshots  int      `json:"profileMaxSnapshots" yaml:"profileMaxSnapshots"`   // Snapshots kept in ProfileDir (0 keeps all)
+	ProfileMutexFraction int      `json:"profileMutexFraction" yaml:"profileMutexFraction"` // Sample 1 in n mutex contention events (runtime default, off, if 0)
+	ProfileBlockRate     Duration `json:"profileBlockRate" yaml:"profileBlockRate"`         // Sample one blocking event per this much time blocked (runtime default, off, if 0)
+
+	WatchdogInterval Duration `json:"watchdogInterval" yaml:"watchdogInterval"` // Time between goroutine counts of the leak watchdog (disabled if 0)
+	WatchdogWindow   int      `json:"watchdogWindow" yaml:"watchdogWindow"`     // Consecutive rising counts that log a suspected goroutine leak
+	WatchdogMin      int      `json:"watchdogMin" yaml:"watchdogMin"`           // Goroutine count below which the watchdog stays quiet
+}
+
+// TracingConfig configures request tracing (spans), as opposed to the runtime traces of DebugConfig.
+type TracingConfig struct {
+	OTLPEndpoint string  `json:"otlpEndpoint" yaml:"otlpEndpoint"` // OTLP/HTTP collector, e.g. http://localhost:4318 (disabled if empty)
+	File         string  `json:"file" yaml:"file"`                 // JSON lines span file, "stdout" for standard output (disabled if empty)
+	SampleRatio  float64 `json:"sampleRatio" yaml:"sampleRatio"`   // Share of new traces that are recorded
+	ServiceName  string  `json:"serviceName" yaml:"serviceName"`   // service.name of exported spans
+}
+
+// Search backends of the search endpoint
+const (
+	BackendHeap       = "heap"        // Scores the whole catalog, keeps the top results in a min-heap
+	BackendBruteForce = "brute-force" // Scores and sorts the whole catalog
+	BackendQdrant     = "qdrant"      // Queries the Qdrant vector database at search.qdrantUrl
+)
+
+// SearchBackends lists the valid values of search.backend
+var SearchBackends = []string{BackendHeap, BackendBruteForce, BackendQdrant}
+
+// Enrichment strategies of the search endpoint
+const (
+	StrategySequential          = "sequential"
+	StrategyWorkerPool          = "worker-pool"
+	StrategyParallel            = "parallel"
+	StrategyParallelPrefetchAds = "parallel-prefetch-ads"
+	StrategyBatch               = "batch"
+)
+
+// EnrichStrategies lists the valid values of search.strategy
+var EnrichStrategies = []string{StrategySequential, StrategyWorkerPool, StrategyParallel, StrategyParallelPrefetchAds, StrategyBatch}
+
+// SearchConfig configures the search endpoint.
+type SearchConfig struct {
+	DefaultItemCount int    `json:"defaultItemCount" yaml:"defaultItemCount"` // Results returned if itemCount is missing
+	Backend          string `json:"backend" yaml:"backend"`                   // Search backend, one of SearchBackends
+	Strategy         string `json:"strategy" yaml:"strategy"`                 // Enrichment strategy, one of EnrichStrategies
+	EnrichWorkers    int    `json:"enrichWorkers" yaml:"enrichWorkers"`       // Worker pool size of the worker-pool strategy
+	QdrantURL        string `json:"qdrantUrl" yaml:"qdrantUrl"`               // Base URL of the Qdrant server of the qdrant backend
+}
+
+// AdsConfig configures sponsored products and ad event tracking.
+type AdsConfig struct {
+	DefaultSlots   int    `json:"defaultSlots" yaml:"defaultSlots"`     // Sponsored products if adSlots is missing
+	MaxSlots       int    `json:"maxSlots" yaml:"maxSlots"`             // Upper bound of adSlots
+	SlotInterval   int    `json:"slotInterval" yaml:"slotInterval"`     // Organic results between two sponsored slots
+	EventsCapacity int    `json:"eventsCapacity" yaml:"eventsCapacity"` // Ad events kept in memory
+	EventsFile     string `json:"eventsFile" yaml:"eventsFile"`         // Spill file of evicted ad events (disabled if empty)
+}
+
+// SimulationConfig configures the simulated product, stock and ads services.
+type SimulationConfig struct {
+	Seed           int64    `json:"seed" yaml:"seed"`                     // 0 picks a time-based seed
+	Stateful       bool     `json:"stateful" yaml:"stateful"`             // Serve data from in-memory stores
+	StockShards    int      `json:"stockShards" yaml:"stockShards"`       // 0 uses a mutex per product
+	ReservationTTL Duration `json:"reservationTtl" yaml:"reservationTtl"` // Time after which an unfinished reservation is released
+	ErrorRate      float64  `json:"errorRate" yaml:"errorRate"`           // Probability that a simulated call fails
+	LatencyFile    string   `json:"latencyFile" yaml:"latencyFile"`       // JSON file mapping services to latency specs
+	ProductLatency string   `json:"productLatency" yaml:"productLatency"` // Overrides LatencyFile, e.g. lognormal:15ms,0.6
+	StockLatency   string   `json:"stockLatency" yaml:"stockLatency"`     // Overrides LatencyFile
+	AdsLatency     string   `json:"adsLatency" yaml:"adsLatency"`         // Overrides LatencyFile
+}
+
+// ServicesConfig configures remote product and stock services.
+type ServicesConfig struct {
+	ProductURL   string   `json:"productUrl" yaml:"productUrl"`     // http://, https:// or grpc:// URL (in-process if empty)
+	StockURL     string   `json:"stockUrl" yaml:"stockUrl"`         // http://, https:// or grpc:// URL (in-process if empty)
+	MaxIdleConns int      `json:"maxIdleConns" yaml:"maxIdleConns"` // Idle HTTP connections kept per service
+	Timeout      Duration `json:"timeout" yaml:"timeout"`           // Timeout of a remote call
+}
+
+// PricingConfig configures price conversion.
+type PricingConfig struct {
+	ExchangeRatesFile string `json:"exchangeRatesFile" yaml:"exchangeRatesFile"` // Fixed demo rates if empty
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			RequestTimeout:  Duration(60 * time.Second),
+			ShutdownTimeout: Duration(5 * time.Second),
+			SwaggerFile:     "pkg/docs/swagger.json",
+		},
+		Logging: LoggingConfig{
+			Level:            "info",
+			Format:           logging.FormatJSON,
+			SampleInitial:    100,
+			SampleThereafter: 100,
+		},
+		Debug: DebugConfig{
+			Addr:          "localhost:6060",
+			TraceDir:      "traces",
+			TraceMaxFiles: 10,
+
+			ProfileDir:           "profiles",
+			ProfileCPUDuration:   Duration(10 * time.Second),
+			ProfileMaxSnapshots:  24,
+			ProfileMutexFraction: 100,
+			ProfileBlockRate:     Duration(time.Millisecond),
+
+			WatchdogInterval: Duration(10 * time.Second),
+			WatchdogWindow:   6,
+			WatchdogMin:      1000,
+		},
+		Tracing: TracingConfig{
+			SampleRatio: 1,
+			ServiceName: "go-bottlenecks",
+		},
+		Search: SearchConfig{
+			DefaultItemCount: 10,
+			Backend:          BackendHeap,
+			Strategy:         StrategyWorkerPool,
+			EnrichWorkers:    10,
+			QdrantURL:        "http://localhost:6333",
+		},
+		Ads: AdsConfig{
+			DefaultSlots:   1,
+			MaxSlots:       5,
+			SlotInterval:   4,
+			EventsCapacity: 10_000,
+		},
+		Simulation: SimulationConfig{
+			ErrorRate:      util.DefaultErrorRate,
+			ReservationTTL: Duration(15 * time.Minute),
+		},
+		Services: ServicesConfig{
+			MaxIdleConns: 100,
+			Timeout:      Duration(5 * time.Second),
+		},
+	}
+}
+
+// Validate reports every invalid value in c.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(c.Server.RequestTimeout > 0, "server.requestTimeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+	check(c.Logging.Format == logging.FormatJSON || c.Logging.Format == logging.FormatText,
+		"logging.format %q must be %s or %s", c.Logging.Format, logging.FormatJSON, logging.FormatText)
+	check(c.Logging.SampleInitial >= 0, "logging.sampleInitial must not be negative")
+	check(c.Logging.SampleThereafter >= 0, "logging.sampleThereafter must not be negative")
+
+	check((c.Debug.Username == "") == (c.Debug.Password == ""), "debug.username and debug.password must be set together")
+	check(c.Debug.TraceDir != "" || (c.Debug.TraceOnStart == 0 && c.Debug.FlightRecorder == 0), "debug.traceDir must be set to trace")
+	check(c.Debug.TraceMaxFiles >= 0, "debug.traceMaxFiles must not be negative")
+	check(c.Debug.TraceOnStart >= 0, "debug.traceOnStart must not be negative")
+	check(c.Debug.FlightRecorder >= 0, "debug.flightRecorder must not be negative")
+	check(c.Debug.ProfileDir != "" || c.Debug.ProfileInterval == 0, "debug.profileDir must be set to profile")
+	check(c.Debug.ProfileInterval == 0 || c.Debug.ProfileInterval >= Duration(time.Second), "debug.profileInterval must be 0 or at least 1s")
+	check(c.Debug.ProfileCPUDuration > 0 && (c.Debug.ProfileInterval == 0 || c.Debug.ProfileCPUDuration <= c.Debug.ProfileInterval),
+		"debug.profileCpuDuration must be positive and at most debug.profileInterval")
+	check(c.Debug.ProfileMaxSnapshots >= 0, "debug.profileMaxSnapshots must not be negative")
+	check(c.Debug.ProfileMutexFraction >= 0, "debug.profileMutexFraction must not be negative")
+	check(c.Debug.ProfileBlockRate >= 0, "debug.profileBlockRate must not be negative")
+	check(c.Debug.WatchdogInterval >= 0, "debug.watchdogInterval must not be negative")
+	check(c.Debug.WatchdogWindow > 0, "debug.watchdogWindow must be positive")
+	check(c.Debug.WatchdogMin >= 0, "debug.watchdogMin must not be negative")
+
+	check(c.Tracing.OTLPEndpoint == "" || c.Tracing.File == "", "tracing.otlpEndpoint and tracing.file must not be set together")
+	if c.Tracing.OTLPEndpoint != "" {
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"tracing.otlpEndpoint %q must be an http:// or https:// URL", c.Tracing.OTLPEndpoint)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.serviceName must not be empty")
+
+	check(c.Search.DefaultItemCount > 0, "search.defaultItemCount must be positive")
+	check(slices.Contains(SearchBackends, c.Search.Backend), "search.backend %q must be one of %s", c.Search.Backend, strings.Join(SearchBackends, ", "))
+	check(slices.Contains(EnrichStrategies, c.Search.Strategy), "search.strategy %q must be one of %s", c.Search.Strategy, strings.Join(EnrichStrategies, ", "))
+	check(c.Search.EnrichWorkers > 0, "search.enrichWorkers must be positive")
+	if u, err := url.Parse(c.Search.QdrantURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check(false, "search.qdrantUrl %q must be an http:// or https:// URL", c.Search.QdrantURL)
+	}
+
+	check(c.Ads.MaxSlots >= 0, "ads.maxSlots must not be negative")
+	check(c.Ads.DefaultSlots >= 0 && c.Ads.DefaultSlots <= c.Ads.MaxSlots, "ads.defaultSlots must be between 0 and ads.maxSlots")
+	check(c.Ads.SlotInterval > 0, "ads.slotInterval must be positive")
+	check(c.Ads.EventsCapacity > 0, "ads.eventsCapacity must be positive")
+
+	check(c.Simulation.StockShards >= 0, "simulation.stockShards must not be negative")
+	check(!c.Simulation.Stateful || c.Services.ProductURL == "" || c.Services.StockURL == "",
+		"simulation.stateful has no effect with remote product and stock services, start cmd/productsvc and cmd/stocksvc with -stateful instead")
+	check(c.Simulation.ReservationTTL > 0, "simulation.reservationTtl must be positive")
+	check(c.Simulation.ErrorRate >= 0 && c.Simulation.ErrorRate <= 1, "simulation.errorRate must be between 0 and 1")
+	for _, l := range []struct{ name, spec string }{
+		{"productLatency", c.Simulation.ProductLatency},
+		{"stockLatency", c.Simulation.StockLatency},
+		{"adsLatency", c.Simulation.AdsLatency},
+	} {
+		if l.spec == "" {
+			continue
+		}
+		if _, err := util.ParseLatencyModel(l.spec); err != nil {
+			errs = append(errs, fmt.Errorf("simulation.%s: %w", l.name, err))
+		}
+	}
+
+	for _, s := range []struct{ name, raw string }{
+		{"productUrl", c.Services.ProductURL},
+		{"stockUrl", c.Services.StockURL},
+	} {
+		if s.raw == "" {
+			continue
+		}
+		u, err := url.Parse(s.raw)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "grpc") && u.Host != "",
+			"services.%s %q must be an http://, https:// or grpc:// URL", s.name, s.raw)
+	}
+	check(c.Services.MaxIdleConns >= 0, "services.maxIdleConns must not be negative")
+	check(c.Services.Timeout > 0, "services.timeout must be positive")
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of c with secrets masked, for display.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Debug.Password != "" {
+		redacted.Debug.Password = "REDACTED"
+	}
+	return &redacted
+}
+
+// Hash returns a short hash of the configuration, to tell apart profiles, benchmarks or logs of
+// differently configured runs. Secrets do not contribute.
+func (c *Config) Hash() string {
+	b, _ := json.Marshal(c.Redacted())
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Duration is a time.Duration that is written as a string like "250ms" in config files and fault rules.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set implements flag.Value.
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	return d.Set(s)
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	return d.Set(s)
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "server:\n  addr: :9000\n  requestTimeout: 30s\nsearch:\n  enrichWorkers: 4\nsimulation:\n  seed: 1\n"
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"BOTTLENECKS_CONFIG":                      path,
+		"BOTTLENECKS_SERVER_REQUEST_TIMEOUT":      "20s",
+		"BOTTLENECKS_SEARCH_ENRICH_WORKERS":       "8",
+		"BOTTLENECKS_SIMULATION_ERROR_RATE":       "0.2",
+		"BOTTLENECKS_SERVICES_PRODUCT_URL":        "grpc://localhost:9081",
+		"BOTTLENECKS_SIMULATION_STATEFUL":         "true",
+		"BOTTLENECKS_PRICING_EXCHANGE_RATES_FILE": "rates.json",
+	}
+
+	cfg, err := load("test", []string{"-enrich-workers=16", "-seed=42"}, lookupIn(env), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":9000" {
+		t.Fatalf("addr = %q, want the file's :9000", cfg.Server.Addr)
+	}
+	if time.Duration(cfg.Server.RequestTimeout) != 20*time.Second {
+		t.Fatalf("requestTimeout = %v, want the environment's 20s", cfg.Server.RequestTimeout)
+	}
+	if cfg.Search.EnrichWorkers != 16 || cfg.Simulation.Seed != 42 {
+		t.Fatalf("enrichWorkers = %d, seed = %d, want the flags' 16 and 42", cfg.Search.EnrichWorkers, cfg.Simulation.Seed)
+	}
+	if cfg.Simulation.ErrorRate != 0.2 || !cfg.Simulation.Stateful || cfg.Services.ProductURL != "grpc://localhost:9081" ||
+		cfg.Pricing.ExchangeRatesFile != "rates.json" {
+		t.Fatalf("environment not applied: %+v", cfg)
+	}
+	if cfg.Ads.MaxSlots != Default().Ads.MaxSlots {
+		t.Fatalf("maxSlots = %d, want the default", cfg.Ads.MaxSlots)
+	}
+}
+
+func TestLoadJSONFileFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"ads": {"maxSlots": 8, "defaultSlots": 2}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := load("test", []string{"-config", path}, lookupIn(nil), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Ads.MaxSlots != 8 || cfg.Ads.DefaultSlots != 2 {
+		t.Fatalf("ads = %+v", cfg.Ads)
+	}
+}
+
+func TestLoadRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	unknown := filepath.Join(dir, "unknown.yaml")
+	if err := os.WriteFile(unknown, []byte("server:\n  port: 8080\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown key", []string{"-config", unknown}, nil, "field port not found"},
+		{"bad env", nil, map[string]string{"BOTTLENECKS_SEARCH_ENRICH_WORKERS": "many"}, "BOTTLENECKS_SEARCH_ENRICH_WORKERS"},
+		{"bad flag", []string{"-request-timeout=soon"}, nil, "request-timeout"},
+		{"workers", []string{"-enrich-workers=0"}, nil, "search.enrichWorkers"},
+		{"strategy", []string{"-enrich-strategy=magic"}, nil, "search.strategy"},
+		{"backend", []string{"-search-backend=elastic"}, nil, "search.backend"},
+		{"qdrant url", []string{"-qdrant-url=localhost:6333"}, nil, "search.qdrantUrl"},
+		{"ad slots", []string{"-default-ad-slots=6"}, nil, "ads.defaultSlots"},
+		{"error rate", []string{"-error-rate=1.5"}, nil, "simulation.errorRate"},
+		{"reservation ttl", []string{"-reservation-ttl=0s"}, nil, "simulation.reservationTtl"},
+		{"latency", []string{"-stock-latency=gamma:1ms"}, nil, "simulation.stockLatency"},
+		{"url", []string{"-product-service-url=localhost:8081"}, nil, "services.productUrl"},
+		{"remote stateful", []string{"-stateful", "-product-service-url=http://localhost:8081", "-stock-service-url=http://localhost:8082"}, nil, "simulation.stateful"},
+		{"debug auth", []string{"-debug-user=admin"}, nil, "debug.username"},
+		{"mutex fraction", []string{"-profile-mutex-fraction=-1"}, nil, "debug.profileMutexFraction"},
+		{"block rate", []string{"-profile-block-rate=-1ms"}, nil, "debug.profileBlockRate"},
+		{"profile cpu duration", []string{"-profile-interval=5s", "-profile-cpu-duration=10s"}, nil, "debug.profileCpuDuration"},
+		{"watchdog window", []string{"-goroutine-watchdog-window=0"}, nil, "debug.watchdogWindow"},
+		{"log level", []string{"-log-level=loud"}, nil, "logging.level"},
+		{"log format", nil, map[string]string{"BOTTLENECKS_LOGGING_FORMAT": "xml"}, "logging.format"},
+		{"span sample ratio", []string{"-span-sample-ratio=2"}, nil, "tracing.sampleRatio"},
+		{"otlp endpoint", []string{"-otlp-endpoint=localhost:4318"}, nil, "tracing.otlpEndpoint"},
+		{"span exporters", []string{"-otlp-endpoint=http://localhost:4318", "-spans-file=stdout"}, nil, "tracing.file"},
+	}
+	for _, tt := range tests {
+		_, err := load("test", tt.args, lookupIn(tt.env), io.Discard)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: got error %v, want one mentioning %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg, err := load("test", []string{"-debug-addr=:7070", "-debug-user=admin"}, lookupIn(map[string]string{"BOTTLENECKS_DEBUG_PASSWORD": "secret"}), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Debug.Addr != ":7070" || cfg.Debug.Password != "secret" {
+		t.Fatalf("debug = %+v", cfg.Debug)
+	}
+	if got := cfg.Redacted().Debug.Password; got == "secret" || got == "" {
+		t.Fatalf("redacted password = %q", got)
+	}
+	if cfg.Debug.Password != "secret" {
+		t.Fatal("Redacted modified the original")
+	}
+}
+
+func TestHash(t *testing.T) {
+	a, b := Default(), Default()
+	if a.Hash() != b.Hash() || len(a.Hash()) != 12 {
+		t.Fatalf("hashes %q and %q of equal configurations", a.Hash(), b.Hash())
+	}
+	b.Search.Strategy = StrategyParallel
+	if a.Hash() == b.Hash() {
+		t.Fatal("hash ignores search.strategy")
+	}
+	b = Default()
+	b.Debug.Password = "secret"
+	if a.Hash() == b.Hash() {
+		t.Fatal("hash ignores whether a password is set")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for key, want := range map[string]string{"addr": "ADDR", "requestTimeout": "REQUEST_TIMEOUT", "productUrl": "PRODUCT_URL"} {
+		if got := envName(key); got != want {
+			t.Fatalf("envName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
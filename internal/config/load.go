@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes every environment variable read by Load. Variable names are derived from
+// the file keys, e.g. server.requestTimeout is BOTTLENECKS_SERVER_REQUEST_TIMEOUT.
+const EnvPrefix = "BOTTLENECKS_"
+
+// envConfigFile names the config file if -config is not given
+const envConfigFile = EnvPrefix + "CONFIG"
+
+// Load builds the configuration for the program name from, in increasing precedence: the defaults,
+// the YAML or JSON file given by -config or BOTTLENECKS_CONFIG, BOTTLENECKS_* environment variables
+// and the flags in args. The result is validated. For -h, flag.ErrHelp is returned after printing the usage.
+func Load(name string, args []string) (*Config, error) {
+	return load(name, args, os.LookupEnv, os.Stderr)
+}
+
+func load(name string, args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	// Flags are parsed twice: first only to find the config file, then again on top of the file
+	// and the environment so that only flags that were actually given override them
+	var path string
+	probe := newFlagSet(name, Default(), &path)
+	probe.SetOutput(io.Discard)
+	probe.Parse(args)
+	if path == "" {
+		path, _ = lookupEnv(envConfigFile)
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookupEnv); err != nil {
+		return nil, err
+	}
+
+	fs := newFlagSet(name, cfg, &path)
+	fs.SetOutput(output)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// newFlagSet binds the command-line flags to cfg. Flag defaults are the values already in cfg.
+func newFlagSet(name string, cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(path, "config", "", "YAML or JSON config file (also "+envConfigFile+")")
+
+	fs.StringVar(&cfg.Server.Addr, "addr", cfg.Server.Addr, "address to listen on")
+	fs.Var(&cfg.Server.RequestTimeout, "request-timeout", "per-request timeout")
+	fs.Var(&cfg.Server.ShutdownTimeout, "shutdown-timeout", "time given to in-flight requests on shutdown")
+	fs.StringVar(&cfg.Server.SwaggerFile, "swagger-file", cfg.Server.SwaggerFile, "swagger.json served at /swagger.json")
+	fs.StringVar(&cfg.Server.RecordFile, "record-requests", cfg.Server.RecordFile, "append method, path, query, status and latency of /api requests as JSON lines to this file, for cmd/replay (disabled if empty)")
+
+	fs.StringVar(&cfg.Logging.Level, "log-level", cfg.Logging.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&cfg.Logging.Format, "log-format", cfg.Logging.Format, "log format: json or text")
+	fs.IntVar(&cfg.Logging.SampleInitial, "log-sample-initial", cfg.Logging.SampleInitial, "lines per message and second logged before sampling starts (no sampling if 0)")
+	fs.IntVar(&cfg.Logging.SampleThereafter, "log-sample-thereafter", cfg.Logging.SampleThereafter, "then log every n-th line of a message (none if 0)")
+
+	fs.StringVar(&cfg.Debug.Addr, "debug-addr", cfg.Debug.Addr, "debug server (pprof, expvar, runtime metrics, trace controls) listen address (disabled if empty)")
+	fs.StringVar(&cfg.Debug.Username, "debug-user", cfg.Debug.Username, "basic auth user of the debug server (no auth if empty)")
+	fs.StringVar(&cfg.Debug.Password, "debug-password", cfg.Debug.Password, "basic auth password of the debug server (prefer "+EnvPrefix+"DEBUG_PASSWORD)")
+	fs.StringVar(&cfg.Debug.TraceDir, "trace-dir", cfg.Debug.TraceDir, "directory of runtime trace captures and flight recorder dumps")
+	fs.IntVar(&cfg.Debug.TraceMaxFiles, "trace-max-files", cfg.Debug.TraceMaxFiles, "number of trace files kept in -trace-dir (0 keeps all)")
+	fs.Var(&cfg.Debug.TraceOnStart, "trace", "capture a runtime trace for this long after startup, e.g. 30s (disabled if 0)")
+	fs.Var(&cfg.Debug.FlightRecorder, "flight-recorder", "keep this much recent runtime trace in memory for /debug/trace/flight, e.g. 10s (disabled if 0)")
+	fs.StringVar(&cfg.Debug.ProfileDir, "profile-dir", cfg.Debug.ProfileDir, "directory of continuous profiling snapshots, listed at /debug/profiles")
+	fs.Var(&cfg.Debug.ProfileInterval, "profile-interval", "capture CPU, heap, allocs, mutex, block and goroutine profiles this often, e.g. 5m (disabled if 0)")
+	fs.Var(&cfg.Debug.ProfileCPUDuration, "profile-cpu-duration", "length of each snapshot's CPU profile")
+	fs.IntVar(&cfg.Debug.ProfileMaxSnapshots, "profile-max-snapshots", cfg.Debug.ProfileMaxSnapshots, "number of profiling snapshots kept in -profile-dir (0 keeps all)")
+	fs.IntVar(&cfg.Debug.ProfileMutexFraction, "profile-mutex-fraction", cfg.Debug.ProfileMutexFraction, "while profiling, sample 1 in n mutex contention events (0 leaves the runtime default)")
+	fs.Var(&cfg.Debug.ProfileBlockRate, "profile-block-rate", "while profiling, sample one blocking event per this much time blocked, e.g. 1ms (0 leaves the runtime default)")
+	fs.Var(&cfg.Debug.WatchdogInterval, "goroutine-watchdog-interval", "count goroutines this often and log a suspected leak when the count keeps rising (disabled if 0)")
+	fs.IntVar(&cfg.Debug.WatchdogWindow, "goroutine-watchdog-window", cfg.Debug.WatchdogWindow, "consecutive rising goroutine counts that log a suspected leak")
+	fs.IntVar(&cfg.Debug.WatchdogMin, "goroutine-watchdog-min", cfg.Debug.WatchdogMin, "goroutine count below which the leak watchdog stays quiet")
+
+	fs.StringVar(&cfg.Tracing.OTLPEndpoint, "otlp-endpoint", cfg.Tracing.OTLPEndpoint, "export request spans to this OTLP/HTTP collector, e.g. http://localhost:4318 (disabled if empty)")
+	fs.StringVar(&cfg.Tracing.File, "spans-file", cfg.Tracing.File, "write request spans as JSON lines to this file, or stdout (disabled if empty)")
+	fs.Float64Var(&cfg.Tracing.SampleRatio, "span-sample-ratio", cfg.Tracing.SampleRatio, "share of new request traces that are recorded")
+	fs.StringVar(&cfg.Tracing.ServiceName, "service-name", cfg.Tracing.ServiceName, "service.name of exported spans")
+
+	fs.IntVar(&cfg.Search.DefaultItemCount, "default-item-count", cfg.Search.DefaultItemCount, "number of results returned if itemCount is missing")
+	fs.StringVar(&cfg.Search.Backend, "search-backend", cfg.Search.Backend, "search backend: "+strings.Join(SearchBackends, ", "))
+	fs.StringVar(&cfg.Search.Strategy, "enrich-strategy", cfg.Search.Strategy, "enrichment strategy: "+strings.Join(EnrichStrategies, ", "))
+	fs.IntVar(&cfg.Search.EnrichWorkers, "enrich-workers", cfg.Search.EnrichWorkers, "worker pool size of the worker-pool strategy")
+	fs.StringVar(&cfg.Search.QdrantURL, "qdrant-url", cfg.Search.QdrantURL, "base URL of the Qdrant server queried by the qdrant search backend")
+
+	fs.IntVar(&cfg.Ads.DefaultSlots, "default-ad-slots", cfg.Ads.DefaultSlots, "number of sponsored products if adSlots is missing")
+	fs.IntVar(&cfg.Ads.MaxSlots, "max-ad-slots", cfg.Ads.MaxSlots, "upper bound of adSlots")
+	fs.IntVar(&cfg.Ads.SlotInterval, "ad-slot-interval", cfg.Ads.SlotInterval, "number of organic results between two sponsored slots")
+	fs.IntVar(&cfg.Ads.EventsCapacity, "ad-events-capacity", cfg.Ads.EventsCapacity, "number of ad events kept in memory")
+	fs.StringVar(&cfg.Ads.EventsFile, "ad-events-file", cfg.Ads.EventsFile, "append ad events evicted from the in-memory log to this file (disabled if empty)")
+
+	fs.Int64Var(&cfg.Simulation.Seed, "seed", cfg.Simulation.Seed, "seed for the simulated services (0 picks a time-based seed)")
+	fs.BoolVar(&cfg.Simulation.Stateful, "stateful", cfg.Simulation.Stateful, "serve product and stock data from in-memory stores seeded from the search catalog")
+	fs.IntVar(&cfg.Simulation.StockShards, "stock-shards", cfg.Simulation.StockShards, "number of lock shards of the stateful stock store (0 uses a mutex per product)")
+	fs.Var(&cfg.Simulation.ReservationTTL, "reservation-ttl", "time after which a stock reservation that was neither committed nor released is released")
+	fs.Float64Var(&cfg.Simulation.ErrorRate, "error-rate", cfg.Simulation.ErrorRate, "probability that a call to a simulated service fails")
+	fs.StringVar(&cfg.Simulation.LatencyFile, "latency-config", cfg.Simulation.LatencyFile, "JSON file mapping simulated services (product, stock, ads) to latency specs")
+	fs.StringVar(&cfg.Simulation.ProductLatency, "product-latency", cfg.Simulation.ProductLatency, "latency model of the product service, e.g. lognormal:15ms,0.6 (overrides -latency-config)")
+	fs.StringVar(&cfg.Simulation.StockLatency, "stock-latency", cfg.Simulation.StockLatency, "latency model of the stock service, e.g. pareto:5ms,1.8 (overrides -latency-config)")
+	fs.StringVar(&cfg.Simulation.AdsLatency, "ads-latency", cfg.Simulation.AdsLatency, "latency model of the ads service, e.g. uniform:0ms,40ms (overrides -latency-config)")
+
+	fs.StringVar(&cfg.Services.ProductURL, "product-service-url", cfg.Services.ProductURL, "call the product service at this URL instead of in-process, e.g. http://localhost:8081 or grpc://localhost:9081")
+	fs.StringVar(&cfg.Services.StockURL, "stock-service-url", cfg.Services.StockURL, "call the stock service at this URL instead of in-process, e.g. http://localhost:8082 or grpc://localhost:9082")
+	fs.IntVar(&cfg.Services.MaxIdleConns, "service-max-idle-conns", cfg.Services.MaxIdleConns, "idle HTTP connections kept per remote service")
+	fs.Var(&cfg.Services.Timeout, "service-timeout", "timeout of a remote service call")
+
+	fs.StringVar(&cfg.Pricing.ExchangeRatesFile, "exchange-rates", cfg.Pricing.ExchangeRatesFile, "JSON file with the exchange-rate table used to show prices in other currencies (fixed demo rates if empty)")
+	return fs
+}
+
+// loadFile decodes a YAML (.yaml, .yml) or JSON file into cfg, rejecting unknown keys.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if err == io.EOF {
+			err = nil // empty file
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s: extension must be .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// applyEnv overrides the fields of the struct v from environment variables named prefix_KEY,
+// where KEY is the field's yaml key in upper snake case.
+func applyEnv(v reflect.Value, prefix string, lookupEnv func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := prefix + "_" + envName(strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name, lookupEnv); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := lookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("%s=%q: %w", name, raw, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if field.Type() == durationType {
+		return field.Addr().Interface().(*Duration).Set(raw)
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// envName converts a camelCase key to upper snake case, e.g. requestTimeout to REQUEST_TIMEOUT.
+func envName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+// Package debugserver serves profiling and diagnostics endpoints on a listener of their own, so that
+// they can be bound to localhost or protected with basic auth independently of the API.
+//
+//	/debug/pprof/   net/http/pprof (go tool pprof http://localhost:6060/debug/pprof/heap)
+//	/debug/vars     expvar
+//	/debug/metrics  runtime/metrics as JSON
+//	/admin/         state changing endpoints registered with Options.Admin
+package debugserver
+
+import (
+	"encoding/json"
+	"expvar"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime/metrics"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Options configures the debug handler.
+type Options struct {
+	Username string // Basic auth user (no auth if empty)
+	Password string // Basic auth password
+
+	// Routes registers additional endpoints under /debug, e.g. the trace controls of the API server
+	Routes func(r chi.Router)
+	// Admin registers endpoints under /admin, e.g. the fault injection and store updates of the API server
+	Admin func(r chi.Router)
+}
+
+// Handler returns the debug mux.
+func Handler(opts Options) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	if opts.Username != "" {
+		r.Use(middleware.BasicAuth("debug", map[string]string{opts.Username: opts.Password}))
+	}
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/debug/pprof/", http.StatusFound)
+	})
+	r.Route("/debug", func(r chi.Router) {
+		// pprof.Index serves the named profiles (heap, goroutine, ...) below /debug/pprof/
+		r.HandleFunc("/pprof/*", pprof.Index)
+		r.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+		r.HandleFunc("/pprof/profile", pprof.Profile)
+		r.HandleFunc("/pprof/symbol", pprof.Symbol)
+		r.HandleFunc("/pprof/trace", pprof.Trace)
+
+		r.Handle("/vars", expvar.Handler())
+		r.Get("/metrics", handleRuntimeMetrics)
+
+		if opts.Routes != nil {
+			opts.Routes(r)
+		}
+	})
+	if opts.Admin != nil {
+		r.Route("/admin", opts.Admin)
+	}
+	return r
+}
+
+// New returns an http.Server serving Handler(opts) on addr. Profiles and traces stream for as long
+// as requested, so the server has no write timeout.
+func New(addr string, opts Options) *http.Server {
+	return &http.Server{Addr: addr, Handler: Handler(opts)}
+}
+
+// histogram summarizes a runtime/metrics histogram. Quantiles are bucket upper bounds.
+type histogram struct {
+	Count uint64  `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// handleRuntimeMetrics returns every supported runtime/metrics value keyed by its name.
+func handleRuntimeMetrics(w http.ResponseWriter, r *http.Request) {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i, d := range descs {
+		samples[i].Name = d.Name
+	}
+	metrics.Read(samples)
+
+	values := make(map[string]any, len(samples))
+	for _, s := range samples {
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			values[s.Name] = s.Value.Uint64()
+		case metrics.KindFloat64:
+			values[s.Name] = finite(s.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			values[s.Name] = summarize(s.Value.Float64Histogram())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(values)
+}
+
+func summarize(h *metrics.Float64Histogram) histogram {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	s := histogram{Count: total}
+	if total == 0 {
+		return s
+	}
+
+	// Counts[i] falls between Buckets[i] and Buckets[i+1]
+	cumulative := make([]uint64, len(h.Counts))
+	var seen uint64
+	for i, c := range h.Counts {
+		seen += c
+		cumulative[i] = seen
+	}
+	quantile := func(q float64) float64 {
+		rank := uint64(math.Ceil(q * float64(total)))
+		i := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] >= rank })
+		return bound(h, i)
+	}
+	s.P50, s.P90, s.P99 = quantile(0.5), quantile(0.9), quantile(0.99)
+	for i := len(h.Counts) - 1; i >= 0; i-- {
+		if h.Counts[i] > 0 {
+			s.Max = bound(h, i)
+			break
+		}
+	}
+	return s
+}
+
+// bound returns the upper bound of bucket i, or its lower bound if the upper one is infinite.
+func bound(h *metrics.Float64Histogram, i int) float64 {
+	if upper := h.Buckets[i+1]; !math.IsInf(upper, 0) {
+		return upper
+	}
+	return finite(h.Buckets[i])
+}
+
+// finite maps values JSON cannot encode to 0.
+func finite(f float64) float64 {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,79 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func get(t *testing.T, h http.Handler, path, user, pass string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEndpoints(t *testing.T) {
+	h := Handler(Options{Routes: func(r chi.Router) {
+		r.Get("/extra", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	}})
+
+	for path, want := range map[string]int{
+		"/debug/pprof/":                    http.StatusOK,
+		"/debug/pprof/goroutine?debug=1":   http.StatusOK,
+		"/debug/pprof/cmdline":             http.StatusOK,
+		"/debug/vars":                      http.StatusOK,
+		"/debug/metrics":                   http.StatusOK,
+		"/debug/extra":                     http.StatusTeapot,
+		"/debug/pprof/no-such-profile":     http.StatusNotFound,
+		"/api/search?term=not-on-this-mux": http.StatusNotFound,
+	} {
+		if got := get(t, h, path, "", "").Code; got != want {
+			t.Errorf("GET %s = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	rec := get(t, Handler(Options{}), "/debug/metrics", "", "")
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &values); err != nil {
+		t.Fatal(err)
+	}
+	var goroutines uint64
+	if err := json.Unmarshal(values["/sched/goroutines:goroutines"], &goroutines); err != nil || goroutines == 0 {
+		t.Fatalf("goroutines = %s, %v", values["/sched/goroutines:goroutines"], err)
+	}
+	var pauses histogram
+	if err := json.Unmarshal(values["/sched/pauses/total/gc:seconds"], &pauses); err != nil {
+		t.Fatalf("gc pauses = %s, %v", values["/sched/pauses/total/gc:seconds"], err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	h := Handler(Options{Username: "admin", Password: "secret", Admin: func(r chi.Router) {
+		r.Get("/faults", func(w http.ResponseWriter, r *http.Request) {})
+	}})
+	if got := get(t, h, "/admin/faults", "", "").Code; got != http.StatusUnauthorized {
+		t.Fatalf("admin without credentials: %d, want 401", got)
+	}
+	if got := get(t, h, "/admin/faults", "admin", "secret").Code; got != http.StatusOK {
+		t.Fatalf("admin with valid credentials: %d, want 200", got)
+	}
+	if got := get(t, h, "/debug/pprof/", "", "").Code; got != http.StatusUnauthorized {
+		t.Fatalf("no credentials: %d, want 401", got)
+	}
+	if got := get(t, h, "/debug/pprof/", "admin", "wrong").Code; got != http.StatusUnauthorized {
+		t.Fatalf("wrong password: %d, want 401", got)
+	}
+	if got := get(t, h, "/debug/pprof/", "admin", "secret").Code; got != http.StatusOK {
+		t.Fatalf("valid credentials: %d, want 200", got)
+	}
+}
@@ -0,0 +1,242 @@
+// Package fault injects scripted failures into the simulated services so incidents can be
+// rehearsed against /api/search while it is under load. Rules are matched per service and
+// optionally per product ID, and can be added or removed at runtime.
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+// Kind is the type of fault a rule injects.
+type Kind string
+
+const (
+	// KindError makes the call fail immediately.
+	KindError Kind = "error"
+	// KindTimeout makes the call hang for Delay, or until its context is done, and then fail with ErrTimeout.
+	KindTimeout Kind = "timeout"
+	// KindSlow adds Delay to the call, which then succeeds unless its context is done first.
+	KindSlow Kind = "slow"
+	// KindPanic makes the call panic. The panic is recovered where the fault is injected and
+	// returned as an error wrapping ErrPanic, because calls are made from enrichment worker
+	// goroutines where an unrecovered panic would crash the whole server.
+	KindPanic Kind = "panic"
+)
+
+var (
+	// ErrInjected is wrapped by every error returned because of a fault rule.
+	ErrInjected = errors.New("fault injected")
+	// ErrTimeout is wrapped by errors returned by timeout rules.
+	ErrTimeout = errors.New("timeout")
+	// ErrPanic is wrapped by errors returned by panic rules.
+	ErrPanic = errors.New("panic")
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Brownout makes a rule active for On, then inactive for Off, repeating from the moment the rule was added.
+type Brownout struct {
+	On  config.Duration `json:"on"`
+	Off config.Duration `json:"off"`
+}
+
+// Rule describes one fault. Service is one of ServiceProduct, ServiceStock and ServiceAds, or "" or "*" to match
+// every service; an empty ProductIDs matches every product.
+type Rule struct {
+	ID          string          `json:"id"`
+	Service     string          `json:"service"`
+	ProductIDs  []int           `json:"productIds,omitempty"`
+	Kind        Kind            `json:"kind"`
+	Probability float64         `json:"probability"`
+	Delay       config.Duration `json:"delay,omitempty"`
+	Message     string          `json:"message,omitempty"`
+	Brownout    *Brownout       `json:"brownout,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+func (r *Rule) validate() error {
+	switch r.Service {
+	case "", "*", ServiceProduct, ServiceStock, ServiceAds:
+	default:
+		// A misspelled service would match nothing, and the fault would silently never fire
+		return fmt.Errorf("unknown service %q", r.Service)
+	}
+	switch r.Kind {
+	case KindError, KindTimeout, KindSlow, KindPanic:
+	default:
+		return fmt.Errorf("unknown fault kind %q", r.Kind)
+	}
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability must be between 0 and 1")
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	if r.Kind == KindSlow && r.Delay == 0 {
+		return fmt.Errorf("slow faults need a delay")
+	}
+	if r.Brownout != nil && (r.Brownout.On <= 0 || r.Brownout.Off < 0) {
+		return fmt.Errorf("brownout needs a positive on duration and a non-negative off duration")
+	}
+	return nil
+}
+
+// active reports whether the rule's brownout window is currently on.
+func (r *Rule) active(now time.Time) bool {
+	if r.Brownout == nil {
+		return true
+	}
+	period := time.Duration(r.Brownout.On + r.Brownout.Off)
+	return now.Sub(r.CreatedAt)%period < time.Duration(r.Brownout.On)
+}
+
+func (r *Rule) matches(service string, productIDs []int) bool {
+	if r.Service != "" && r.Service != "*" && r.Service != service {
+		return false
+	}
+	if len(r.ProductIDs) == 0 {
+		return true
+	}
+	for _, want := range r.ProductIDs {
+		for _, id := range productIDs {
+			if id == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Injector holds the active fault rules. A nil *Injector injects nothing.
+type Injector struct {
+	mu     sync.RWMutex
+	rules  []Rule
+	nextID int
+	rng    *rand.Rand
+}
+
+// NewInjector creates an Injector without rules drawing from rng (the default RNG if nil).
+func NewInjector(rng *rand.Rand) *Injector {
+	return &Injector{rng: util.OrDefaultRand(rng)}
+}
+
+// Add validates and activates a rule, returning it with its assigned ID.
+func (in *Injector) Add(r Rule) (Rule, error) {
+	if err := r.validate(); err != nil {
+		return Rule{}, err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.nextID++
+	r.ID = strconv.Itoa(in.nextID)
+	r.CreatedAt = time.Now()
+	in.rules = append(in.rules, r)
+	return r, nil
+}
+
+// Remove deletes the rule with the given ID and reports whether it existed.
+func (in *Injector) Remove(id string) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for i, r := range in.rules {
+		if r.ID == id {
+			in.rules = append(in.rules[:i], in.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all rules.
+func (in *Injector) Clear() {
+	in.mu.Lock()
+	in.rules = nil
+	in.mu.Unlock()
+}
+
+// Rules returns a copy of the active rules.
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule{}, in.rules...)
+}
+
+// Inject applies every matching rule to a call of service for the given product IDs.
+// Slow rules add their delay; the first triggered error, timeout or panic rule ends the call.
+// Delays end early with the context's error when ctx is done.
+func (in *Injector) Inject(ctx context.Context, service string, productIDs ...int) (err error) {
+	if in == nil {
+		return nil
+	}
+	in.mu.RLock()
+	if len(in.rules) == 0 {
+		in.mu.RUnlock()
+		return nil
+	}
+	now := time.Now()
+	var triggered []Rule
+	for _, r := range in.rules {
+		if r.matches(service, productIDs) && r.active(now) && in.rng.Float64() < r.Probability {
+			triggered = append(triggered, r)
+		}
+	}
+	in.mu.RUnlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%w: %s: %w: %v", ErrInjected, service, ErrPanic, p)
+		}
+	}()
+	for _, r := range triggered {
+		switch r.Kind {
+		case KindSlow:
+			if err := sleep(ctx, time.Duration(r.Delay)); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrInjected, service, err)
+			}
+		case KindError:
+			return fmt.Errorf("%w: %s: %s", ErrInjected, service, r.message("error"))
+		case KindTimeout:
+			delay := time.Duration(r.Delay)
+			if delay == 0 {
+				delay = defaultTimeout
+			}
+			if err := sleep(ctx, delay); err != nil {
+				return fmt.Errorf("%w: %s: %w: %w", ErrInjected, service, ErrTimeout, err)
+			}
+			return fmt.Errorf("%w: %s: %w after %s", ErrInjected, service, ErrTimeout, delay)
+		case KindPanic:
+			panic(r.message("panic"))
+		}
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is done, whichever comes first, and returns ctx.Err() in the latter case.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Rule) message(fallback string) string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return fallback
+}
@@ -0,0 +1,110 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+func TestInjectMatchesServiceAndProduct(t *testing.T) {
+	in := NewInjector(util.NewRand(1))
+	if _, err := in.Add(Rule{Service: ServiceStock, ProductIDs: []int{42}, Kind: KindError, Probability: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := in.Inject(context.Background(), ServiceStock, 42); !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected injected error for stock/42, got %v", err)
+	}
+	if err := in.Inject(context.Background(), ServiceStock, 7); err != nil {
+		t.Fatalf("expected no error for stock/7, got %v", err)
+	}
+	if err := in.Inject(context.Background(), ServiceProduct, 42); err != nil {
+		t.Fatalf("expected no error for product/42, got %v", err)
+	}
+}
+
+func TestInjectTimeoutAndRemove(t *testing.T) {
+	in := NewInjector(util.NewRand(1))
+	rule, err := in.Add(Rule{Service: "*", Kind: KindTimeout, Probability: 1, Delay: config.Duration(time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Inject(context.Background(), ServiceAds); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	if !in.Remove(rule.ID) {
+		t.Fatal("expected rule to be removed")
+	}
+	if err := in.Inject(context.Background(), ServiceAds); err != nil {
+		t.Fatalf("expected no error after removal, got %v", err)
+	}
+}
+
+func TestInjectPanicIsRecovered(t *testing.T) {
+	in := NewInjector(util.NewRand(1))
+	if _, err := in.Add(Rule{Service: ServiceProduct, Kind: KindPanic, Probability: 1, Message: "nil map"}); err != nil {
+		t.Fatal(err)
+	}
+	// The panic would crash the test binary if it escaped the worker goroutine
+	errs := make(chan error)
+	go func() { errs <- in.Inject(context.Background(), ServiceProduct, 1) }()
+	if err := <-errs; !errors.Is(err, ErrPanic) || !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected a recovered panic, got %v", err)
+	}
+}
+
+func TestInjectDelaysEndWithContext(t *testing.T) {
+	in := NewInjector(util.NewRand(1))
+	for _, kind := range []Kind{KindSlow, KindTimeout} {
+		in.Clear()
+		if _, err := in.Add(Rule{Kind: kind, Probability: 1, Delay: config.Duration(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start := time.Now()
+		err := in.Inject(ctx, ServiceStock, 1)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+			t.Errorf("%s: got %v after %s, want the context's deadline", kind, err, time.Since(start))
+		}
+	}
+}
+
+func TestRuleBrownout(t *testing.T) {
+	created := time.Now()
+	r := Rule{Brownout: &Brownout{On: config.Duration(time.Second), Off: config.Duration(2 * time.Second)}, CreatedAt: created}
+
+	for _, tt := range []struct {
+		offset time.Duration
+		want   bool
+	}{
+		{0, true},
+		{500 * time.Millisecond, true},
+		{1500 * time.Millisecond, false},
+		{3200 * time.Millisecond, true},
+	} {
+		if got := r.active(created.Add(tt.offset)); got != tt.want {
+			t.Fatalf("active at +%s = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestAddRejectsInvalidRules(t *testing.T) {
+	in := NewInjector(nil)
+	for _, r := range []Rule{
+		{Kind: "explode", Probability: 1},
+		{Service: "stocks", Kind: KindError, Probability: 1},
+		{Kind: KindError, Probability: 2},
+		{Kind: KindSlow, Probability: 1},
+		{Kind: KindError, Probability: 1, Brownout: &Brownout{}},
+	} {
+		if _, err := in.Add(r); err == nil {
+			t.Fatalf("expected %+v to be rejected", r)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package fault
+
+import (
+	"context"
+
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+)
+
+// Service names used when matching rules
+const (
+	ServiceProduct = "product"
+	ServiceStock   = "stock"
+	ServiceAds     = "ads"
+)
+
+type productService struct {
+	next product.ProductService
+	in   *Injector
+}
+
+// WrapProductService returns a ProductService that applies the injector's "product" rules before calling next.
+// It is a product.BatchProductService, which fetches a batch one by one if next cannot.
+func WrapProductService(next product.ProductService, in *Injector) product.BatchProductService {
+	return &productService{next: next, in: in}
+}
+
+func (s *productService) GetProductByID(ctx context.Context, id int) (*product.Product, error) {
+	if err := s.in.Inject(ctx, ServiceProduct, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetProductByID(ctx, id)
+}
+
+// GetProductsByIDs applies the rules once for the whole batch, so a rule for any of ids fails all of them.
+func (s *productService) GetProductsByIDs(ctx context.Context, ids []int) ([]*product.Product, error) {
+	if err := s.in.Inject(ctx, ServiceProduct, ids...); err != nil {
+		return nil, err
+	}
+	return product.GetProducts(ctx, s.next, ids)
+}
+
+type stockService struct {
+	next stock.StockService
+	in   *Injector
+}
+
+// WrapStockService returns a StockService that applies the injector's "stock" rules before calling next.
+// It is a stock.BatchStockService, which fetches a batch one by one if next cannot.
+func WrapStockService(next stock.StockService, in *Injector) stock.BatchStockService {
+	return &stockService{next: next, in: in}
+}
+
+func (s *stockService) GetStockByProductID(ctx context.Context, id int) (*stock.Stock, error) {
+	if err := s.in.Inject(ctx, ServiceStock, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetStockByProductID(ctx, id)
+}
+
+// GetStocksByProductIDs applies the rules once for the whole batch, so a rule for any of ids fails all of them.
+func (s *stockService) GetStocksByProductIDs(ctx context.Context, ids []int) ([]*stock.Stock, error) {
+	if err := s.in.Inject(ctx, ServiceStock, ids...); err != nil {
+		return nil, err
+	}
+	return stock.GetStocks(ctx, s.next, ids)
+}
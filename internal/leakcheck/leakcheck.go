@@ -0,0 +1,245 @@
+// Package leakcheck finds goroutines that outlive the code that started them, such as a sender
+// blocked forever on a channel nobody reads after a timeout (see pkg/example/example5).
+//
+// Tests call Check, or VerifyTestMain for a whole package; long-running servers start a Watchdog,
+// which reports when the number of goroutines keeps growing.
+package leakcheck
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Goroutine is one goroutine of a stack dump.
+type Goroutine struct {
+	ID        int64
+	State     string // e.g. "chan send" or "select, 2 minutes"
+	Function  string // Function the goroutine is in, e.g. "time.Sleep"
+	CreatedBy string // Function that started the goroutine, empty for main
+	Stack     string // Full stack as printed by the runtime
+}
+
+func (g Goroutine) String() string { return g.Stack }
+
+// functions returns every function on the goroutine's stack, innermost first, and its creator.
+func (g Goroutine) functions() []string {
+	var fns []string
+	for _, line := range strings.Split(g.Stack, "\n")[1:] {
+		if line == "" || line[0] == '\t' {
+			continue
+		}
+		fn := strings.TrimPrefix(line, "created by ")
+		if i := strings.Index(fn, " in goroutine "); i >= 0 {
+			fn = fn[:i]
+		}
+		fns = append(fns, trimArgs(fn))
+	}
+	return fns
+}
+
+// trimArgs removes the argument list from a stack frame such as main.f(0x1, 0x2).
+func trimArgs(frame string) string {
+	if i := strings.LastIndex(frame, "("); i > 0 && strings.HasSuffix(frame, ")") {
+		return frame[:i]
+	}
+	return frame
+}
+
+// All returns every goroutine except the calling one.
+func All() []Goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	blocks := bytes.Split(bytes.TrimSpace(buf), []byte("\n\n"))
+	// The calling goroutine comes first
+	gs := make([]Goroutine, 0, len(blocks))
+	for _, block := range blocks[1:] {
+		if g, ok := parse(string(block)); ok {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// parse parses one goroutine of a runtime.Stack dump:
+//
+//	goroutine 7 [chan send]:
+//	main.f(...)
+//		/src/main.go:12 +0x2c
+//	created by main.main in goroutine 1
+//		/src/main.go:8 +0x25
+func parse(block string) (Goroutine, bool) {
+	header, rest, _ := strings.Cut(block, "\n")
+	header, ok := strings.CutPrefix(header, "goroutine ")
+	if !ok {
+		return Goroutine{}, false
+	}
+	idStr, state, _ := strings.Cut(header, " ")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return Goroutine{}, false
+	}
+	g := Goroutine{ID: id, State: strings.TrimSuffix(strings.TrimPrefix(state, "["), "]:"), Stack: block}
+	for _, line := range strings.Split(rest, "\n") {
+		if line == "" || line[0] == '\t' {
+			continue
+		}
+		if creator, ok := strings.CutPrefix(line, "created by "); ok {
+			if i := strings.Index(creator, " in goroutine "); i >= 0 {
+				creator = creator[:i]
+			}
+			g.CreatedBy = creator
+		} else if g.Function == "" {
+			g.Function = trimArgs(line)
+		}
+	}
+	return g, true
+}
+
+// Goroutines of the runtime and the testing package that come and go on their own
+var defaultIgnores = []string{
+	"testing.(*T).Run",
+	"testing.(*T).Parallel",
+	"testing.runTests",
+	"testing.(*M).Run",
+	"testing.(*F).Fuzz",
+	"testing.tRunner",
+	"testing.runFuzzTests",
+	"testing.(*B).run1",
+	"testing.(*B).doBench",
+	"testing.(*B).RunParallel",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"runtime/trace.Start.func1",
+}
+
+type options struct {
+	timeout  time.Duration
+	ignore   []string
+	creators []string
+}
+
+// Option configures Check, Leaked and VerifyTestMain.
+type Option func(*options)
+
+// Timeout sets how long goroutines are given to exit before they count as leaked (2s by default).
+func Timeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// IgnoreFunction ignores goroutines with fn anywhere on their stack or as their creator, e.g.
+// a background loop that is meant to run for the whole process.
+func IgnoreFunction(fn string) Option {
+	return func(o *options) { o.ignore = append(o.ignore, fn) }
+}
+
+// IgnoreCreatedBy ignores goroutines started by functions whose name begins with prefix, whatever
+// they are blocked in, e.g. the workers of a package that runs them for the whole process.
+func IgnoreCreatedBy(prefix string) Option {
+	return func(o *options) { o.creators = append(o.creators, prefix) }
+}
+
+func newOptions(opts []Option) options {
+	o := options{timeout: 2 * time.Second, ignore: slices.Clone(defaultIgnores)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o options) ignored(g Goroutine) bool {
+	for _, prefix := range o.creators {
+		if strings.HasPrefix(g.CreatedBy, prefix) {
+			return true
+		}
+	}
+	for _, fn := range g.functions() {
+		if slices.Contains(o.ignore, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is the set of goroutines running at some point, see Take.
+type Snapshot map[int64]bool
+
+// Take records the running goroutines, so that Leaked can tell which ones were started later.
+func Take() Snapshot {
+	s := Snapshot{}
+	for _, g := range All() {
+		s[g.ID] = true
+	}
+	return s
+}
+
+// Leaked waits up to the timeout for the goroutines started since s was taken to exit and returns
+// those that did not.
+func (s Snapshot) Leaked(opts ...Option) []Goroutine {
+	o := newOptions(opts)
+	deadline := time.Now().Add(o.timeout)
+	for delay := time.Millisecond; ; delay = min(2*delay, 100*time.Millisecond) {
+		var leaked []Goroutine
+		for _, g := range All() {
+			if !s[g.ID] && !o.ignored(g) {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(delay)
+	}
+}
+
+// Report formats leaked goroutines with their stacks.
+func Report(leaked []Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d leaked goroutine(s):", len(leaked))
+	for _, g := range leaked {
+		b.WriteString("\n\n")
+		b.WriteString(g.Stack)
+	}
+	return b.String()
+}
+
+// Check fails t if goroutines started after the call are still running when t and its cleanups
+// are done. Call it first in a test, before starting anything that may clean up after itself later.
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	s := Take()
+	t.Cleanup(func() {
+		if leaked := s.Leaked(opts...); len(leaked) > 0 {
+			t.Error(Report(leaked))
+		}
+	})
+}
+
+// VerifyTestMain runs the tests and benchmarks of m and fails if they leave goroutines behind:
+//
+//	func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	s := Take()
+	code := m.Run()
+	if code == 0 {
+		if leaked := s.Leaked(opts...); len(leaked) > 0 {
+			fmt.Fprintln(os.Stderr, "leakcheck:", Report(leaked))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,114 @@
+package leakcheck
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func blockedSender(ch chan int) { ch <- 1 }
+
+func TestLeaked(t *testing.T) {
+	s := Take()
+	ch := make(chan int)
+	go blockedSender(ch)
+	go func() { time.Sleep(20 * time.Millisecond) }()
+
+	leaked := s.Leaked(Timeout(200 * time.Millisecond))
+	if len(leaked) != 1 {
+		t.Fatalf("leaked = %v, want the blocked sender", leaked)
+	}
+	g := leaked[0]
+	if g.Function != "github.com/idagdelen/go-bottlenecks/internal/leakcheck.blockedSender" || g.State != "chan send" ||
+		!strings.HasSuffix(g.CreatedBy, "leakcheck.TestLeaked") {
+		t.Errorf("leaked goroutine = %+v", g)
+	}
+	if r := Report(leaked); !strings.HasPrefix(r, "1 leaked goroutine(s):") || !strings.Contains(r, "blockedSender") {
+		t.Errorf("report = %q", r)
+	}
+
+	if leaked := s.Leaked(Timeout(0), IgnoreFunction("github.com/idagdelen/go-bottlenecks/internal/leakcheck.blockedSender")); len(leaked) != 0 {
+		t.Errorf("ignored goroutine reported: %v", leaked)
+	}
+	<-ch
+	if leaked := s.Leaked(); len(leaked) != 0 {
+		t.Errorf("released goroutine reported: %v", leaked)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	Check(t)
+	done := make(chan bool)
+	go func() { done <- true }()
+	<-done
+}
+
+func TestParse(t *testing.T) {
+	g, ok := parse(`goroutine 7 [chan send, 2 minutes]:
+main.f(0xc000012345)
+	/src/main.go:12 +0x2c
+created by main.main in goroutine 1
+	/src/main.go:8 +0x25`)
+	if !ok || g.ID != 7 || g.State != "chan send, 2 minutes" || g.Function != "main.f" || g.CreatedBy != "main.main" {
+		t.Fatalf("parse = %+v, %v", g, ok)
+	}
+	if fns := g.functions(); len(fns) != 2 || fns[0] != "main.f" || fns[1] != "main.main" {
+		t.Errorf("functions = %v", fns)
+	}
+}
+
+func TestWatchdogAlertsOnSteadyGrowth(t *testing.T) {
+	var alerts []Alert
+	w := &Watchdog{opts: WatchdogOptions{Window: 3, Min: 10, Top: 1, OnAlert: func(a Alert) { alerts = append(alerts, a) }}}
+
+	counts := []int{5}
+	for _, n := range []int{6, 7, 8, 9, 9, 10, 11, 12} {
+		counts = w.observe(counts, n)
+	}
+	// 5..9 rose for a whole window but stayed below Min; 9..12 alerts
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	if c := alerts[0].Counts; len(c) != 4 || c[0] != 9 || c[3] != 12 {
+		t.Errorf("counts = %v", c)
+	}
+	if len(alerts[0].Groups) != 1 || alerts[0].Groups[0].Count == 0 {
+		t.Errorf("groups = %+v", alerts[0].Groups)
+	}
+	if len(counts) != 1 {
+		t.Errorf("run not restarted after alert: %v", counts)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	groups := Groups([]Goroutine{
+		{Function: "a", CreatedBy: "x", State: "chan send"},
+		{Function: "b", CreatedBy: "x", State: "select"},
+		{Function: "a", CreatedBy: "x", State: "chan send, 3 minutes"},
+	})
+	if len(groups) != 2 || groups[0] != (Group{Function: "a", CreatedBy: "x", State: "chan send", Count: 2}) {
+		t.Errorf("groups = %+v", groups)
+	}
+}
+
+func TestWatchdogStop(t *testing.T) {
+	Check(t)
+	w := StartWatchdog(WatchdogOptions{Interval: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	w.Stop()
+	w.Stop()
+}
+
+func TestIgnoreCreatedBy(t *testing.T) {
+	s := Take()
+	ch := make(chan int)
+	go blockedSender(ch)
+	defer func() { <-ch }()
+
+	if leaked := s.Leaked(Timeout(0), IgnoreCreatedBy("github.com/idagdelen/go-bottlenecks/internal/leakcheck.")); len(leaked) != 0 {
+		t.Errorf("ignored goroutine reported: %v", leaked)
+	}
+	if leaked := s.Leaked(Timeout(0), IgnoreCreatedBy("net/http.")); len(leaked) != 1 {
+		t.Errorf("leaked = %v", leaked)
+	}
+}
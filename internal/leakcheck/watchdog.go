@@ -0,0 +1,119 @@
+package leakcheck
+
+import (
+	"cmp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchdogOptions configure a Watchdog.
+type WatchdogOptions struct {
+	Interval time.Duration // Time between two goroutine counts
+	Window   int           // Consecutive rising counts that raise an alert (6 if 0)
+	Min      int           // Counts below this never alert, as a busy server legitimately grows to some size
+	Top      int           // Goroutine groups reported with an alert (10 if 0)
+	OnAlert  func(Alert)   // Called from the watchdog's goroutine
+}
+
+// Alert reports a goroutine count that rose for a whole window.
+type Alert struct {
+	Counts []int   // Goroutine counts of the window, oldest first
+	Groups []Group // Largest groups of goroutines at the time of the alert
+}
+
+// Group counts goroutines that are in the same function and were started by the same one, which is
+// usually where a leak shows: thousands of goroutines blocked in one chan send.
+type Group struct {
+	Function  string `json:"function"`
+	CreatedBy string `json:"createdBy"`
+	State     string `json:"state"`
+	Count     int    `json:"count"`
+}
+
+// Groups groups gs by function, creator and state, largest first.
+func Groups(gs []Goroutine) []Group {
+	index := map[Group]int{}
+	var groups []Group
+	for _, g := range gs {
+		// Drop the wait time in states like "chan send, 5 minutes"
+		state, _, _ := strings.Cut(g.State, ",")
+		key := Group{Function: g.Function, CreatedBy: g.CreatedBy, State: state}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, key)
+		}
+		groups[i].Count++
+	}
+	slices.SortStableFunc(groups, func(a, b Group) int { return cmp.Compare(b.Count, a.Count) })
+	return groups
+}
+
+// Watchdog counts goroutines every interval and alerts when the count rose in each of the last
+// Window intervals. A leak grows with the traffic that causes it, while a burst of requests levels
+// off, so a single alert is a hint rather than proof; the groups of the alert show where to look.
+type Watchdog struct {
+	opts WatchdogOptions
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// StartWatchdog starts counting goroutines in the background until Stop is called.
+func StartWatchdog(opts WatchdogOptions) *Watchdog {
+	if opts.Window <= 0 {
+		opts.Window = 6
+	}
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	w := &Watchdog{opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// Stop ends the watchdog and waits for it.
+func (w *Watchdog) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Watchdog) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	counts := []int{runtime.NumGoroutine()}
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+		counts = w.observe(counts, runtime.NumGoroutine())
+	}
+}
+
+// observe adds a count to the current rising run of counts and alerts once the run spans the
+// window. The run restarts after an alert, so that a steady leak alerts once per window.
+func (w *Watchdog) observe(counts []int, n int) []int {
+	if n <= counts[len(counts)-1] {
+		return []int{n}
+	}
+	counts = append(counts, n)
+	if len(counts) <= w.opts.Window {
+		return counts
+	}
+	if n >= w.opts.Min && w.opts.OnAlert != nil {
+		groups := Groups(All())
+		w.opts.OnAlert(Alert{Counts: counts, Groups: groups[:min(len(groups), w.opts.Top)]})
+	}
+	return []int{n}
+}
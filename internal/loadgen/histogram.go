@@ -0,0 +1,162 @@
+package loadgen
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Histogram buckets (HDR layout): values below 2*half are counted exactly, above that every power of
+// two is split into half linear buckets, so any value is kept to within 1/half, three significant
+// digits. Values are microseconds and clamped to maxValue.
+const (
+	half     = 1024
+	maxShift = 22 // Values up to 2^(22+11) µs, about 2.4 hours
+	maxValue = int64(2*half)<<maxShift - 1
+)
+
+// Histogram is a high dynamic range latency histogram with microsecond resolution. It is not safe
+// for concurrent use.
+type Histogram struct {
+	counts [2*half + maxShift*half]int64
+	total  int64
+	min    int64
+	max    int64
+	sum    float64
+}
+
+func bucketIndex(v int64) int {
+	if v < 2*half {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - 11
+	return 2*half + (shift-1)*half + int(v>>shift) - half
+}
+
+// bucketValue returns the highest value counted in bucket i.
+func bucketValue(i int) int64 {
+	if i < 2*half {
+		return int64(i)
+	}
+	shift := (i-2*half)/half + 1
+	low := int64((i-2*half)%half+half) << shift
+	return low + 1<<shift - 1
+}
+
+// Record counts one latency.
+func (h *Histogram) Record(d time.Duration) { h.recordN(d.Microseconds(), 1) }
+
+func (h *Histogram) recordN(v, n int64) {
+	v = min(max(v, 0), maxValue)
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	h.max = max(h.max, v)
+	h.counts[bucketIndex(v)] += n
+	h.total += n
+	h.sum += float64(v) * float64(n)
+}
+
+// RecordCorrected counts one latency of a request that was expected every interval. A request that
+// took several intervals held back the requests that should have been sent meanwhile, so these
+// are counted as well, with the latencies they would have seen (coordinated omission correction).
+func (h *Histogram) RecordCorrected(d, interval time.Duration) {
+	h.recordCorrectedN(d.Microseconds(), 1, interval.Microseconds())
+}
+
+func (h *Histogram) recordCorrectedN(v, n, interval int64) {
+	h.recordN(v, n)
+	if interval <= 0 {
+		return
+	}
+	for missing := v - interval; missing >= interval; missing -= interval {
+		h.recordN(missing, n)
+	}
+}
+
+// Corrected returns a copy of h corrected for coordinated omission after the fact, as if every
+// value had been recorded with RecordCorrected.
+func (h *Histogram) Corrected(interval time.Duration) *Histogram {
+	c := &Histogram{}
+	for i, n := range h.counts {
+		if n > 0 {
+			c.recordCorrectedN(min(bucketValue(i), h.max), n, interval.Microseconds())
+		}
+	}
+	return c
+}
+
+// Merge adds the counts of o to h.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.total == 0 {
+		return
+	}
+	if h.total == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+	h.total += o.total
+	h.sum += o.sum
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 { return h.total }
+
+// Min returns the smallest recorded value.
+func (h *Histogram) Min() time.Duration { return time.Duration(h.min) * time.Microsecond }
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() time.Duration { return time.Duration(h.max) * time.Microsecond }
+
+// Mean returns the average of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/float64(h.total)) * time.Microsecond
+}
+
+// Percentile returns the value that p percent of the recorded values are at or below, e.g. 99.9.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := max(int64(math.Ceil(p/100*float64(h.total))), 1)
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return time.Duration(min(bucketValue(i), h.max)) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
+
+// Summary summarizes a latency histogram in milliseconds.
+type Summary struct {
+	Min  float64 `json:"minMs"`
+	Mean float64 `json:"meanMs"`
+	P50  float64 `json:"p50Ms"`
+	P90  float64 `json:"p90Ms"`
+	P99  float64 `json:"p99Ms"`
+	P999 float64 `json:"p999Ms"`
+	Max  float64 `json:"maxMs"`
+}
+
+func ms(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+// Summary returns the usual percentiles of h.
+func (h *Histogram) Summary() Summary {
+	return Summary{
+		Min:  ms(h.Min()),
+		Mean: ms(h.Mean()),
+		P50:  ms(h.Percentile(50)),
+		P90:  ms(h.Percentile(90)),
+		P99:  ms(h.Percentile(99)),
+		P999: ms(h.Percentile(99.9)),
+		Max:  ms(h.Max()),
+	}
+}
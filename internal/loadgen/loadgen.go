@@ -0,0 +1,268 @@
+// Package loadgen sends HTTP load and measures latencies without coordinated omission.
+//
+// In the open model requests are started at a constant rate whether or not earlier ones have
+// finished, like independent users, and each latency is measured from the time its request was
+// due, so a stalled server shows up as the queueing delay users would see. In the closed model
+// a fixed number of workers send a request as soon as their previous one finishes, like hey or ab;
+// a slow server then also slows down the load, and the latencies the workers did not get to send
+// are added back after the run (see Histogram.Corrected).
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+// Load models
+const (
+	ModeOpen   = "open"
+	ModeClosed = "closed"
+)
+
+// Options configure a run.
+type Options struct {
+	Mode        string        // ModeOpen or ModeClosed
+	Rate        float64       // Requests per second of the open model
+	Concurrency int           // Workers of the closed model, the most requests in flight of the open model
+	Duration    time.Duration // Length of the run (unlimited if 0)
+	Requests    int           // Requests to send (unlimited if 0)
+
+	// ExpectedInterval is the time between two requests of a closed model worker assumed by the
+	// coordinated omission correction (the mean service time if 0).
+	ExpectedInterval time.Duration
+
+	// Schedule replaces the constant rate of the open model: request i is due Schedule[i] after
+	// the start, and there are len(Schedule) requests.
+	Schedule []time.Duration
+
+	// OnResponse, if set, is called with the status code (0 if the request failed) and the latency
+	// of request i. It is called concurrently.
+	OnResponse func(i, status int, latency time.Duration)
+
+	Client *http.Client // A client pooling Concurrency connections if nil
+}
+
+// NewRequest returns the i-th request of a run. It is called from one goroutine at a time.
+type NewRequest func(i int) (*http.Request, error)
+
+// Result reports a run.
+type Result struct {
+	Label       string           `json:"label,omitempty"`
+	URL         string           `json:"url,omitempty"`
+	Mode        string           `json:"mode"`
+	Rate        float64          `json:"rate,omitempty"`
+	Concurrency int              `json:"concurrency"`
+	Start       time.Time        `json:"start"`
+	Elapsed     float64          `json:"elapsedSeconds"`
+	Requests    int64            `json:"requests"`
+	Errors      int64            `json:"errors"` // Failed requests and responses other than 2xx
+	Status      map[string]int64 `json:"status"` // Responses by status code, failed requests as "error"
+	Throughput  float64          `json:"throughput"`
+
+	// Latency is corrected for coordinated omission, ServiceTime is measured from the time each
+	// request was actually sent.
+	Latency     Summary `json:"latency"`
+	ServiceTime Summary `json:"serviceTime"`
+
+	latency, service *Histogram
+}
+
+// Histograms returns the corrected latency and the service time histograms of the run.
+func (r *Result) Histograms() (latency, service *Histogram) { return r.latency, r.service }
+
+// Run sends requests until the duration has passed, all requests have been sent or ctx is done,
+// and waits for the requests in flight, so that a run cut short still has a result.
+func Run(ctx context.Context, opts Options, newRequest NewRequest) (*Result, error) {
+	if opts.Concurrency <= 0 {
+		return nil, errors.New("concurrency must be positive")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 && opts.Schedule == nil {
+		return nil, errors.New("either a duration or a number of requests is needed")
+	}
+	if opts.Client == nil {
+		opts.Client = util.NewHTTPClient(opts.Concurrency, 10*time.Second)
+	}
+
+	rec := &recorder{client: opts.Client, onResponse: opts.OnResponse, latency: &Histogram{}, service: &Histogram{}, status: map[string]int64{}}
+	start := time.Now()
+	var err error
+	switch opts.Mode {
+	case ModeOpen:
+		if opts.Rate <= 0 && opts.Schedule == nil {
+			return nil, errors.New("rate must be positive in the open model")
+		}
+		err = runOpen(ctx, opts, newRequest, rec, start)
+	case ModeClosed:
+		err = runClosed(ctx, opts, newRequest, rec, start)
+	default:
+		return nil, fmt.Errorf("unknown mode %q, must be %s or %s", opts.Mode, ModeOpen, ModeClosed)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Mode == ModeClosed {
+		interval := opts.ExpectedInterval
+		if interval <= 0 {
+			interval = rec.service.Mean()
+		}
+		rec.latency = rec.service.Corrected(interval)
+	}
+	r := &Result{
+		Mode:        opts.Mode,
+		Concurrency: opts.Concurrency,
+		Start:       start,
+		Elapsed:     elapsed.Seconds(),
+		Requests:    rec.service.Count(),
+		Errors:      rec.errors,
+		Status:      rec.status,
+		Throughput:  float64(rec.service.Count()) / elapsed.Seconds(),
+		Latency:     rec.latency.Summary(),
+		ServiceTime: rec.service.Summary(),
+		latency:     rec.latency,
+		service:     rec.service,
+	}
+	if opts.Mode == ModeOpen && opts.Schedule == nil {
+		r.Rate = opts.Rate
+	}
+	return r, nil
+}
+
+// runOpen starts request i at start + i/rate, or start + Schedule[i]. A request that cannot start on time because
+// Concurrency requests are in flight waits, and the wait counts towards its latency.
+func runOpen(ctx context.Context, opts Options, newRequest NewRequest, rec *recorder, start time.Time) error {
+	inflight := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	if opts.Schedule != nil {
+		opts.Requests = len(opts.Schedule)
+		if opts.Requests == 0 {
+			return nil
+		}
+	}
+	for i := 0; opts.Requests <= 0 || i < opts.Requests; i++ {
+		var due time.Time
+		if opts.Schedule != nil {
+			due = start.Add(opts.Schedule[i])
+		} else {
+			due = start.Add(time.Duration(float64(i) / opts.Rate * float64(time.Second)))
+		}
+		if opts.Duration > 0 && due.Sub(start) >= opts.Duration {
+			return nil
+		}
+		if !sleepUntil(ctx, due) {
+			return nil
+		}
+		select {
+		case inflight <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		req, err := newRequest(i)
+		if err != nil {
+			<-inflight
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec.do(i, req, due)
+			<-inflight
+		}()
+	}
+	return nil
+}
+
+// sleepUntil waits until t and reports whether ctx is still running then.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runClosed runs Concurrency workers that each send their next request when the previous one is done.
+func runClosed(ctx context.Context, opts Options, newRequest NewRequest, rec *recorder, start time.Time) error {
+	var (
+		next     atomic.Int64
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if (opts.Requests > 0 && i >= opts.Requests) || (opts.Duration > 0 && time.Since(start) >= opts.Duration) || ctx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				req, err := newRequest(i)
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				stop := firstErr != nil
+				mu.Unlock()
+				if stop {
+					return
+				}
+				rec.do(i, req, time.Now())
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// recorder sends requests and records their outcome.
+type recorder struct {
+	client     *http.Client
+	onResponse func(i, status int, latency time.Duration)
+
+	mu               sync.Mutex
+	latency, service *Histogram
+	status           map[string]int64
+	errors           int64
+}
+
+// do sends request i and records its latency since due and its service time.
+func (r *recorder) do(i int, req *http.Request, due time.Time) {
+	sent := time.Now()
+	status, code := "error", 0
+	resp, err := r.client.Do(req)
+	if err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		code = resp.StatusCode
+		status = strconv.Itoa(code)
+	}
+	done := time.Now()
+	if r.onResponse != nil {
+		r.onResponse(i, code, done.Sub(sent))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latency.Record(done.Sub(due))
+	r.service.Record(done.Sub(sent))
+	r.status[status]++
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		r.errors++
+	}
+}
@@ -0,0 +1,259 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := &Histogram{}
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond * 100) // 0.1ms to 1s
+	}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{{50, 500 * time.Millisecond}, {99, 990 * time.Millisecond}, {99.9, 999 * time.Millisecond}, {100, time.Second}} {
+		got := h.Percentile(c.p)
+		if diff := got - c.want; diff < 0 || diff > c.want/1000 {
+			t.Errorf("p%g = %v, want %v within 0.1%%", c.p, got, c.want)
+		}
+	}
+	if h.Count() != 10000 || h.Min() != 100*time.Microsecond || h.Max() != time.Second {
+		t.Errorf("count %d, min %v, max %v", h.Count(), h.Min(), h.Max())
+	}
+	if mean := h.Mean(); mean < 500*time.Millisecond || mean > 501*time.Millisecond {
+		t.Errorf("mean = %v", mean)
+	}
+}
+
+func TestBucketsCoverValues(t *testing.T) {
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 123456789, maxValue} {
+		i := bucketIndex(v)
+		if hi := bucketValue(i); hi < v || (i > 0 && bucketValue(i-1) >= v) {
+			t.Errorf("value %d in bucket %d of values up to %d, previous up to %d", v, i, hi, bucketValue(i-1))
+		}
+	}
+}
+
+func TestCoordinatedOmissionCorrection(t *testing.T) {
+	// A worker expected to send every 10ms that was stalled for 1s by one request
+	h := &Histogram{}
+	for range 99 {
+		h.Record(10 * time.Millisecond)
+	}
+	h.Record(time.Second)
+	if p := h.Percentile(90); p > 11*time.Millisecond {
+		t.Fatalf("uncorrected p90 = %v", p)
+	}
+
+	c := h.Corrected(10 * time.Millisecond)
+	if c.Count() != 99+99+1 {
+		t.Errorf("corrected count = %d", c.Count())
+	}
+	if p := c.Percentile(75); p < 490*time.Millisecond {
+		t.Errorf("corrected p75 = %v, want the stalled requests counted", p)
+	}
+
+	r := &Histogram{}
+	for range 99 {
+		r.RecordCorrected(10*time.Millisecond, 10*time.Millisecond)
+	}
+	r.RecordCorrected(time.Second, 10*time.Millisecond)
+	if r.Count() != c.Count() || r.Percentile(75) != c.Percentile(75) {
+		t.Errorf("RecordCorrected: count %d, p75 %v; Corrected: count %d, p75 %v", r.Count(), r.Percentile(75), c.Count(), c.Percentile(75))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := &Histogram{}, &Histogram{}
+	a.Record(5 * time.Millisecond)
+	b.Record(time.Millisecond)
+	b.Record(9 * time.Millisecond)
+	a.Merge(b)
+	if a.Count() != 3 || a.Min() != time.Millisecond || a.Max() != 9*time.Millisecond || a.Percentile(50).Round(time.Millisecond) != 5*time.Millisecond {
+		t.Errorf("merged: count %d, min %v, max %v, p50 %v", a.Count(), a.Min(), a.Max(), a.Percentile(50))
+	}
+}
+
+func TestPicker(t *testing.T) {
+	terms := []string{"hot", "warm", "cold", "frozen"}
+	p, err := NewPicker(terms, 1.5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for range 10000 {
+		counts[p.Next()]++
+	}
+	if counts["hot"] <= counts["warm"] || counts["warm"] <= counts["frozen"] || counts["frozen"] == 0 {
+		t.Errorf("zipf counts = %v", counts)
+	}
+	if _, err := NewPicker(terms, 1, 1); err == nil {
+		t.Error("zipf exponent 1 accepted")
+	}
+	if _, err := NewPicker(nil, 0, 1); err == nil {
+		t.Error("no terms accepted")
+	}
+}
+
+func TestLoadTerms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terms.txt")
+	os.WriteFile(path, []byte("# popular first\nphone\n\n  laptop case \n"), 0o644)
+	terms, err := LoadTerms(path)
+	if err != nil || len(terms) != 2 || terms[0] != "phone" || terms[1] != "laptop case" {
+		t.Fatalf("terms = %q, %v", terms, err)
+	}
+}
+
+// newServer answers after delay, or after stall for every stallEvery-th request.
+func newServer(t *testing.T, delay, stall time.Duration, stallEvery int64) *httptest.Server {
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if stallEvery > 0 && n.Add(1)%stallEvery == 0 {
+			time.Sleep(stall)
+		} else {
+			time.Sleep(delay)
+		}
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(url string) NewRequest {
+	return func(i int) (*http.Request, error) {
+		if i%10 == 9 {
+			return http.NewRequest(http.MethodGet, url+"?fail=1", nil)
+		}
+		return http.NewRequest(http.MethodGet, url, nil)
+	}
+}
+
+func TestRunOpen(t *testing.T) {
+	srv := newServer(t, time.Millisecond, 0, 0)
+	r, err := Run(context.Background(), Options{Mode: ModeOpen, Rate: 200, Concurrency: 10, Duration: 250 * time.Millisecond}, get(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 50 || r.Errors != 5 || r.Status["200"] != 45 || r.Status["500"] != 5 {
+		t.Errorf("requests %d, errors %d, status %v", r.Requests, r.Errors, r.Status)
+	}
+	if r.Rate != 200 || r.Throughput < 150 || r.Latency.P50 < 1 {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestRunOpenCountsQueueing(t *testing.T) {
+	// One connection at 100 req/s against a 20ms server: requests queue up behind each other
+	srv := newServer(t, 20*time.Millisecond, 0, 0)
+	r, err := Run(context.Background(), Options{Mode: ModeOpen, Rate: 100, Concurrency: 1, Requests: 20}, get(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ServiceTime.P99 > 100 || r.Latency.Max < 150 {
+		t.Errorf("service p99 %.1fms, latency max %.1fms, want the queueing in the latency only", r.ServiceTime.P99, r.Latency.Max)
+	}
+}
+
+func TestRunClosed(t *testing.T) {
+	srv := newServer(t, time.Millisecond, 200*time.Millisecond, 50)
+	r, err := Run(context.Background(), Options{Mode: ModeClosed, Concurrency: 2, Requests: 100}, get(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 100 || r.Errors != 10 {
+		t.Errorf("requests %d, errors %d", r.Requests, r.Errors)
+	}
+	latency, service := r.Histograms()
+	if latency.Count() <= service.Count() || r.Latency.P90 < 10*r.ServiceTime.P90 {
+		t.Errorf("stalls not corrected: latency %+v, service %+v", r.Latency, r.ServiceTime)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	srv := newServer(t, 0, 0, 0)
+	for _, mode := range []string{ModeOpen, ModeClosed} {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		r, err := Run(ctx, Options{Mode: mode, Rate: 100, Concurrency: 2, Duration: time.Hour}, get(srv.URL))
+		cancel()
+		// The run sent requests until ctx ended, then stopped long before Duration
+		if err != nil || r.Requests == 0 || r.Elapsed > 1 {
+			t.Errorf("%s: %v, %+v", mode, err, r)
+		}
+	}
+}
+
+func TestRunRejectsOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Mode: ModeOpen, Concurrency: 1, Requests: 1},
+		{Mode: ModeClosed, Requests: 1},
+		{Mode: ModeClosed, Concurrency: 1},
+		{Mode: "ramp", Concurrency: 1, Requests: 1},
+	} {
+		if _, err := Run(context.Background(), opts, get("http://localhost:1")); err == nil {
+			t.Errorf("%+v accepted", opts)
+		}
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	srv := newServer(t, 0, 0, 0)
+	r, err := Run(context.Background(), Options{Mode: ModeClosed, Concurrency: 1, Requests: 5}, get(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Label = "baseline"
+
+	var buf bytes.Buffer
+	WriteCSV(&buf, r, true)
+	WriteCSV(&buf, r, false)
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 3 || len(rows[1]) != len(CSVHeader) || rows[2][0] != "baseline" {
+		t.Errorf("csv = %v, %v", rows, err)
+	}
+
+	buf.Reset()
+	WriteJSON(&buf, r)
+	if !strings.Contains(buf.String(), `"label": "baseline"`) || !strings.Contains(buf.String(), `"p99Ms"`) {
+		t.Errorf("json = %s", buf.String())
+	}
+	buf.Reset()
+	WriteText(&buf, r)
+	if !strings.Contains(buf.String(), "closed model, concurrency 1") || !strings.Contains(buf.String(), "p99.9") {
+		t.Errorf("text = %s", buf.String())
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	srv := newServer(t, 0, 0, 0)
+	schedule := []time.Duration{0, 0, 50 * time.Millisecond, 100 * time.Millisecond}
+	var mu sync.Mutex
+	statuses := map[int]int{}
+	r, err := Run(context.Background(), Options{
+		Mode: ModeOpen, Concurrency: 4, Schedule: schedule,
+		OnResponse: func(i, status int, latency time.Duration) {
+			mu.Lock()
+			statuses[i] = status
+			mu.Unlock()
+		},
+	}, get(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Requests != 4 || r.Elapsed < 0.1 || r.Rate != 0 || len(statuses) != 4 || statuses[3] != 200 {
+		t.Errorf("result %+v, statuses %v", r, statuses)
+	}
+}
@@ -0,0 +1,73 @@
+package loadgen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// WriteText writes r for a terminal.
+func WriteText(w io.Writer, r *Result) error {
+	load := fmt.Sprintf("concurrency %d", r.Concurrency)
+	if r.Mode == ModeOpen {
+		load = fmt.Sprintf("%g req/s, at most %d in flight", r.Rate, r.Concurrency)
+	}
+	fmt.Fprintf(w, "%s model, %s, %.1fs\n", r.Mode, load, r.Elapsed)
+	fmt.Fprintf(w, "  requests    %d (%d errors), %.1f req/s\n", r.Requests, r.Errors, r.Throughput)
+	fmt.Fprintf(w, "  status     ")
+	for _, code := range slices.Sorted(maps.Keys(r.Status)) {
+		fmt.Fprintf(w, " %s:%d", code, r.Status[code])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  %-12s%9s %9s %9s %9s %9s %9s %9s\n", "ms", "min", "mean", "p50", "p90", "p99", "p99.9", "max")
+	for _, row := range []struct {
+		name string
+		s    Summary
+	}{{"latency", r.Latency}, {"service", r.ServiceTime}} {
+		s := row.s
+		fmt.Fprintf(w, "  %-12s%9.2f %9.2f %9.2f %9.2f %9.2f %9.2f %9.2f\n", row.name, s.Min, s.Mean, s.P50, s.P90, s.P99, s.P999, s.Max)
+	}
+	_, err := fmt.Fprintln(w, "  latency is corrected for coordinated omission, service time is not")
+	return err
+}
+
+// WriteJSON writes r as an indented JSON object.
+func WriteJSON(w io.Writer, r *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// CSVHeader lists the columns written by WriteCSV.
+var CSVHeader = []string{
+	"label", "url", "mode", "rate", "concurrency", "start", "elapsed_s", "requests", "errors", "throughput",
+	"latency_mean_ms", "latency_p50_ms", "latency_p90_ms", "latency_p99_ms", "latency_p999_ms", "latency_max_ms",
+	"service_mean_ms", "service_p50_ms", "service_p90_ms", "service_p99_ms", "service_p999_ms", "service_max_ms",
+}
+
+// WriteCSV writes r as one CSV row, preceded by CSVHeader if header is set, so that the rows of
+// several runs can be collected in one file.
+func WriteCSV(w io.Writer, r *Result, header bool) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	row := []string{
+		r.Label, r.URL, r.Mode, f(r.Rate), strconv.Itoa(r.Concurrency), r.Start.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(r.Elapsed, 'f', 3, 64), strconv.FormatInt(r.Requests, 10), strconv.FormatInt(r.Errors, 10),
+		strconv.FormatFloat(r.Throughput, 'f', 1, 64),
+	}
+	for _, s := range []Summary{r.Latency, r.ServiceTime} {
+		row = append(row, f(s.Mean), f(s.P50), f(s.P90), f(s.P99), f(s.P999), f(s.Max))
+	}
+
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write(CSVHeader)
+	}
+	cw.Write(row)
+	cw.Flush()
+	return cw.Error()
+}
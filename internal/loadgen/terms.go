@@ -0,0 +1,98 @@
+package loadgen
+
+import (
+	"bufio"
+	"errors"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+// LoadTerms reads search terms from a file, one per line. Blank lines and lines starting with #
+// are skipped. The order is kept, so that a Zipfian Picker treats the first term as the most popular.
+func LoadTerms(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var terms []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("no terms in " + path)
+	}
+	return terms, nil
+}
+
+// CatalogTerms returns the words of the search catalog's product names, most frequent first, so
+// that generated queries hit real products and popular words are searched most often.
+func CatalogTerms() []string {
+	counts := map[string]int{}
+	for _, p := range search.Catalog() {
+		for _, w := range strings.FieldsFunc(strings.ToLower(p.Name), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(w)) > 2 {
+				counts[w]++
+			}
+		}
+	}
+	terms := make([]string, 0, len(counts))
+	for w := range counts {
+		terms = append(terms, w)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if counts[terms[i]] != counts[terms[j]] {
+			return counts[terms[i]] > counts[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	return terms
+}
+
+// Picker picks terms at random, either uniformly or with a Zipf distribution over their order,
+// where the k-th term is picked about 1/k^s as often as the first. It is not safe for concurrent
+// use.
+type Picker struct {
+	terms []string
+	rng   *rand.Rand
+	zipf  *rand.Zipf
+}
+
+// NewPicker returns a Picker over terms. s is the Zipf exponent and must be greater than 1, or 0
+// to pick uniformly.
+func NewPicker(terms []string, s float64, seed int64) (*Picker, error) {
+	if len(terms) == 0 {
+		return nil, errors.New("no terms to pick from")
+	}
+	if s != 0 && s <= 1 {
+		return nil, errors.New("zipf exponent must be greater than 1")
+	}
+	p := &Picker{terms: terms, rng: rand.New(rand.NewSource(seed))}
+	if s > 0 {
+		p.zipf = rand.NewZipf(p.rng, s, 1, uint64(len(terms)-1))
+	}
+	return p, nil
+}
+
+// Next returns the next term.
+func (p *Picker) Next() string {
+	if p.zipf != nil {
+		return p.terms[p.zipf.Uint64()]
+	}
+	return p.terms[p.rng.Intn(len(p.terms))]
+}
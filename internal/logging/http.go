@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+)
+
+type requestKey struct{}
+
+// request is the logging state of one request.
+type request struct {
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	attrs []slog.Attr // Added to the access log line
+}
+
+// FromContext returns the logger of the request ctx belongs to, which adds the request ID to every
+// line, or slog.Default() outside of requests.
+func FromContext(ctx context.Context) *slog.Logger {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.logger
+	}
+	return slog.Default()
+}
+
+// AddAttrs adds attributes, e.g. the search term, to the access log line of the request ctx
+// belongs to. Outside of requests it does nothing.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		req.attrs = append(req.attrs, attrs...)
+		req.mu.Unlock()
+	}
+}
+
+// Middleware logs one line per request with its method, route pattern, status, duration, size and
+// the attributes added with AddAttrs. Lines logged with FromContext during the request carry the
+// same request_id (from chi's RequestID middleware, which must run first) and the trace_id if the
+// request is traced. Server errors are logged as warnings, everything else as info.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := r.Context()
+			l := logger
+			if id := middleware.GetReqID(ctx); id != "" {
+				l = l.With("request_id", id)
+			}
+			if sc := tracing.SpanFromContext(ctx).Context(); sc.IsValid() {
+				l = l.With("trace_id", sc.TraceID.String())
+			}
+			req := &request{logger: l}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(ctx, requestKey{}, req)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelWarn
+			}
+			if !l.Enabled(ctx, level) {
+				return
+			}
+			route := "unmatched"
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			req.mu.Lock()
+			attrs := append([]slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int("bytes", ww.BytesWritten()),
+			}, req.attrs...)
+			req.mu.Unlock()
+			l.LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
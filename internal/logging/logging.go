@@ -0,0 +1,147 @@
+// Package logging builds the structured log/slog logger of the servers, with sampling so that
+// logging stays cheap under load, and request-scoped loggers carrying the request ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Formats of the log output
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options configure a logger.
+type Options struct {
+	Level            slog.Level
+	Format           string        // FormatJSON (default) or FormatText
+	SampleInitial    int           // Lines per message and Interval logged before sampling starts (no sampling if 0)
+	SampleThereafter int           // Then every SampleThereafter-th line is logged (only the first SampleInitial if 0)
+	Interval         time.Duration // Sampling window (1s if 0)
+}
+
+// ParseLevel parses debug, info, warn or error, optionally with an offset such as warn+2.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("log level %q: must be debug, info, warn or error", s)
+	}
+	return l, nil
+}
+
+// New returns a logger writing to w.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	ho := &slog.HandlerOptions{Level: opts.Level}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case FormatJSON, "":
+		h = slog.NewJSONHandler(w, ho)
+	case FormatText:
+		h = slog.NewTextHandler(w, ho)
+	default:
+		return nil, fmt.Errorf("log format %q: must be %s or %s", opts.Format, FormatJSON, FormatText)
+	}
+	if opts.SampleInitial > 0 {
+		h = NewSampler(h, opts.SampleInitial, opts.SampleThereafter, opts.Interval)
+	}
+	return slog.New(h), nil
+}
+
+// samplerSlots is the number of counters lines are hashed onto by level and message. Messages
+// sharing a slot share their budget, which only makes sampling a little stricter.
+const samplerSlots = 4096
+
+// slot hashes level and message with FNV-1a, inline because hash/fnv would allocate per line.
+func slot(level slog.Level, msg string) uint32 {
+	const prime = 16777619
+	h := uint32(2166136261)
+	h = (h ^ uint32(uint8(level))) * prime
+	for i := 0; i < len(msg); i++ {
+		h = (h ^ uint32(msg[i])) * prime
+	}
+	return h % samplerSlots
+}
+
+type sampleCounter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+// inc counts a line at now (unix nanoseconds) and returns the count within the current window.
+// Races at the window boundary can let a few extra lines through, never drop the first ones.
+func (c *sampleCounter) inc(now, interval int64) uint64 {
+	if resetAt := c.resetAt.Load(); now < resetAt {
+		return c.n.Add(1)
+	} else if c.resetAt.CompareAndSwap(resetAt, now+interval) {
+		c.n.Store(1)
+		return 1
+	}
+	return c.n.Add(1)
+}
+
+// Sampler is a slog.Handler that logs the first lines of every message per interval and then
+// only every n-th, in the spirit of zap's sampler. Errors are never sampled.
+type Sampler struct {
+	next       slog.Handler
+	initial    uint64
+	thereafter uint64
+	interval   int64
+	counters   *[samplerSlots]sampleCounter // Shared by the handlers derived with WithAttrs and WithGroup
+	dropped    *atomic.Int64
+}
+
+// NewSampler wraps next, see Options for the parameters.
+func NewSampler(next slog.Handler, initial, thereafter int, interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Sampler{
+		next:       next,
+		initial:    uint64(max(initial, 0)),
+		thereafter: uint64(max(thereafter, 0)),
+		interval:   int64(interval),
+		counters:   new([samplerSlots]sampleCounter),
+		dropped:    new(atomic.Int64),
+	}
+}
+
+// Dropped returns the number of lines dropped by sampling.
+func (s *Sampler) Dropped() int64 { return s.dropped.Load() }
+
+func (s *Sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *Sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError {
+		now := r.Time
+		if now.IsZero() {
+			now = time.Now()
+		}
+		n := s.counters[slot(r.Level, r.Message)].inc(now.UnixNano(), s.interval)
+		if n > s.initial && (s.thereafter == 0 || (n-s.initial)%s.thereafter != 0) {
+			s.dropped.Add(1)
+			return nil
+		}
+	}
+	return s.next.Handle(ctx, r)
+}
+
+func (s *Sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *s
+	c.next = s.next.WithAttrs(attrs)
+	return &c
+}
+
+func (s *Sampler) WithGroup(name string) slog.Handler {
+	c := *s
+	c.next = s.next.WithGroup(name)
+	return &c
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSampler(slog.NewTextHandler(&buf, nil), 3, 5, time.Hour)
+	l := slog.New(s)
+	for i := 0; i < 20; i++ {
+		l.Info("hot")
+		l.With("k", "v").Info("hot") // Derived loggers share the budget
+	}
+	l.Info("cold")
+	for i := 0; i < 5; i++ {
+		l.Error("hot")
+	}
+
+	// 40 hot infos: the first 3, then the 8th, 13th, ..., 38th
+	if got := strings.Count(buf.String(), "level=INFO msg=hot"); got != 3+7 {
+		t.Errorf("%d hot infos logged, want 10", got)
+	}
+	if !strings.Contains(buf.String(), "msg=cold") {
+		t.Error("a message of its own was sampled away")
+	}
+	if got := strings.Count(buf.String(), "level=ERROR"); got != 5 {
+		t.Errorf("%d errors logged, want all 5", got)
+	}
+	if s.Dropped() != 30 {
+		t.Errorf("dropped %d, want 30", s.Dropped())
+	}
+}
+
+func TestSamplerWindow(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewSampler(slog.NewTextHandler(&buf, nil), 1, 0, 20*time.Millisecond))
+	l.Info("tick")
+	l.Info("tick")
+	time.Sleep(30 * time.Millisecond)
+	l.Info("tick")
+	if got := strings.Count(buf.String(), "msg=tick"); got != 2 {
+		t.Errorf("%d lines logged, want one per window", got)
+	}
+}
+
+func TestNewRejectsUnknownFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Options{Format: "xml"}); err == nil {
+		t.Error("xml format accepted")
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("level loud accepted")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID, Middleware(logger))
+	r.Get("/search/{id}", func(w http.ResponseWriter, r *http.Request) {
+		AddAttrs(r.Context(), slog.String("term", "phone"))
+		FromContext(r.Context()).Warn("dependency failed")
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/search/7", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines:\n%s", len(lines), buf.String())
+	}
+	var dep, access map[string]any
+	json.Unmarshal([]byte(lines[0]), &dep)
+	json.Unmarshal([]byte(lines[1]), &access)
+	if dep["request_id"] == nil || dep["request_id"] != access["request_id"] {
+		t.Errorf("request IDs %v and %v", dep["request_id"], access["request_id"])
+	}
+	for k, want := range map[string]any{"msg": "request", "level": "WARN", "route": "/search/{id}", "status": 502.0, "term": "phone"} {
+		if access[k] != want {
+			t.Errorf("%s = %v, want %v", k, access[k], want)
+		}
+	}
+}
+
+func TestFromContextOutsideRequest(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext outside a request is not slog.Default()")
+	}
+	AddAttrs(context.Background(), slog.String("ignored", "yes"))
+}
@@ -0,0 +1,343 @@
+// Package metrics is a small, dependency-free metrics registry that writes the Prometheus text
+// exposition format. It supports labelled counters, gauges and histograms; recording a value is
+// lock-free once a label combination has been seen.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 0.5ms to 10s.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector writes one metric family.
+type collector interface {
+	write(w io.Writer) error
+}
+
+// Registry holds metrics in registration order. It is safe for concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds c, which writes the metrics with the given names.
+func (r *Registry) register(c collector, names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		if r.names[name] {
+			panic(fmt.Sprintf("metrics: %s registered twice", name))
+		}
+		r.names[name] = true
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every metric in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+	for _, c := range collectors {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.Write(w)
+	})
+}
+
+// family is the name, help and labels shared by all series of a metric.
+type family struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	series sync.Map // label values joined by labelSep -> series
+}
+
+const labelSep = "\xff"
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", f.name, f.labels, values))
+	}
+	return strings.Join(values, labelSep)
+}
+
+// load returns the series for the label values, creating it with newSeries on first use.
+func (f *family) load(values []string, newSeries func() any) any {
+	key := f.key(values)
+	if s, ok := f.series.Load(key); ok {
+		return s
+	}
+	s, _ := f.series.LoadOrStore(key, newSeries())
+	return s
+}
+
+// sorted returns the series ordered by label values, so that scrapes are stable.
+func (f *family) sorted() (keys []string, series []any) {
+	f.series.Range(func(k, v any) bool {
+		keys = append(keys, k.(string))
+		return true
+	})
+	slices.Sort(keys)
+	series = make([]any, len(keys))
+	for i, k := range keys {
+		series[i], _ = f.series.Load(k)
+	}
+	return keys, series
+}
+
+func (f *family) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+	return err
+}
+
+// labelPairs formats the series' labels and any extra name/value pairs, e.g. {route="/api",le="0.1"}.
+func (f *family) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, value := range strings.Split(key, labelSep) {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// atomicFloat is a float64 updated with compare-and-swap.
+type atomicFloat struct{ bits atomic.Uint64 }
+
+func (a *atomicFloat) add(v float64) {
+	for {
+		old := a.bits.Load()
+		if a.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (a *atomicFloat) set(v float64) { a.bits.Store(math.Float64bits(v)) }
+func (a *atomicFloat) load() float64 { return math.Float64frombits(a.bits.Load()) }
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct{ f *family }
+
+// NewCounter registers a counter. By convention its name ends in _total.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{f: &family{name: name, help: help, typ: "counter", labels: labels}}
+	r.register(c, name)
+	return c
+}
+
+// Inc adds one to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v, which must not be negative, to the series with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.f.load(labelValues, func() any { return new(atomicFloat) }).(*atomicFloat).add(v)
+}
+
+// Value returns the current value of the series with the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.f.load(labelValues, func() any { return new(atomicFloat) }).(*atomicFloat).load()
+}
+
+func (c *Counter) write(w io.Writer) error { return writeValues(w, c.f) }
+
+// Gauge is a value per label combination that can go up and down.
+type Gauge struct{ f *family }
+
+// NewGauge registers a gauge.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{f: &family{name: name, help: help, typ: "gauge", labels: labels}}
+	r.register(g, name)
+	return g
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.load(labelValues, func() any { return new(atomicFloat) }).(*atomicFloat).set(v)
+}
+
+// Add adds v, which may be negative, to the series with the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.load(labelValues, func() any { return new(atomicFloat) }).(*atomicFloat).add(v)
+}
+
+// Value returns the current value of the series with the given label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.f.load(labelValues, func() any { return new(atomicFloat) }).(*atomicFloat).load()
+}
+
+func (g *Gauge) write(w io.Writer) error { return writeValues(w, g.f) }
+
+func writeValues(w io.Writer, f *family) error {
+	if err := f.writeHeader(w); err != nil {
+		return err
+	}
+	keys, series := f.sorted()
+	for i, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelPairs(key), formatFloat(series[i].(*atomicFloat).load())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc is a gauge without labels whose value is read when the registry is written.
+type GaugeFunc struct {
+	f     *family
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge that calls value on every scrape.
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{f: &family{name: name, help: help, typ: "gauge"}, value: value}
+	r.register(g, name)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	if err := g.f.writeHeader(w); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", g.f.name, formatFloat(g.value()))
+	return err
+}
+
+// Histogram counts observations into buckets per label combination.
+type Histogram struct {
+	f       *family
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []atomic.Uint64 // Per bucket, not cumulative; the last one is +Inf
+	count  atomic.Uint64
+	sum    atomicFloat
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds (DefaultBuckets if nil).
+// By convention its name ends in the unit, e.g. _seconds.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s are not sorted", name))
+	}
+	h := &Histogram{f: &family{name: name, help: help, typ: "histogram", labels: labels}, buckets: slices.Clone(buckets)}
+	r.register(h, name)
+	return h
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.f.load(labelValues, func() any {
+		return &histogramSeries{counts: make([]atomic.Uint64, len(h.buckets)+1)}
+	}).(*histogramSeries)
+	i, _ := slices.BinarySearch(h.buckets, v) // first bucket with bound >= v
+	s.counts[i].Add(1)
+	s.count.Add(1)
+	s.sum.add(v)
+}
+
+// Count returns the number of observations of the series with the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	s, ok := h.f.series.Load(h.f.key(labelValues))
+	if !ok {
+		return 0
+	}
+	return s.(*histogramSeries).count.Load()
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	if err := h.f.writeHeader(w); err != nil {
+		return err
+	}
+	keys, series := h.f.sorted()
+	for i, key := range keys {
+		s := series[i].(*histogramSeries)
+		counts := make([]uint64, len(s.counts))
+		for j := range s.counts {
+			counts[j] = s.counts[j].Load()
+		}
+		if err := writeHistogram(w, h.f, key, h.buckets, counts, s.sum.load()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHistogram writes one histogram series from per-bucket counts, the last of which is +Inf.
+func writeHistogram(w io.Writer, f *family, key string, bounds []float64, counts []uint64, sum float64) error {
+	var cumulative uint64
+	for j, c := range counts {
+		cumulative += c
+		le := "+Inf"
+		if j < len(bounds) {
+			le = formatFloat(bounds[j])
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelPairs(key, "le", le), cumulative); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+		f.name, f.labelPairs(key), formatFloat(sum), f.name, f.labelPairs(key), cumulative)
+	return err
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return fmt.Sprint(v)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWriteExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests by route.", "route", "code")
+	inflight := r.NewGauge("inflight", "In-flight requests.")
+	latency := r.NewHistogram("latency_seconds", "Request latency.\nIn seconds.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("answer", "The answer.", func() float64 { return 42 })
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "500")
+	requests.Inc(`/"quoted"`, "200")
+	inflight.Add(3)
+	inflight.Add(-1)
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a")
+	latency.Observe(5, "/a")
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP requests_total Requests by route.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"",code="200"} 1
+requests_total{route="/a",code="500"} 2
+requests_total{route="/b",code="200"} 1
+# HELP inflight In-flight requests.
+# TYPE inflight gauge
+inflight 2
+# HELP latency_seconds Request latency.\nIn seconds.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.15
+latency_seconds_count{route="/a"} 3
+# HELP answer The answer.
+# TYPE answer gauge
+answer 42
+`
+	if b.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestConcurrentRecording(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("calls_total", "Calls.", "service")
+	h := r.NewHistogram("call_seconds", "Call latency.", nil, "service")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc("product")
+				h.Observe(0.01, "product")
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.Value("product"); got != 8000 {
+		t.Fatalf("counter = %v, want 8000", got)
+	}
+	if got := h.Count("product"); got != 8000 {
+		t.Fatalf("histogram count = %d, want 8000", got)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	r := NewRegistry()
+	RegisterRuntime(r)
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\ngo_goroutines ", `go_gc_pause_seconds_bucket{le="+Inf"} `, "\ngo_gc_pause_seconds_count "} {
+		if !strings.Contains("\n"+b.String(), want) {
+			t.Fatalf("runtime metrics lack %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime/metrics"
+	"slices"
+)
+
+// runtimeBuckets are the bounds, in seconds, that runtime/metrics time histograms are folded into.
+// The runtime's own histograms have well over a hundred buckets.
+var runtimeBuckets = []float64{1e-6, 5e-6, 1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, .01, .05, .1, .5, 1}
+
+// runtimeMetric maps a runtime/metrics sample to a Prometheus metric.
+type runtimeMetric struct {
+	sample, name, help, typ string
+}
+
+var runtimeMetrics = []runtimeMetric{
+	{"/sched/goroutines:goroutines", "go_goroutines", "Number of live goroutines.", "gauge"},
+	{"/sched/gomaxprocs:threads", "go_gomaxprocs", "Current GOMAXPROCS.", "gauge"},
+	{"/gc/cycles/total:gc-cycles", "go_gc_cycles_total", "Completed GC cycles.", "counter"},
+	{"/memory/classes/heap/objects:bytes", "go_memory_heap_objects_bytes", "Memory occupied by live and not yet swept heap objects.", "gauge"},
+	{"/gc/heap/allocs:bytes", "go_gc_heap_allocs_bytes_total", "Cumulative bytes allocated on the heap.", "counter"},
+	{"/sched/pauses/total/gc:seconds", "go_gc_pause_seconds", "Stop-the-world pause latencies of the GC.", "histogram"},
+	{"/sched/latencies:seconds", "go_sched_latency_seconds", "Time goroutines spent runnable before running.", "histogram"},
+}
+
+// runtimeCollector reads runtime/metrics on every scrape.
+type runtimeCollector struct {
+	metrics []runtimeMetric
+}
+
+// RegisterRuntime registers the goroutine count, GOMAXPROCS, GC cycles, heap size and allocations,
+// GC pause and scheduling latency histograms from runtime/metrics. Metrics the running Go version
+// does not support are skipped.
+func RegisterRuntime(r *Registry) {
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	c := &runtimeCollector{}
+	var names []string
+	for _, m := range runtimeMetrics {
+		if supported[m.sample] {
+			c.metrics = append(c.metrics, m)
+			names = append(names, m.name)
+		}
+	}
+	r.register(c, names...)
+}
+
+func (c *runtimeCollector) write(w io.Writer) error {
+	samples := make([]metrics.Sample, len(c.metrics))
+	for i, m := range c.metrics {
+		samples[i].Name = m.sample
+	}
+	metrics.Read(samples)
+
+	for i, m := range c.metrics {
+		f := &family{name: m.name, help: m.help, typ: m.typ}
+		if err := f.writeHeader(w); err != nil {
+			return err
+		}
+		var err error
+		switch v := samples[i].Value; v.Kind() {
+		case metrics.KindUint64:
+			_, err = fmt.Fprintf(w, "%s %d\n", f.name, v.Uint64())
+		case metrics.KindFloat64:
+			_, err = fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(v.Float64()))
+		case metrics.KindFloat64Histogram:
+			counts, sum := fold(v.Float64Histogram(), runtimeBuckets)
+			err = writeHistogram(w, f, "", runtimeBuckets, counts, sum)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fold sums the runtime histogram's buckets into the given bounds, plus a final +Inf bucket.
+// A runtime bucket goes into the first bound not below its upper edge. The runtime does not
+// track the sum of observations, so it is estimated from bucket midpoints.
+func fold(h *metrics.Float64Histogram, bounds []float64) (counts []uint64, sum float64) {
+	counts = make([]uint64, len(bounds)+1)
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		lower, upper := h.Buckets[i], h.Buckets[i+1]
+		j, _ := slices.BinarySearch(bounds, upper)
+		counts[j] += c
+
+		mid := lower
+		switch {
+		case math.IsInf(lower, -1):
+			mid = upper
+		case !math.IsInf(upper, 1):
+			mid = (lower + upper) / 2
+		}
+		sum += mid * float64(c)
+	}
+	return counts, sum
+}
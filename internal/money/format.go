@@ -0,0 +1,116 @@
+package money
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale describes how a locale writes money and which currency it uses by default.
+type Locale struct {
+	Tag         string // BCP 47 language tag, e.g. "tr-TR"
+	Currency    string // Default currency of the locale
+	Decimal     string // Decimal separator
+	Group       string // Thousands separator
+	SymbolAfter bool   // Write the symbol after the amount, separated by a space ("1.234,50 €")
+}
+
+var (
+	localeTR = Locale{Tag: "tr-TR", Currency: "TRY", Decimal: ",", Group: "."}
+	localeUS = Locale{Tag: "en-US", Currency: "USD", Decimal: ".", Group: ","}
+	localeGB = Locale{Tag: "en-GB", Currency: "GBP", Decimal: ".", Group: ","}
+	localeDE = Locale{Tag: "de-DE", Currency: "EUR", Decimal: ",", Group: ".", SymbolAfter: true}
+	localeFR = Locale{Tag: "fr-FR", Currency: "EUR", Decimal: ",", Group: " ", SymbolAfter: true}
+	localeJP = Locale{Tag: "ja-JP", Currency: "JPY", Decimal: ".", Group: ","}
+)
+
+// DefaultLocale is used when a request does not ask for a supported locale
+var DefaultLocale = localeTR
+
+// locales maps lower-case language tags and primary languages to locales
+var locales = map[string]Locale{
+	"tr": localeTR, "tr-tr": localeTR,
+	"en": localeUS, "en-us": localeUS, "en-gb": localeGB,
+	"de": localeDE, "de-de": localeDE,
+	"fr": localeFR, "fr-fr": localeFR,
+	"ja": localeJP, "ja-jp": localeJP,
+}
+
+// Format writes m the way loc does, e.g. "₺1.234,50" for tr-TR or "$1,234.50" for en-US.
+// Currencies without a known symbol are written with their code.
+func Format(m Money, loc Locale) string {
+	symbol, digits := m.Currency, 2
+	if c, ok := currencies[m.Currency]; ok {
+		symbol, digits = c.Symbol, c.Digits
+	}
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digitsStr := strconv.FormatInt(amount, 10)
+	if len(digitsStr) <= digits {
+		digitsStr = strings.Repeat("0", digits-len(digitsStr)+1) + digitsStr
+	}
+	intPart, fracPart := digitsStr[:len(digitsStr)-digits], digitsStr[len(digitsStr)-digits:]
+
+	var b strings.Builder
+	b.WriteString(sign)
+	if !loc.SymbolAfter {
+		b.WriteString(symbol)
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(loc.Group)
+		}
+		b.WriteRune(d)
+	}
+	if digits > 0 {
+		b.WriteString(loc.Decimal)
+		b.WriteString(fracPart)
+	}
+	if loc.SymbolAfter {
+		b.WriteString(" ")
+		b.WriteString(symbol)
+	}
+	return b.String()
+}
+
+// MatchLocale picks the supported locale the client prefers most from an Accept-Language header,
+// e.g. "en-GB,en;q=0.9,tr;q=0.8". Unsupported regions fall back to their primary language, and
+// DefaultLocale is returned if nothing matches.
+func MatchLocale(acceptLanguage string) Locale {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag: strings.ToLower(strings.TrimSpace(tag)), q: q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if loc, ok := locales[t.tag]; ok {
+			return loc
+		}
+		primary, _, _ := strings.Cut(t.tag, "-")
+		if loc, ok := locales[primary]; ok {
+			return loc
+		}
+	}
+	return DefaultLocale
+}
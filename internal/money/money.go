@@ -0,0 +1,69 @@
+// Package money models prices as integer minor units with an ISO 4217 currency code,
+// converts them through an exchange-rate table and formats them for a locale.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultCurrency is the currency product prices are stored in
+const DefaultCurrency = "TRY"
+
+// ErrUnknownCurrency is returned for currency codes that are not in the currency table
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Currency describes how amounts of a currency are stored and displayed.
+type Currency struct {
+	Code   string // ISO 4217 code, e.g. "TRY"
+	Symbol string // e.g. "₺"
+	Digits int    // number of minor unit digits, e.g. 2 for kuruş or cents
+}
+
+var currencies = map[string]Currency{
+	"TRY": {Code: "TRY", Symbol: "₺", Digits: 2},
+	"USD": {Code: "USD", Symbol: "$", Digits: 2},
+	"EUR": {Code: "EUR", Symbol: "€", Digits: 2},
+	"GBP": {Code: "GBP", Symbol: "£", Digits: 2},
+	"JPY": {Code: "JPY", Symbol: "¥", Digits: 0},
+}
+
+// LookupCurrency returns the currency with the given ISO 4217 code.
+func LookupCurrency(code string) (Currency, error) {
+	c, ok := currencies[code]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Money is an amount in the minor units of a currency, e.g. {123450, "TRY"} is ₺1.234,50.
+type Money struct {
+	Amount   int64  `json:"amount"`   // Amount in minor units (kuruş, cents, ...)
+	Currency string `json:"currency"` // ISO 4217 currency code
+}
+
+// FromMajor converts an amount in major units (e.g. 12.5 lira) to Money, rounding to the nearest minor unit.
+// Unknown currencies are assumed to have two minor unit digits.
+func FromMajor(amount float64, currency string) Money {
+	return Money{Amount: int64(math.Round(amount * scale(currency))), Currency: currency}
+}
+
+// Major returns the amount in major units, e.g. 12.5 for 1250 kuruş.
+func (m Money) Major() float64 {
+	return float64(m.Amount) / scale(m.Currency)
+}
+
+// scale returns 10^digits for the currency's minor units.
+func scale(currency string) float64 {
+	return math.Pow10(digits(currency))
+}
+
+// digits returns the number of minor unit digits of the currency, 2 if it is unknown.
+func digits(currency string) int {
+	if c, ok := currencies[currency]; ok {
+		return c.Digits
+	}
+	return 2
+}
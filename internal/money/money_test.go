@@ -0,0 +1,112 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m    Money
+		loc  Locale
+		want string
+	}{
+		{Money{123450, "TRY"}, localeTR, "₺1.234,50"},
+		{Money{123450, "USD"}, localeUS, "$1,234.50"},
+		{Money{123450, "EUR"}, localeDE, "1.234,50 €"},
+		{Money{5, "TRY"}, localeTR, "₺0,05"},
+		{Money{-1234567, "GBP"}, localeGB, "-£12,345.67"},
+		{Money{1234, "JPY"}, localeJP, "¥1,234"},
+		{Money{100, "XYZ"}, localeUS, "XYZ1.00"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.m, tt.loc); got != tt.want {
+			t.Fatalf("Format(%v, %s) = %q, want %q", tt.m, tt.loc.Tag, got, tt.want)
+		}
+	}
+}
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "tr-TR"},
+		{"en-US", "en-US"},
+		{"en-GB,en;q=0.9", "en-GB"},
+		{"en-AU", "en-US"},
+		{"es-ES,de;q=0.5,tr;q=0.8", "tr-TR"},
+		{"tr;q=0, fr-CH", "fr-FR"},
+		{"xx, yy;q=0.3", "tr-TR"},
+	}
+	for _, tt := range tests {
+		if got := MatchLocale(tt.header).Tag; got != tt.want {
+			t.Fatalf("MatchLocale(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates, err := NewRateTable("TRY", map[string]float64{"USD": 0.03, "JPY": 4.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := rates.Convert(Money{100000, "TRY"}, "USD")
+	if err != nil || got != (Money{3000, "USD"}) {
+		t.Fatalf("TRY->USD = %v, %v", got, err)
+	}
+	got, err = rates.Convert(Money{3000, "USD"}, "JPY")
+	if err != nil || got != (Money{4500, "JPY"}) {
+		t.Fatalf("USD->JPY = %v, %v", got, err)
+	}
+	if _, err := rates.Convert(Money{100, "TRY"}, "EUR"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Fatalf("TRY->EUR: expected ErrUnknownCurrency, got %v", err)
+	}
+
+	// 140 kuruş are exactly 3.5 euro cents at 0.025, which float64 arithmetic puts just below the half
+	defaults := DefaultRates()
+	for _, tt := range []struct {
+		m    Money
+		want Money
+	}{
+		{Money{140, "TRY"}, Money{4, "EUR"}},
+		{Money{-140, "TRY"}, Money{-4, "EUR"}},
+		{Money{580, "TRY"}, Money{15, "EUR"}},
+		{Money{4, "EUR"}, Money{160, "TRY"}},
+	} {
+		if got, err := defaults.Convert(tt.m, tt.want.Currency); err != nil || got != tt.want {
+			t.Fatalf("Convert(%v, %s) = %v, %v, want %v", tt.m, tt.want.Currency, got, err, tt.want)
+		}
+	}
+	if _, err := defaults.Convert(Money{math.MaxInt64, "JPY"}, "TRY"); err == nil {
+		t.Fatal("expected an error for an amount that does not fit in int64")
+	}
+
+	for _, bad := range []map[string]float64{{"USD": 0}, {"XYZ": 1}, {"TRY": 2}} {
+		if _, err := NewRateTable("TRY", bad); err == nil {
+			t.Fatalf("NewRateTable(%v): expected error", bad)
+		}
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"base": "USD", "rates": {"TRY": 40}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := LoadRates(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates.Base() != "USD" || !rates.Supports("TRY") || rates.Supports("EUR") {
+		t.Fatalf("unexpected table %+v", rates)
+	}
+	got, err := rates.Convert(Money{4000, "TRY"}, "USD")
+	if err != nil || got != (Money{100, "USD"}) {
+		t.Fatalf("TRY->USD = %v, %v", got, err)
+	}
+}
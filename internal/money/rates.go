@@ -0,0 +1,134 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+)
+
+// RateTable converts money between currencies. Rates are the amount of a currency
+// that one unit of the base currency buys; the base currency itself has rate 1.
+// A RateTable is not modified after it has been created, so it is safe for concurrent use.
+type RateTable struct {
+	base  string
+	rates map[string]*big.Rat // Exact decimal value of each rate as written, e.g. 29/1000 for 0.029
+}
+
+// rateFile is the JSON layout read by LoadRates, e.g. {"base": "TRY", "rates": {"USD": 0.029}}
+type rateFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// NewRateTable returns a table converting between base and the currencies in rates.
+func NewRateTable(base string, rates map[string]float64) (*RateTable, error) {
+	if _, err := LookupCurrency(base); err != nil {
+		return nil, fmt.Errorf("base currency: %w", err)
+	}
+	t := &RateTable{base: base, rates: map[string]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, rate := range rates {
+		if _, err := LookupCurrency(code); err != nil {
+			return nil, err
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return nil, fmt.Errorf("rate of %s must be a positive number, got %v", code, rate)
+		}
+		if code == base && rate != 1 {
+			return nil, fmt.Errorf("rate of base currency %s must be 1, got %v", code, rate)
+		}
+		// The shortest decimal that formats as rate is the value the rate was written as,
+		// e.g. 0.029 rather than the nearest float64
+		t.rates[code], _ = new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	return t, nil
+}
+
+// DefaultRates returns fixed demo rates with TRY as base. They are not market data.
+func DefaultRates() *RateTable {
+	t, err := NewRateTable(DefaultCurrency, map[string]float64{
+		"USD": 0.029,
+		"EUR": 0.025,
+		"GBP": 0.022,
+		"JPY": 4.4,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadRates reads a rate table from a JSON file such as
+//
+//	{"base": "TRY", "rates": {"USD": 0.029, "EUR": 0.025}}
+func LoadRates(path string) (*RateTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f rateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("exchange rates %s: %w", path, err)
+	}
+	t, err := NewRateTable(f.Base, f.Rates)
+	if err != nil {
+		return nil, fmt.Errorf("exchange rates %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Base returns the base currency of the table.
+func (t *RateTable) Base() string {
+	return t.base
+}
+
+// Supports reports whether the table can convert to and from currency.
+func (t *RateTable) Supports(currency string) bool {
+	_, ok := t.rates[currency]
+	return ok
+}
+
+// Convert returns m in the target currency, rounded to the nearest minor unit with halves
+// rounded away from zero. The conversion is exact up to that rounding: it works on the integer
+// minor units and the decimal rates, never on float64 amounts.
+func (t *RateTable) Convert(m Money, to string) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	from, ok := t.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s: %w", m.Currency, ErrUnknownCurrency)
+	}
+	rate, ok := t.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("no exchange rate for %s: %w", to, ErrUnknownCurrency)
+	}
+	// amount in minor units of to = m.Amount / 10^digits(from) / from * rate * 10^digits(to)
+	x := new(big.Rat).SetInt64(m.Amount)
+	x.Mul(x, rate)
+	x.Quo(x, from)
+	x.Mul(x, new(big.Rat).SetFrac(pow10(digits(to)), pow10(digits(m.Currency))))
+	amount, ok := roundHalfAway(x)
+	if !ok {
+		return Money{}, fmt.Errorf("%d %s minor units converted to %s overflow int64", m.Amount, m.Currency, to)
+	}
+	return Money{Amount: amount, Currency: to}, nil
+}
+
+// roundHalfAway rounds x to the nearest integer, halves away from zero like math.Round, and
+// reports whether the result fits in an int64.
+func roundHalfAway(x *big.Rat) (int64, bool) {
+	q, r := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	// x.Denom() is positive, so r has the sign of x and rounding moves q by one towards it
+	if r.Lsh(r.Abs(r), 1).Cmp(x.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(x.Sign())))
+	}
+	return q.Int64(), q.IsInt64()
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
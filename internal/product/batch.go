@@ -0,0 +1,55 @@
+package product
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxBatchConcurrency bounds the concurrent GetProductByID calls of GetProducts for services
+// that cannot fetch many products in one call.
+const MaxBatchConcurrency = 16
+
+// BatchProductService is implemented by product services that can fetch many products in one call.
+type BatchProductService interface {
+	ProductService
+	// GetProductsByIDs returns the products that could be fetched, in no particular order.
+	GetProductsByIDs(ctx context.Context, ids []int) ([]*Product, error)
+}
+
+// GetProducts fetches the products with a single call if svc is a BatchProductService, and
+// otherwise with at most MaxBatchConcurrency concurrent GetProductByID calls. It returns the
+// products that could be fetched, in no particular order, or ctx's error if ctx is done first.
+func GetProducts(ctx context.Context, svc ProductService, ids []int) ([]*Product, error) {
+	if b, ok := svc.(BatchProductService); ok {
+		return b.GetProductsByIDs(ctx, ids)
+	}
+
+	found := make([]*Product, len(ids))
+	sem := make(chan struct{}, MaxBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			found[i], _ = svc.GetProductByID(ctx, id)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	products := found[:0]
+	for _, p := range found {
+		if p != nil {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
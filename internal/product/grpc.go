@@ -0,0 +1,130 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer adapts a ProductService to the generated pb.ProductServiceServer.
+type grpcServer struct {
+	pb.UnimplementedProductServiceServer
+	svc ProductService
+}
+
+// NewGRPCServer exposes svc as a gRPC ProductService. Unknown products return codes.NotFound;
+// any other error returns codes.Unavailable, like an unhealthy upstream would.
+func NewGRPCServer(svc ProductService) pb.ProductServiceServer {
+	return &grpcServer{svc: svc}
+}
+
+func (s *grpcServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	p, err := s.svc.GetProductByID(ctx, int(req.GetId()))
+	if errors.Is(err, ErrProductNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return toPB(p), nil
+}
+
+// GetProducts looks up the IDs with GetProducts, MaxBatchConcurrency at a time, the way a batch endpoint hides
+// per-item latency. It fails with the status of ctx's error if the call ends first.
+func (s *grpcServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
+	ids := make([]int, len(req.GetIds()))
+	for i, id := range req.GetIds() {
+		ids[i] = int(id)
+	}
+	products, err := GetProducts(ctx, s.svc, ids)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	resp := &pb.GetProductsResponse{Products: make([]*pb.Product, 0, len(products))}
+	found := make(map[int]bool, len(products))
+	for _, p := range products {
+		found[p.ID] = true
+		resp.Products = append(resp.Products, toPB(p))
+	}
+	for _, id := range req.GetIds() {
+		if !found[int(id)] {
+			resp.MissingIds = append(resp.MissingIds, id)
+		}
+	}
+	return resp, nil
+}
+
+// GRPCProductService is a ProductService client for a product service served by NewGRPCServer.
+type GRPCProductService struct {
+	client  pb.ProductServiceClient
+	timeout time.Duration
+}
+
+// NewGRPCProductService creates a client on conn. Each call is bounded by timeout (5s if zero).
+func NewGRPCProductService(conn grpc.ClientConnInterface, timeout time.Duration) *GRPCProductService {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &GRPCProductService{client: pb.NewProductServiceClient(conn), timeout: timeout}
+}
+
+// GetProductByID fetches the product from the remote service
+func (s *GRPCProductService) GetProductByID(ctx context.Context, id int) (*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	p, err := s.client.GetProduct(ctx, &pb.GetProductRequest{Id: int64(id)})
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: %d", ErrProductNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("product service: %w", err)
+	}
+	return fromPB(p), nil
+}
+
+// GetProductsByIDs fetches many products in a single call
+func (s *GRPCProductService) GetProductsByIDs(ctx context.Context, ids []int) ([]*Product, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req := &pb.GetProductsRequest{Ids: make([]int64, len(ids))}
+	for i, id := range ids {
+		req.Ids[i] = int64(id)
+	}
+	resp, err := s.client.GetProducts(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("product service: %w", err)
+	}
+	products := make([]*Product, len(resp.GetProducts()))
+	for i, p := range resp.GetProducts() {
+		products[i] = fromPB(p)
+	}
+	return products, nil
+}
+
+func toPB(p *Product) *pb.Product {
+	return &pb.Product{
+		Id:          int64(p.ID),
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       &pb.Money{Amount: p.Price.Amount, Currency: p.Price.Currency},
+	}
+}
+
+func fromPB(p *pb.Product) *Product {
+	return &Product{
+		ID:          int(p.GetId()),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		Price:       money.Money{Amount: p.GetPrice().GetAmount(), Currency: p.GetPrice().GetCurrency()},
+	}
+}
@@ -0,0 +1,103 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCProductServiceRoundTrip(t *testing.T) {
+	kalem := Product{ID: 7, Name: "Kalem", Description: "Mavi", Price: money.Money{Amount: 1250, Currency: "TRY"}}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterProductServiceServer(srv, NewGRPCServer(NewInMemoryProductService([]Product{kalem, {ID: 8, Name: "Silgi"}})))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewGRPCProductService(conn, 0)
+
+	p, err := client.GetProductByID(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != kalem {
+		t.Fatalf("unexpected product %+v", p)
+	}
+	if _, err := client.GetProductByID(context.Background(), 9); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("expected ErrProductNotFound, got %v", err)
+	}
+
+	products, err := client.GetProductsByIDs(context.Background(), []int{7, 8, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expected 2 products from batch (9 is unknown), got %d", len(products))
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetProductsByIDs(cancelled, []int{7, 8}); status.Code(errors.Unwrap(err)) != codes.Canceled {
+		t.Fatalf("expected codes.Canceled for a cancelled batch, got %v", err)
+	}
+}
+
+// slowProductService counts the GetProductByID calls in flight.
+type slowProductService struct {
+	inFlight, peak atomic.Int64
+}
+
+func (s *slowProductService) GetProductByID(ctx context.Context, id int) (*Product, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return &Product{ID: id}, nil
+}
+
+func TestGetProductsBoundsConcurrency(t *testing.T) {
+	svc := &slowProductService{}
+	ids := make([]int, 10*MaxBatchConcurrency)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	products, err := GetProducts(context.Background(), svc, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != len(ids) {
+		t.Fatalf("expected %d products, got %d", len(ids), len(products))
+	}
+	if peak := svc.peak.Load(); peak > MaxBatchConcurrency {
+		t.Fatalf("%d lookups in flight, want at most %d", peak, MaxBatchConcurrency)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GetProducts(ctx, svc, ids); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
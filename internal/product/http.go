@@ -0,0 +1,81 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+// NewHTTPHandler exposes svc over HTTP as GET /products/{id}, returning the product as JSON.
+// Unknown products return 404; any other error returns 503, like an unhealthy upstream would.
+func NewHTTPHandler(svc ProductService) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid product id", http.StatusBadRequest)
+			return
+		}
+		p, err := svc.GetProductByID(r.Context(), id)
+		if errors.Is(err, ErrProductNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	})
+	return mux
+}
+
+// HTTPProductService is a ProductService client for a product service served by NewHTTPHandler.
+type HTTPProductService struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPProductService creates a client for the product service at baseURL, e.g. http://localhost:8081.
+// A nil client uses util.NewHTTPClient with default settings.
+func NewHTTPProductService(baseURL string, client *http.Client) *HTTPProductService {
+	if client == nil {
+		client = util.NewHTTPClient(0, 0)
+	}
+	return &HTTPProductService{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// GetProductByID fetches the product from the remote service
+func (s *HTTPProductService) GetProductByID(ctx context.Context, id int) (*Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/products/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("product service: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("product service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %d", ErrProductNotFound, id)
+		}
+		return nil, fmt.Errorf("product service: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var p Product
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("product service: decode: %w", err)
+	}
+	return &p, nil
+}
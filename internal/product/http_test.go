@@ -0,0 +1,29 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+)
+
+func TestHTTPProductServiceRoundTrip(t *testing.T) {
+	store := NewInMemoryProductService([]Product{{ID: 7, Name: "Kalem", Description: "Mavi", Price: money.Money{Amount: 1250, Currency: "TRY"}}})
+	srv := httptest.NewServer(NewHTTPHandler(store))
+	defer srv.Close()
+
+	client := NewHTTPProductService(srv.URL, nil)
+	p, err := client.GetProductByID(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != (Product{ID: 7, Name: "Kalem", Description: "Mavi", Price: money.Money{Amount: 1250, Currency: "TRY"}}) {
+		t.Fatalf("unexpected product %+v", p)
+	}
+
+	if _, err := client.GetProductByID(context.Background(), 8); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("expected ErrProductNotFound, got %v", err)
+	}
+}
@@ -1,58 +1,96 @@
 package product
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
+	"github.com/idagdelen/go-bottlenecks/internal/money"
 	"github.com/idagdelen/go-bottlenecks/internal/util"
 )
 
 type Product struct {
-	ID          int
-	Name        string
-	Description string
-	Price       float64
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
 }
 
 // ProductService defines the interface for fetching product details
 // as if from an external service.
 type ProductService interface {
-	GetProductByID(id int) (*Product, error)
+	GetProductByID(ctx context.Context, id int) (*Product, error)
 }
 
 // SimulatedProductService simulates an external product service.
-type SimulatedProductService struct{}
+// All randomness (latency, errors and product data) is drawn from rng.
+// If a backend is set, product data comes from it instead of being generated.
+type SimulatedProductService struct {
+	rng       *rand.Rand
+	latency   util.LatencyModel
+	backend   ProductService
+	errorRate float64
+}
 
-// GetProductByID simulates a network call by sleeping for a random duration
-// and returns a randomly generated product. 5% of the time, returns an error.
-func (s *SimulatedProductService) GetProductByID(id int) (*Product, error) {
-	// Simulate network latency between 0ms and 40ms
-	util.SimulateIO(40)
+// GetProductByID simulates a network call by sleeping for a duration sampled from the latency model,
+// returning ctx's error if ctx ends first. It then fails with probability errorRate (simulation.errorRate
+// in the config), and otherwise returns the product from the backend or, without one, a randomly generated product.
+func (s *SimulatedProductService) GetProductByID(ctx context.Context, id int) (*Product, error) {
+	// Simulate network latency (uniform 0ms-40ms unless another model is configured)
+	if err := util.SimulateLatency(ctx, s.rng, s.latency); err != nil {
+		return nil, err
+	}
 
-	// 5% error simulation (generic)
-	if err := util.SimulateError(0.05, "network error: failed to fetch product"); err != nil {
+	if err := util.SimulateErrorWithRand(s.rng, s.errorRate, "network error: failed to fetch product"); err != nil {
 		return nil, err
 	}
 
+	if s.backend != nil {
+		return s.backend.GetProductByID(ctx, id)
+	}
+
 	// Generate random product data
+	rng := util.OrDefaultRand(s.rng)
 	product := &Product{
 		ID:          id,
-		Name:        fmt.Sprintf("Product-%d", rand.Intn(1000)),
+		Name:        fmt.Sprintf("Product-%d", rng.Intn(1000)),
 		Description: "This is a randomly generated product.",
-		Price:       rand.Float64()*100 + 1, // Price between 1 and 100
+		Price:       money.FromMajor(rng.Float64()*100+1, money.DefaultCurrency), // Price between 1 and 100
 	}
 	return product, nil
 }
 
-// NewSimulatedProductService returns a new instance of SimulatedProductService
+// NewSimulatedProductService returns a new instance of SimulatedProductService with a time-based seed
 func NewSimulatedProductService() ProductService {
-	// Seed the random number generator (should be done once in main, but for PoC it's ok here)
-	rand.Seed(time.Now().UnixNano())
-	return &SimulatedProductService{}
+	return NewSimulatedProductServiceWithRand(util.NewRand(time.Now().UnixNano()))
+}
+
+// NewSimulatedProductServiceWithRand returns a SimulatedProductService drawing from rng,
+// so that a run with the same seed replays the same latencies, errors and products.
+func NewSimulatedProductServiceWithRand(rng *rand.Rand) ProductService {
+	return NewSimulatedProductServiceWithLatency(rng, util.DefaultLatency)
+}
+
+// NewSimulatedProductServiceWithLatency returns a SimulatedProductService drawing from rng whose
+// calls take as long as sampled from the given latency model.
+func NewSimulatedProductServiceWithLatency(rng *rand.Rand, latency util.LatencyModel) ProductService {
+	return NewSimulatedProductServiceWithBackend(rng, latency, nil)
+}
+
+// NewSimulatedProductServiceWithBackend layers latency and error simulation on top of backend,
+// e.g. an InMemoryProductService. A nil backend generates random products.
+func NewSimulatedProductServiceWithBackend(rng *rand.Rand, latency util.LatencyModel, backend ProductService) ProductService {
+	return NewSimulatedProductServiceWithErrorRate(rng, latency, backend, util.DefaultErrorRate)
+}
+
+// NewSimulatedProductServiceWithErrorRate is like NewSimulatedProductServiceWithBackend but fails calls with
+// probability errorRate instead of util.DefaultErrorRate.
+func NewSimulatedProductServiceWithErrorRate(rng *rand.Rand, latency util.LatencyModel, backend ProductService, errorRate float64) ProductService {
+	return &SimulatedProductService{rng: rng, latency: latency, backend: backend, errorRate: errorRate}
 }
 
-// FormatPrice returns the product price as a string with two decimals and ₺ suffix
+// FormatPrice returns the product price formatted for money.DefaultLocale, e.g. "₺1.234,50"
 func (p *Product) FormatPrice() string {
-	return fmt.Sprintf("%.2f₺", p.Price)
+	return money.Format(p.Price, money.DefaultLocale)
 }
@@ -0,0 +1,52 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrProductNotFound is returned when a product does not exist in the store.
+var ErrProductNotFound = errors.New("product not found")
+
+// InMemoryProductService is a stateful ProductService backed by a map.
+// Unlike SimulatedProductService's random data, a product looks the same on every call until it is updated.
+type InMemoryProductService struct {
+	mu       sync.RWMutex
+	products map[int]Product
+}
+
+// NewInMemoryProductService creates a store holding the given products
+func NewInMemoryProductService(products []Product) *InMemoryProductService {
+	s := &InMemoryProductService{products: make(map[int]Product, len(products))}
+	for _, p := range products {
+		s.products[p.ID] = p
+	}
+	return s
+}
+
+// GetProductByID returns a copy of the stored product
+func (s *InMemoryProductService) GetProductByID(ctx context.Context, id int) (*Product, error) {
+	s.mu.RLock()
+	p, ok := s.products[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrProductNotFound, id)
+	}
+	return &p, nil
+}
+
+// PutProduct inserts the product or replaces the stored product with the same ID
+func (s *InMemoryProductService) PutProduct(p Product) {
+	s.mu.Lock()
+	s.products[p.ID] = p
+	s.mu.Unlock()
+}
+
+// Len returns the number of stored products
+func (s *InMemoryProductService) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.products)
+}
@@ -0,0 +1,73 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+)
+
+func TestInMemoryProductService(t *testing.T) {
+	kalem := Product{ID: 7, Name: "Kalem", Description: "Mavi", Price: money.Money{Amount: 1250, Currency: "TRY"}}
+	s := NewInMemoryProductService([]Product{kalem, {ID: 8, Name: "Silgi"}})
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 products, got %d", s.Len())
+	}
+
+	p, err := s.GetProductByID(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != kalem {
+		t.Fatalf("unexpected product %+v", p)
+	}
+	p.Name = "Changed by the caller"
+	if again, _ := s.GetProductByID(context.Background(), 7); again.Name != "Kalem" {
+		t.Fatalf("changing a returned product changed the store: %+v", again)
+	}
+	if _, err := s.GetProductByID(context.Background(), 9); !errors.Is(err, ErrProductNotFound) {
+		t.Fatalf("expected ErrProductNotFound, got %v", err)
+	}
+
+	s.PutProduct(Product{ID: 7, Name: "Kurşun Kalem", Price: money.Money{Amount: 900, Currency: "TRY"}})
+	s.PutProduct(Product{ID: 9, Name: "Defter"})
+	if s.Len() != 3 {
+		t.Fatalf("expected 3 products after replacing 7 and adding 9, got %d", s.Len())
+	}
+	if p, _ := s.GetProductByID(context.Background(), 7); p.Name != "Kurşun Kalem" || p.Price.Amount != 900 {
+		t.Fatalf("product 7 not replaced: %+v", p)
+	}
+	if p, err := s.GetProductByID(context.Background(), 9); err != nil || p.Name != "Defter" {
+		t.Fatalf("product 9 not added: %+v, %v", p, err)
+	}
+}
+
+// Run with -race: lookups and updates of the same products must not race.
+func TestInMemoryProductServiceConcurrentUpdates(t *testing.T) {
+	s := NewInMemoryProductService([]Product{{ID: 1, Name: "0"}})
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				s.PutProduct(Product{ID: 1 + i%2, Name: "updated"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if p, err := s.GetProductByID(context.Background(), 1); err != nil || p.ID != 1 {
+					t.Errorf("GetProductByID(1) = %+v, %v", p, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if s.Len() != 2 {
+		t.Fatalf("expected products 1 and 2, got %d products", s.Len())
+	}
+}
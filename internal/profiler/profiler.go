@@ -0,0 +1,248 @@
+// Package profiler captures CPU, heap, allocs, mutex, block and goroutine profiles in the
+// background, so that a regression can be diffed against the profiles of an earlier run or an
+// earlier hour without having to catch it by hand:
+//
+//	go tool pprof -diff_base profiles/20260101T120000Z/heap.pprof profiles/20260101T130000Z/heap.pprof
+//
+// Every round of profiles is a snapshot directory named after its UTC start time, holding one
+// <profile>.pprof file per profile and labels.json with the labels given in Options, e.g. the git
+// revision and a hash of the configuration. Only the newest snapshots are kept.
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profiles lists the profiles a snapshot can contain.
+var Profiles = []string{"cpu", "heap", "allocs", "mutex", "block", "goroutine"}
+
+const (
+	snapshotLayout = "20060102T150405Z"
+	fileExt        = ".pprof"
+	labelsFile     = "labels.json"
+)
+
+// Options configure a Profiler.
+type Options struct {
+	Dir          string            // Directory of the snapshot directories, created on first use
+	Interval     time.Duration     // Time between the starts of two snapshots
+	CPUDuration  time.Duration     // Length of the CPU profile of a snapshot (10s if 0, at most Interval)
+	MaxSnapshots int               // Snapshots kept in Dir (all if 0)
+	Profiles     []string          // Profiles to capture (all of Profiles if empty)
+	Labels       map[string]string // Written to every snapshot's labels.json
+
+	// Mutex and block profiles stay empty unless the runtime samples them. If positive, these are
+	// passed to runtime.SetMutexProfileFraction and runtime.SetBlockProfileRate on Start.
+	MutexProfileFraction int
+	BlockProfileRate     int
+}
+
+// Snapshot describes one round of profiles.
+type Snapshot struct {
+	Name   string            `json:"name"` // Directory name, the UTC start time
+	Time   time.Time         `json:"time"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Files  []string          `json:"files"` // Profile file names, e.g. heap.pprof
+}
+
+// Profiler captures snapshots until Stop is called.
+type Profiler struct {
+	opts Options
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// Start validates opts, captures a first snapshot in the background and then one every interval.
+func Start(opts Options) (*Profiler, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("profiler: no directory")
+	}
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("profiler: interval must be positive, got %v", opts.Interval)
+	}
+	if opts.CPUDuration <= 0 {
+		opts.CPUDuration = 10 * time.Second
+	}
+	opts.CPUDuration = min(opts.CPUDuration, opts.Interval)
+	if len(opts.Profiles) == 0 {
+		opts.Profiles = Profiles
+	}
+	for _, p := range opts.Profiles {
+		if !slices.Contains(Profiles, p) {
+			return nil, fmt.Errorf("profiler: unknown profile %q, must be one of %s", p, strings.Join(Profiles, ", "))
+		}
+	}
+	if opts.MutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(opts.MutexProfileFraction)
+	}
+	if opts.BlockProfileRate > 0 {
+		runtime.SetBlockProfileRate(opts.BlockProfileRate)
+	}
+
+	p := &Profiler{opts: opts, stop: make(chan struct{}), done: make(chan struct{})}
+	go p.run()
+	return p, nil
+}
+
+// Stop ends the profiler, cutting a running CPU profile short, and waits for it.
+func (p *Profiler) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *Profiler) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.snapshot(time.Now()); err != nil {
+			log.Printf("profiler: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// snapshot captures one round. A failing profile is skipped, e.g. the CPU profile while
+// /debug/pprof/profile is running, so that the others are still written.
+func (p *Profiler) snapshot(now time.Time) error {
+	dir := filepath.Join(p.opts.Dir, now.UTC().Format(snapshotLayout))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	labels, err := json.MarshalIndent(p.opts.Labels, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, labelsFile), labels, 0o644); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range p.opts.Profiles {
+		if err := p.capture(dir, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s profile: %w", name, err))
+		}
+	}
+	p.rotate()
+	return errors.Join(errs...)
+}
+
+func (p *Profiler) capture(dir, name string) error {
+	var buf bytes.Buffer
+	if name == "cpu" {
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(p.opts.CPUDuration):
+		case <-p.stop:
+		}
+		pprof.StopCPUProfile()
+	} else if err := pprof.Lookup(name).WriteTo(&buf, 0); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+fileExt), buf.Bytes(), 0o644)
+}
+
+// rotate removes the oldest snapshots beyond MaxSnapshots.
+func (p *Profiler) rotate() {
+	if p.opts.MaxSnapshots <= 0 {
+		return
+	}
+	snapshots, _ := List(p.opts.Dir)
+	for _, s := range snapshots[:max(0, len(snapshots)-p.opts.MaxSnapshots)] {
+		os.RemoveAll(filepath.Join(p.opts.Dir, s.Name))
+	}
+}
+
+// List returns the snapshots in dir, oldest first. A missing directory has none.
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []Snapshot{}
+	for _, e := range entries {
+		t, err := time.Parse(snapshotLayout, e.Name())
+		if !e.IsDir() || err != nil {
+			continue
+		}
+		s := Snapshot{Name: e.Name(), Time: t, Files: []string{}}
+		files, _ := os.ReadDir(filepath.Join(dir, e.Name()))
+		for _, f := range files {
+			if strings.HasSuffix(f.Name(), fileExt) {
+				s.Files = append(s.Files, f.Name())
+			}
+		}
+		if b, err := os.ReadFile(filepath.Join(dir, e.Name(), labelsFile)); err == nil {
+			json.Unmarshal(b, &s.Labels)
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots, nil
+}
+
+// File returns the path of a profile file of a snapshot in dir, or an error if either name is
+// not one that List would return, so that request parameters cannot escape dir.
+func File(dir, snapshot, file string) (string, error) {
+	if _, err := time.Parse(snapshotLayout, snapshot); err != nil {
+		return "", fmt.Errorf("no snapshot %q", snapshot)
+	}
+	profile, ok := strings.CutSuffix(file, fileExt)
+	if !ok || !slices.Contains(Profiles, profile) {
+		return "", fmt.Errorf("no profile file %q", file)
+	}
+	path := filepath.Join(dir, snapshot, file)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// BuildLabels returns the git revision, whether the tree had local modifications and the Go
+// version the binary was built from, as far as the build recorded them.
+func BuildLabels() map[string]string {
+	labels := map[string]string{"go": runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return labels
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			labels["revision"] = s.Value
+		case "vcs.modified":
+			labels["modified"] = s.Value
+		case "vcs.time":
+			labels["revisionTime"] = s.Value
+		}
+	}
+	return labels
+}
@@ -0,0 +1,91 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotsRotateAndList(t *testing.T) {
+	dir := t.TempDir()
+	p := &Profiler{opts: Options{
+		Dir:          dir,
+		CPUDuration:  10 * time.Millisecond,
+		MaxSnapshots: 2,
+		Profiles:     Profiles,
+		Labels:       map[string]string{"revision": "abc123"},
+	}}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		if err := p.snapshot(start.Add(time.Duration(i) * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshots, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != "20260101T130000Z" || snapshots[1].Name != "20260101T140000Z" {
+		t.Fatalf("snapshots = %+v, want the newest two", snapshots)
+	}
+	s := snapshots[1]
+	if s.Labels["revision"] != "abc123" || !s.Time.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("snapshot = %+v", s)
+	}
+	for _, profile := range Profiles {
+		if !slices.Contains(s.Files, profile+".pprof") {
+			t.Errorf("snapshot lacks %s profile: %v", profile, s.Files)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, s.Name, "heap.pprof")); err != nil || info.Size() == 0 {
+		t.Errorf("heap profile: %v", err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "20260101T120000Z"), 0o755)
+	os.WriteFile(filepath.Join(dir, "20260101T120000Z", "heap.pprof"), []byte("x"), 0o644)
+
+	if _, err := File(dir, "20260101T120000Z", "heap.pprof"); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range [][2]string{
+		{"20260101T120000Z", "cpu.pprof"},
+		{"20260101T120000Z", "labels.json"},
+		{"..", "heap.pprof"},
+		{"20260101T120000Z", "../../etc/passwd"},
+	} {
+		if _, err := File(dir, bad[0], bad[1]); err == nil {
+			t.Errorf("File(%q, %q) succeeded", bad[0], bad[1])
+		}
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Start(Options{Dir: dir, Interval: time.Hour, CPUDuration: time.Hour, Profiles: []string{"cpu", "goroutine"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	p.Stop() // Cuts the CPU profile short
+
+	snapshots, _ := List(dir)
+	if len(snapshots) != 1 || len(snapshots[0].Files) != 2 {
+		t.Fatalf("snapshots = %+v", snapshots)
+	}
+	if _, err := Start(Options{Dir: dir, Interval: time.Hour, Profiles: []string{"threadcreate"}}); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestListMissingDir(t *testing.T) {
+	snapshots, err := List(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(snapshots) != 0 {
+		t.Fatalf("List = %v, %v", snapshots, err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package replay records the requests a server handles as JSON lines and replays a recording
+// against a server, at the original pace or faster or slower, comparing the status codes and
+// latencies of the replay with the recorded ones. Only GET requests are recorded, since request
+// bodies are not: method, path, query and the Accept-Language header, which localizes responses.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Record is one recorded request.
+type Record struct {
+	Time   time.Time `json:"time"` // When the request arrived
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Query  string    `json:"query,omitempty"` // Raw query string
+	// AcceptLanguage is the Accept-Language header, which picks the locale of prices
+	AcceptLanguage string  `json:"acceptLanguage,omitempty"`
+	Status         int     `json:"status"`
+	LatencyMs      float64 `json:"latencyMs"` // Time the server took to respond
+}
+
+// Recorder appends a Record for every request it sees to a writer. Lines are buffered and
+// flushed every second, so that recording does not add a write to every request.
+type Recorder struct {
+	prefix string
+
+	mu     sync.Mutex
+	w      *bufio.Writer
+	enc    *json.Encoder
+	closer io.Closer
+	err    error // First write error, after which recording stops
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder returns a Recorder of the requests whose path starts with prefix, writing to w.
+func NewRecorder(w io.Writer, prefix string) *Recorder {
+	bw := bufio.NewWriter(w)
+	r := &Recorder{prefix: prefix, w: bw, enc: json.NewEncoder(bw), stop: make(chan struct{}), done: make(chan struct{})}
+	r.enc.SetEscapeHTML(false) // Keeps & in queries readable
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	go r.flushLoop()
+	return r
+}
+
+// Create returns a Recorder appending to the file at path, which is created if needed.
+func Create(path, prefix string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f, prefix), nil
+}
+
+func (r *Recorder) flushLoop() {
+	defer close(r.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			r.flush()
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// flush must be called with mu held.
+func (r *Recorder) flush() {
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+}
+
+// Add appends rec.
+func (r *Recorder) Add(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(rec)
+	}
+	return r.err
+}
+
+// Close flushes the buffered records and closes the writer if it is an io.Closer. A nil
+// Recorder does nothing.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	close(r.stop)
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush()
+	if r.closer != nil {
+		if err := r.closer.Close(); r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+// Middleware records the GET requests handled by next. A nil Recorder records nothing.
+func (r *Recorder) Middleware(next http.Handler) http.Handler {
+	if r == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || !strings.HasPrefix(req.URL.Path, r.prefix) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+		next.ServeHTTP(ww, req)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		r.Add(Record{
+			Time:           start,
+			Method:         req.Method,
+			Path:           req.URL.Path,
+			Query:          req.URL.RawQuery,
+			AcceptLanguage: req.Header.Get("Accept-Language"),
+			Status:         status,
+			LatencyMs:      float64(time.Since(start).Microseconds()) / 1000,
+		})
+	})
+}
+
+// Read reads records written by a Recorder.
+func Read(r io.Reader) ([]Record, error) {
+	var records []Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
+
+// ReadFile reads the records of a file written by a Recorder.
+func ReadFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,201 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/loadgen"
+)
+
+// Options configure a replay.
+type Options struct {
+	BaseURL string // Scheme and host the recorded paths are sent to, e.g. http://localhost:8080
+
+	// Speed scales the recorded timing: 1 keeps it, 2 replays twice as fast. If 0, requests are
+	// sent back to back by Concurrency workers.
+	Speed float64
+
+	Concurrency int          // Requests in flight at most
+	Client      *http.Client // A client pooling Concurrency connections if nil
+}
+
+// Side summarizes the recording or the replay.
+type Side struct {
+	Elapsed float64          `json:"elapsedSeconds"`
+	Status  map[string]int64 `json:"status"`
+	Latency loadgen.Summary  `json:"latency"`
+}
+
+// Comparison compares a replay with its recording.
+type Comparison struct {
+	URL      string  `json:"url"`
+	Speed    float64 `json:"speed"`
+	Requests int     `json:"requests"`
+	Recorded Side    `json:"recorded"`
+	Replayed Side    `json:"replayed"`
+
+	// StatusChanges counts the requests answered differently, e.g. "200 -> 500"
+	StatusChanges map[string]int `json:"statusChanges"`
+}
+
+// Replay re-issues records in the order they arrived and compares the outcome with the recording.
+// The recorded latencies were measured in the server, the replayed ones in the client, so the
+// replayed ones include the network. If ctx ends the replay early, only the requests that were
+// replayed are compared.
+func Replay(ctx context.Context, records []Record, opts Options) (*Comparison, error) {
+	if len(records) == 0 {
+		return nil, errors.New("no records to replay")
+	}
+	if opts.Speed < 0 {
+		return nil, fmt.Errorf("speed must not be negative, got %g", opts.Speed)
+	}
+	base := strings.TrimSuffix(opts.BaseURL, "/")
+	records = slices.Clone(records)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	c := &Comparison{
+		URL:           base,
+		Speed:         opts.Speed,
+		StatusChanges: map[string]int{},
+	}
+
+	run := loadgen.Options{Mode: loadgen.ModeClosed, Concurrency: opts.Concurrency, Requests: len(records), Client: opts.Client}
+	if opts.Speed > 0 {
+		run.Mode = loadgen.ModeOpen
+		run.Schedule = make([]time.Duration, len(records))
+		for i, rec := range records {
+			run.Schedule[i] = time.Duration(float64(rec.Time.Sub(records[0].Time)) / opts.Speed)
+		}
+	}
+	var mu sync.Mutex
+	replayed := make([]bool, len(records))
+	status := map[string]int64{}
+	run.OnResponse = func(i, code int, _ time.Duration) {
+		if code == 0 && ctx.Err() != nil {
+			return // Cut off by the end of the replay
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		replayed[i] = true
+		status[statusName(code)]++
+		if code != records[i].Status {
+			c.StatusChanges[statusName(records[i].Status)+" -> "+statusName(code)]++
+		}
+	}
+
+	result, err := loadgen.Run(ctx, run, func(i int) (*http.Request, error) {
+		u := base + records[i].Path
+		if records[i].Query != "" {
+			u += "?" + records[i].Query
+		}
+		req, err := http.NewRequest(records[i].Method, u, nil)
+		if err == nil && records[i].AcceptLanguage != "" {
+			req.Header.Set("Accept-Language", records[i].AcceptLanguage)
+		}
+		return req, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var compared []Record
+	for i, rec := range records {
+		if replayed[i] {
+			compared = append(compared, rec)
+		}
+	}
+	if len(compared) == 0 {
+		return nil, fmt.Errorf("no request was replayed: %w", context.Cause(ctx))
+	}
+	c.Requests = len(compared)
+	c.Recorded = summarize(compared)
+	c.Replayed = Side{Elapsed: result.Elapsed, Status: status, Latency: result.ServiceTime}
+	return c, nil
+}
+
+func summarize(records []Record) Side {
+	h := &loadgen.Histogram{}
+	s := Side{Status: map[string]int64{}}
+	for _, rec := range records {
+		h.Record(time.Duration(rec.LatencyMs * float64(time.Millisecond)))
+		s.Status[statusName(rec.Status)]++
+	}
+	s.Elapsed = records[len(records)-1].Time.Sub(records[0].Time).Seconds()
+	s.Latency = h.Summary()
+	return s
+}
+
+func statusName(status int) string {
+	if status == 0 {
+		return "error"
+	}
+	return strconv.Itoa(status)
+}
+
+// WriteText writes c as a table of the recorded and the replayed values.
+func WriteText(w io.Writer, c *Comparison) error {
+	speed := fmt.Sprintf("%gx speed", c.Speed)
+	if c.Speed == 0 {
+		speed = "back to back"
+	}
+	fmt.Fprintf(w, "replayed %d requests against %s at %s\n", c.Requests, c.URL, speed)
+	fmt.Fprintf(w, "  %-14s%10s %10s\n", "", "recorded", "replayed")
+	fmt.Fprintf(w, "  %-14s%10.1f %10.1f\n", "seconds", c.Recorded.Elapsed, c.Replayed.Elapsed)
+	codes := map[string]bool{}
+	for code := range maps.Keys(c.Recorded.Status) {
+		codes[code] = true
+	}
+	for code := range maps.Keys(c.Replayed.Status) {
+		codes[code] = true
+	}
+	for _, code := range slices.Sorted(maps.Keys(codes)) {
+		fmt.Fprintf(w, "  %-14s%10d %10d\n", "status "+code, c.Recorded.Status[code], c.Replayed.Status[code])
+	}
+	for _, row := range []struct {
+		name               string
+		recorded, replayed float64
+	}{
+		{"mean ms", c.Recorded.Latency.Mean, c.Replayed.Latency.Mean},
+		{"p50 ms", c.Recorded.Latency.P50, c.Replayed.Latency.P50},
+		{"p90 ms", c.Recorded.Latency.P90, c.Replayed.Latency.P90},
+		{"p99 ms", c.Recorded.Latency.P99, c.Replayed.Latency.P99},
+		{"p99.9 ms", c.Recorded.Latency.P999, c.Replayed.Latency.P999},
+		{"max ms", c.Recorded.Latency.Max, c.Replayed.Latency.Max},
+	} {
+		fmt.Fprintf(w, "  %-14s%10.2f %10.2f %+8.1f%%\n", row.name, row.recorded, row.replayed, change(row.recorded, row.replayed))
+	}
+	if len(c.StatusChanges) == 0 {
+		_, err := fmt.Fprintln(w, "  every request got its recorded status")
+		return err
+	}
+	fmt.Fprintln(w, "  status changes:")
+	for _, change := range slices.Sorted(maps.Keys(c.StatusChanges)) {
+		fmt.Fprintf(w, "    %-14s%8d\n", change, c.StatusChanges[change])
+	}
+	return nil
+}
+
+func change(before, after float64) float64 {
+	if before == 0 {
+		return 0
+	}
+	return (after - before) / before * 100
+}
+
+// WriteJSON writes c as an indented JSON object.
+func WriteJSON(w io.Writer, c *Comparison) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
@@ -0,0 +1,160 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecorderMiddleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.jsonl")
+	rec, err := Create(path, "/api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := rec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("term") == "" {
+			http.Error(w, "term required", http.StatusBadRequest)
+		}
+	}))
+	for _, target := range []string{"/api/search?term=phone&itemCount=3", "/api/search", "/health"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Language", "en-US")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Bodies are not recorded, so requests with one would be replayed without it
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/stock/reserve", strings.NewReader(`{"items":[]}`)))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want the two /api requests", records)
+	}
+	r := records[0]
+	if r.Method != "GET" || r.Path != "/api/search" || r.Query != "term=phone&itemCount=3" || r.AcceptLanguage != "en-US" || r.Status != 200 || r.Time.IsZero() {
+		t.Errorf("record = %+v", r)
+	}
+	if records[1].Status != http.StatusBadRequest {
+		t.Errorf("record = %+v", records[1])
+	}
+}
+
+func TestNilRecorder(t *testing.T) {
+	var rec *Recorder
+	next := http.NotFoundHandler()
+	if h := rec.Middleware(next); h == nil {
+		t.Error("nil recorder has no middleware")
+	}
+	if err := rec.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadRejectsGarbage(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"method":"GET","path":"/api/search"}` + "\n\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Query().Get("term") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: start.Add(200 * time.Millisecond), Method: "GET", Path: "/api/search", Query: "term=broken", Status: 200, LatencyMs: 30},
+		{Time: start, Method: "GET", Path: "/api/search", Query: "term=phone", Status: 200, LatencyMs: 10},
+		{Time: start.Add(100 * time.Millisecond), Method: "GET", Path: "/api/search", Status: 400, LatencyMs: 1},
+	}
+
+	c, err := Replay(context.Background(), records, Options{BaseURL: srv.URL + "/", Speed: 2, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 3 || c.Requests != 3 {
+		t.Fatalf("%d hits, comparison %+v", hits.Load(), c)
+	}
+	if c.Replayed.Elapsed < 0.1 || c.Replayed.Elapsed > 0.19 {
+		t.Errorf("replay took %.3fs, want the recorded 0.2s at twice the speed", c.Replayed.Elapsed)
+	}
+	if c.Recorded.Elapsed != 0.2 || c.Recorded.Status["200"] != 2 || c.Recorded.Latency.Max != 30 {
+		t.Errorf("recorded = %+v", c.Recorded)
+	}
+	if c.Replayed.Status["200"] != 2 || c.Replayed.Status["500"] != 1 {
+		t.Errorf("replayed = %+v", c.Replayed)
+	}
+	if len(c.StatusChanges) != 2 || c.StatusChanges["200 -> 500"] != 1 || c.StatusChanges["400 -> 200"] != 1 {
+		t.Errorf("status changes = %v", c.StatusChanges)
+	}
+
+	var buf bytes.Buffer
+	WriteText(&buf, c)
+	for _, want := range []string{"replayed 3 requests", "2x speed", "status 500", "200 -> 500", "p99 ms"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("text lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	c, err = Replay(context.Background(), records, Options{BaseURL: srv.URL, Concurrency: 1})
+	if err != nil || c.Replayed.Elapsed > 0.1 || hits.Load() != 6 {
+		t.Errorf("back to back replay: %v, %+v", err, c)
+	}
+	if _, err := Replay(context.Background(), nil, Options{BaseURL: srv.URL, Concurrency: 1}); err == nil {
+		t.Error("empty recording replayed")
+	}
+}
+
+func TestReplayComparesOnlyReplayedRequests(t *testing.T) {
+	var languages []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		languages = append(languages, r.Header.Get("Accept-Language"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: start, Method: "GET", Path: "/api/search", Query: "term=phone", AcceptLanguage: "en-US", Status: 200, LatencyMs: 10},
+		{Time: start, Method: "GET", Path: "/api/search", Query: "term=pen", Status: 200, LatencyMs: 20},
+		// Ctrl-C arrives before this one is due
+		{Time: start.Add(time.Minute), Method: "GET", Path: "/api/search", Query: "term=ink", Status: 500, LatencyMs: 900},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c, err := Replay(ctx, records, Options{BaseURL: srv.URL, Speed: 1, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Requests != 2 || c.Recorded.Status["200"] != 2 || c.Recorded.Status["500"] != 0 || c.Recorded.Latency.Max != 20 {
+		t.Errorf("recorded side of a partial replay = %d requests, %+v", c.Requests, c.Recorded)
+	}
+	if len(c.StatusChanges) != 0 || c.Replayed.Status["200"] != 2 || c.Replayed.Status["error"] != 0 {
+		t.Errorf("replayed = %+v, status changes %v", c.Replayed, c.StatusChanges)
+	}
+	slices.Sort(languages)
+	if !slices.Equal(languages, []string{"", "en-US"}) {
+		t.Errorf("Accept-Language headers replayed = %q", languages)
+	}
+}
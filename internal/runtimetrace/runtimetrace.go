@@ -0,0 +1,244 @@
+// Package runtimetrace captures Go execution traces on demand instead of for the whole process lifetime.
+// Captures and flight recorder dumps are written to timestamped files in one directory, of which only
+// the newest are kept.
+package runtimetrace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrActive is returned when a capture is started while another one is running
+	ErrActive = errors.New("a trace capture is already running")
+	// ErrNotActive is returned when there is no capture to stop
+	ErrNotActive = errors.New("no trace capture is running")
+	// ErrNoFlightRecorder is returned when dumping while the flight recorder is off
+	ErrNoFlightRecorder = errors.New("the flight recorder is not running")
+)
+
+// File name prefixes, followed by a UTC timestamp and the .trace extension
+const (
+	capturePrefix = "trace-"
+	flightPrefix  = "flight-"
+	fileExt       = ".trace"
+)
+
+// Capture describes a running or finished trace capture.
+type Capture struct {
+	File    string    `json:"file"`
+	Started time.Time `json:"started"`
+	Until   time.Time `json:"until"` // Scheduled end of the capture
+}
+
+// Status is a snapshot of the controller.
+type Status struct {
+	Active         *Capture `json:"active,omitempty"`
+	FlightRecorder bool     `json:"flightRecorder"`
+	Files          []string `json:"files"` // Trace files in the directory, oldest first
+}
+
+// Controller starts and stops trace captures and owns the flight recorder. It is safe for concurrent use.
+type Controller struct {
+	dir      string
+	maxFiles int
+
+	mu     sync.Mutex
+	active *Capture
+	file   *os.File
+	timer  *time.Timer
+	flight *trace.FlightRecorder
+}
+
+// NewController returns a controller writing into dir, which is created on first use.
+// After each new file only the newest maxFiles trace files are kept (all if maxFiles <= 0).
+func NewController(dir string, maxFiles int) *Controller {
+	return &Controller{dir: dir, maxFiles: maxFiles}
+}
+
+// Start begins a capture that stops by itself after d.
+func (c *Controller) Start(d time.Duration) (Capture, error) {
+	if d <= 0 {
+		return Capture{}, fmt.Errorf("capture duration must be positive, got %v", d)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active != nil {
+		return Capture{}, ErrActive
+	}
+
+	f, err := c.create(capturePrefix)
+	if err != nil {
+		return Capture{}, err
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return Capture{}, err
+	}
+
+	now := time.Now()
+	capture := &Capture{File: f.Name(), Started: now, Until: now.Add(d)}
+	c.active, c.file = capture, f
+	c.timer = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.active == capture {
+			c.stopLocked()
+		}
+	})
+	return *capture, nil
+}
+
+// Stop ends the running capture early.
+func (c *Controller) Stop() (Capture, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil {
+		return Capture{}, ErrNotActive
+	}
+	capture := *c.active
+	return capture, c.stopLocked()
+}
+
+func (c *Controller) stopLocked() error {
+	c.timer.Stop()
+	trace.Stop()
+	err := c.file.Close()
+	c.active, c.file, c.timer = nil, nil, nil
+	c.rotate()
+	return err
+}
+
+// StartFlightRecorder keeps roughly the last window of execution trace in memory, bounded by
+// maxBytes if positive, so that it can be written out with DumpFlightRecorder when something goes wrong.
+func (c *Controller) StartFlightRecorder(window time.Duration, maxBytes uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flight != nil {
+		return nil
+	}
+	fr := trace.NewFlightRecorder(trace.FlightRecorderConfig{MinAge: window, MaxBytes: maxBytes})
+	if err := fr.Start(); err != nil {
+		return err
+	}
+	c.flight = fr
+	return nil
+}
+
+// DumpFlightRecorder writes the flight recorder's window to a new file and returns its name.
+func (c *Controller) DumpFlightRecorder() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.flight == nil {
+		return "", ErrNoFlightRecorder
+	}
+	f, err := c.create(flightPrefix)
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.flight.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	c.rotate()
+	return f.Name(), nil
+}
+
+// Status returns the running capture, whether the flight recorder is on and the kept files.
+func (c *Controller) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Status{FlightRecorder: c.flight != nil, Files: c.files()}
+	if c.active != nil {
+		capture := *c.active
+		s.Active = &capture
+	}
+	return s
+}
+
+// Close stops the running capture and the flight recorder.
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.active != nil {
+		err = c.stopLocked()
+	}
+	if c.flight != nil {
+		c.flight.Stop()
+		c.flight = nil
+	}
+	return err
+}
+
+// create opens a new file named prefix + UTC timestamp in the directory.
+func (c *Controller) create(prefix string) (*os.File, error) {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := prefix + time.Now().UTC().Format("20060102T150405.000000000Z") + fileExt
+	return os.OpenFile(filepath.Join(c.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+}
+
+// files lists the trace files in the directory by modification time, oldest first.
+func (c *Controller) files() []string {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil
+	}
+	type file struct {
+		name string
+		mod  time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, fileExt) ||
+			!(strings.HasPrefix(name, capturePrefix) || strings.HasPrefix(name, flightPrefix)) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{name: filepath.Join(c.dir, name), mod: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].mod.Equal(files[j].mod) {
+			return files[i].mod.Before(files[j].mod)
+		}
+		return files[i].name < files[j].name
+	})
+
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	return names
+}
+
+// rotate removes the oldest trace files beyond maxFiles. The running capture is never removed.
+func (c *Controller) rotate() {
+	if c.maxFiles <= 0 {
+		return
+	}
+	files := c.files()
+	for _, name := range files[:max(0, len(files)-c.maxFiles)] {
+		if c.active != nil && name == c.active.File {
+			continue
+		}
+		os.Remove(name)
+	}
+}
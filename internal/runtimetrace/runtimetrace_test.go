@@ -0,0 +1,87 @@
+package runtimetrace
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCaptureStopsAndRotates(t *testing.T) {
+	c := NewController(t.TempDir(), 2)
+	defer c.Close()
+
+	var first Capture
+	for i := 0; i < 3; i++ {
+		capture, err := c.Start(time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = capture
+		}
+		if _, err := c.Start(time.Minute); !errors.Is(err, ErrActive) {
+			t.Fatalf("second Start: got %v, want ErrActive", err)
+		}
+		if _, err := c.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Stop(); !errors.Is(err, ErrNotActive) {
+		t.Fatalf("Stop without capture: got %v, want ErrNotActive", err)
+	}
+
+	status := c.Status()
+	if status.Active != nil || len(status.Files) != 2 {
+		t.Fatalf("status = %+v, want no capture and 2 kept files", status)
+	}
+	if _, err := os.Stat(first.File); !os.IsNotExist(err) {
+		t.Fatalf("oldest capture %s was not rotated away", first.File)
+	}
+}
+
+func TestCaptureEndsAfterDuration(t *testing.T) {
+	c := NewController(t.TempDir(), 0)
+	defer c.Close()
+
+	capture, err := c.Start(20 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Status().Active != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("capture did not stop by itself")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info, err := os.Stat(capture.File); err != nil || info.Size() == 0 {
+		t.Fatalf("capture file %s: %v", capture.File, err)
+	}
+}
+
+func TestFlightRecorderDump(t *testing.T) {
+	c := NewController(t.TempDir(), 0)
+	defer c.Close()
+
+	if _, err := c.DumpFlightRecorder(); !errors.Is(err, ErrNoFlightRecorder) {
+		t.Fatalf("dump without recorder: got %v, want ErrNoFlightRecorder", err)
+	}
+	if err := c.StartFlightRecorder(time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+	// A capture may run alongside the flight recorder
+	if _, err := c.Start(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	name, err := c.DumpFlightRecorder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(name); err != nil || info.Size() == 0 {
+		t.Fatalf("flight recorder dump %s: %v", name, err)
+	}
+	if !c.Status().FlightRecorder {
+		t.Fatal("status does not report the flight recorder")
+	}
+}
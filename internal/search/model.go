@@ -9,3 +9,9 @@ type ScoredProduct struct {
 	*Product
 	Score float64 `json:"score"`
 }
+
+// Catalog returns the products known to the in-memory search index.
+// The returned slice is shared and must not be modified.
+func Catalog() []Product {
+	return productMetadata
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 type QdrantSearchResult struct {
@@ -26,8 +27,11 @@ type qdrantSearchResponse struct {
 	} `json:"result"`
 }
 
-func searchProductsQdrant(vector []float32, top int) ([]QdrantSearchResult, error) {
-	url := "http://localhost:6333/collections/products/points/search"
+// DefaultQdrantURL is the base URL of the Qdrant server queried by SearchProductsQdrantByVector.
+const DefaultQdrantURL = "http://localhost:6333"
+
+func searchProductsQdrant(baseURL string, vector []float32, top int) ([]QdrantSearchResult, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/collections/products/points/search"
 	reqBody := map[string]interface{}{
 		"vector": vector,
 		"top":    top,
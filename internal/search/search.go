@@ -43,10 +43,18 @@ func (h *scoredProductMinHeap) Pop() interface{} {
 	return item
 }
 
+// Embed returns the query vector the search functions compare the product vectors with.
+func Embed(text string) []float64 {
+	return getEmbedding(text)
+}
+
 // SearchProducts optimized: only keeps top N results in memory using a min-heap
 func SearchProducts(text string, pageSize int) ([]ScoredProduct, float64) {
-	queryVector := getEmbedding(text)
+	return SearchProductsByVector(getEmbedding(text), pageSize)
+}
 
+// SearchProductsByVector is SearchProducts for an already embedded query.
+func SearchProductsByVector(queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
 	scoredProducts := make([]ScoredProduct, len(productVectors))
 
 	for i, vec := range productVectors {
@@ -76,8 +84,11 @@ go tool pprof http://localhost:6060/debug/pprof/heap
 go tool pprof http://localhost:6060/debug/pprof/profile?seconds=30
 */
 func SearchProductsHeapOptimized(text string, pageSize int) ([]ScoredProduct, float64) {
-	queryVector := getEmbedding(text)
+	return SearchProductsHeapOptimizedByVector(getEmbedding(text), pageSize)
+}
 
+// SearchProductsHeapOptimizedByVector is SearchProductsHeapOptimized for an already embedded query.
+func SearchProductsHeapOptimizedByVector(queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
 	h := &scoredProductMinHeap{}
 	heap.Init(h)
 
@@ -106,12 +117,28 @@ func SearchProductsHeapOptimized(text string, pageSize int) ([]ScoredProduct, fl
 }
 
 func SearchProductsQdrantOptimized(text string, pageSize int) ([]ScoredProduct, float64) {
-	vector := make([]float32, 4)
+	return SearchProductsQdrantByVector(getEmbedding(text), pageSize)
+}
+
+// SearchProductsQdrantByVector is SearchProductsQdrantOptimized for an already embedded query.
+func SearchProductsQdrantByVector(queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
+	return searchQdrantByVector(DefaultQdrantURL, queryVector, pageSize)
+}
+
+// NewQdrantSearcher returns SearchProductsQdrantByVector for the Qdrant server at baseURL.
+func NewQdrantSearcher(baseURL string) func(queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
+	return func(queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
+		return searchQdrantByVector(baseURL, queryVector, pageSize)
+	}
+}
+
+func searchQdrantByVector(baseURL string, queryVector []float64, pageSize int) ([]ScoredProduct, float64) {
+	vector := make([]float32, len(queryVector))
 	for i := range vector {
-		vector[i] = float32(getEmbedding(text)[i])
+		vector[i] = float32(queryVector[i])
 	}
 
-	results, err := searchProductsQdrant(vector, pageSize)
+	results, err := searchProductsQdrant(baseURL, vector, pageSize)
 	if err != nil {
 		return nil, 0
 	}
@@ -0,0 +1,55 @@
+package stock
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxBatchConcurrency bounds the concurrent GetStockByProductID calls of GetStocks for services
+// that cannot fetch many stock entries in one call.
+const MaxBatchConcurrency = 16
+
+// BatchStockService is implemented by stock services that can fetch many stock entries in one call.
+type BatchStockService interface {
+	StockService
+	// GetStocksByProductIDs returns the stock entries that could be fetched, in no particular order.
+	GetStocksByProductIDs(ctx context.Context, ids []int) ([]*Stock, error)
+}
+
+// GetStocks fetches the stock entries with a single call if svc is a BatchStockService, and
+// otherwise with at most MaxBatchConcurrency concurrent GetStockByProductID calls. It returns the
+// stock entries that could be fetched, in no particular order, or ctx's error if ctx is done first.
+func GetStocks(ctx context.Context, svc StockService, ids []int) ([]*Stock, error) {
+	if b, ok := svc.(BatchStockService); ok {
+		return b.GetStocksByProductIDs(ctx, ids)
+	}
+
+	found := make([]*Stock, len(ids))
+	sem := make(chan struct{}, MaxBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			found[i], _ = svc.GetStockByProductID(ctx, id)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stocks := found[:0]
+	for _, s := range found {
+		if s != nil {
+			stocks = append(stocks, s)
+		}
+	}
+	return stocks, nil
+}
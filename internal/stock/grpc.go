@@ -0,0 +1,119 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer adapts a StockService to the generated pb.StockServiceServer.
+type grpcServer struct {
+	pb.UnimplementedStockServiceServer
+	svc StockService
+}
+
+// NewGRPCServer exposes svc as a gRPC StockService. Unknown products return codes.NotFound;
+// any other error returns codes.Unavailable, like an unhealthy upstream would.
+func NewGRPCServer(svc StockService) pb.StockServiceServer {
+	return &grpcServer{svc: svc}
+}
+
+func (s *grpcServer) GetStock(ctx context.Context, req *pb.GetStockRequest) (*pb.Stock, error) {
+	stk, err := s.svc.GetStockByProductID(ctx, int(req.GetProductId()))
+	if errors.Is(err, ErrStockNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return toPB(stk), nil
+}
+
+// GetStocks looks up the IDs with GetStocks, MaxBatchConcurrency at a time, the way a batch endpoint hides
+// per-item latency. It fails with the status of ctx's error if the call ends first.
+func (s *grpcServer) GetStocks(ctx context.Context, req *pb.GetStocksRequest) (*pb.GetStocksResponse, error) {
+	ids := make([]int, len(req.GetProductIds()))
+	for i, id := range req.GetProductIds() {
+		ids[i] = int(id)
+	}
+	stocks, err := GetStocks(ctx, s.svc, ids)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	resp := &pb.GetStocksResponse{Stocks: make([]*pb.Stock, 0, len(stocks))}
+	found := make(map[int]bool, len(stocks))
+	for _, stk := range stocks {
+		found[stk.ProductID] = true
+		resp.Stocks = append(resp.Stocks, toPB(stk))
+	}
+	for _, id := range req.GetProductIds() {
+		if !found[int(id)] {
+			resp.MissingIds = append(resp.MissingIds, id)
+		}
+	}
+	return resp, nil
+}
+
+// GRPCStockService is a StockService client for a stock service served by NewGRPCServer.
+type GRPCStockService struct {
+	client  pb.StockServiceClient
+	timeout time.Duration
+}
+
+// NewGRPCStockService creates a client on conn. Each call is bounded by timeout (5s if zero).
+func NewGRPCStockService(conn grpc.ClientConnInterface, timeout time.Duration) *GRPCStockService {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &GRPCStockService{client: pb.NewStockServiceClient(conn), timeout: timeout}
+}
+
+// GetStockByProductID fetches the stock from the remote service
+func (s *GRPCStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stk, err := s.client.GetStock(ctx, &pb.GetStockRequest{ProductId: int64(id)})
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: %d", ErrStockNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stock service: %w", err)
+	}
+	return fromPB(stk), nil
+}
+
+// GetStocksByProductIDs fetches many stock entries in a single call
+func (s *GRPCStockService) GetStocksByProductIDs(ctx context.Context, ids []int) ([]*Stock, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req := &pb.GetStocksRequest{ProductIds: make([]int64, len(ids))}
+	for i, id := range ids {
+		req.ProductIds[i] = int64(id)
+	}
+	resp, err := s.client.GetStocks(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("stock service: %w", err)
+	}
+	stocks := make([]*Stock, len(resp.GetStocks()))
+	for i, stk := range resp.GetStocks() {
+		stocks[i] = fromPB(stk)
+	}
+	return stocks, nil
+}
+
+func toPB(s *Stock) *pb.Stock {
+	return &pb.Stock{ProductId: int64(s.ProductID), Quantity: int64(s.Quantity)}
+}
+
+func fromPB(s *pb.Stock) *Stock {
+	return &Stock{ProductID: int(s.GetProductId()), Quantity: int(s.GetQuantity())}
+}
@@ -0,0 +1,49 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestGRPCStockServiceRoundTrip(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterStockServiceServer(srv, NewGRPCServer(NewInMemoryStockService(map[int]int{1: 10, 2: 0})))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewGRPCStockService(conn, 0)
+
+	stk, err := client.GetStockByProductID(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stk.Quantity != 10 {
+		t.Fatalf("expected quantity 10, got %d", stk.Quantity)
+	}
+	if _, err := client.GetStockByProductID(context.Background(), 3); !errors.Is(err, ErrStockNotFound) {
+		t.Fatalf("expected ErrStockNotFound, got %v", err)
+	}
+
+	stocks, err := client.GetStocksByProductIDs(context.Background(), []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stocks) != 2 {
+		t.Fatalf("expected 2 stock entries from batch (3 is unknown), got %d", len(stocks))
+	}
+}
@@ -0,0 +1,81 @@
+package stock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+// NewHTTPHandler exposes svc over HTTP as GET /stock/{id}, returning the stock as JSON.
+// Unknown products return 404; any other error returns 503, like an unhealthy upstream would.
+func NewHTTPHandler(svc StockService) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stock/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid product id", http.StatusBadRequest)
+			return
+		}
+		stk, err := svc.GetStockByProductID(r.Context(), id)
+		if errors.Is(err, ErrStockNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stk)
+	})
+	return mux
+}
+
+// HTTPStockService is a StockService client for a stock service served by NewHTTPHandler.
+type HTTPStockService struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPStockService creates a client for the stock service at baseURL, e.g. http://localhost:8082.
+// A nil client uses util.NewHTTPClient with default settings.
+func NewHTTPStockService(baseURL string, client *http.Client) *HTTPStockService {
+	if client == nil {
+		client = util.NewHTTPClient(0, 0)
+	}
+	return &HTTPStockService{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// GetStockByProductID fetches the stock from the remote service
+func (s *HTTPStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/stock/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("stock service: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stock service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %d", ErrStockNotFound, id)
+		}
+		return nil, fmt.Errorf("stock service: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var stk Stock
+	if err := json.NewDecoder(resp.Body).Decode(&stk); err != nil {
+		return nil, fmt.Errorf("stock service: decode: %w", err)
+	}
+	return &stk, nil
+}
@@ -0,0 +1,58 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingStockService fails every lookup, like a stock service whose database is down.
+type failingStockService struct{}
+
+func (failingStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestHTTPStockServiceRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(NewHTTPHandler(NewInMemoryStockService(map[int]int{7: 12})))
+	defer srv.Close()
+
+	client := NewHTTPStockService(srv.URL, nil)
+	stk, err := client.GetStockByProductID(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stk != (Stock{ProductID: 7, Quantity: 12}) {
+		t.Fatalf("unexpected stock %+v", stk)
+	}
+
+	if _, err := client.GetStockByProductID(context.Background(), 8); !errors.Is(err, ErrStockNotFound) {
+		t.Fatalf("expected ErrStockNotFound, got %v", err)
+	}
+}
+
+func TestHTTPStockServiceErrors(t *testing.T) {
+	failing := httptest.NewServer(NewHTTPHandler(failingStockService{}))
+	defer failing.Close()
+	_, err := NewHTTPStockService(failing.URL, nil).GetStockByProductID(context.Background(), 7)
+	if err == nil || errors.Is(err, ErrStockNotFound) || !strings.Contains(err.Error(), "status 503") || !strings.Contains(err.Error(), "database unavailable") {
+		t.Fatalf("expected a 503 error with the service's message, got %v", err)
+	}
+
+	garbled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{not json"))
+	}))
+	defer garbled.Close()
+	if _, err := NewHTTPStockService(garbled.URL, nil).GetStockByProductID(context.Background(), 7); err == nil || !strings.Contains(err.Error(), "decode") {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewHTTPStockService(garbled.URL, nil).GetStockByProductID(ctx, 7); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
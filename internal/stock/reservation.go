@@ -0,0 +1,135 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultReservationTTL is how long a reservation holds its items if it is neither committed nor
+// released, unless the store is given another TTL with SetReservationTTL.
+const DefaultReservationTTL = 15 * time.Minute
+
+var (
+	// ErrInsufficientStock is returned when a reservation asks for more items than are available.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrReservationNotFound is returned when releasing or committing an unknown, already finished or expired reservation.
+	ErrReservationNotFound = errors.New("reservation not found")
+)
+
+// Reservation holds items of a product until it is committed (sold) or released (returned to stock).
+// A reservation that is still outstanding at ExpiresAt is released by the store.
+type Reservation struct {
+	ID        string    `json:"id"`
+	ProductID int       `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Store is a stateful StockService that supports updates and reservations.
+// Quantities reported by GetStockByProductID exclude reserved items, and a Store never oversells:
+// the sum of outstanding and committed reservations never exceeds the stock that was set.
+//
+// SetQuantity sets the unsold stock of a product including the items held by its outstanding
+// reservations, so that releasing them later cannot raise the stock above what was set. It fails
+// with ErrInsufficientStock if more items than quantity are reserved.
+type Store interface {
+	StockService
+	SetQuantity(id, quantity int) error
+	Reserve(productID, quantity int) (*Reservation, error)
+	Release(reservationID string) error
+	Commit(reservationID string) error
+	SetReservationTTL(ttl time.Duration) error
+}
+
+// reservationBook tracks outstanding reservations. It is shared by the Store implementations,
+// which only differ in how they lock the quantities.
+type reservationBook struct {
+	nextID     atomic.Int64
+	ttl        atomic.Int64 // time.Duration
+	nextExpiry atomic.Int64 // Unix nanoseconds of the earliest ExpiresAt, math.MaxInt64 if none
+	now        func() time.Time
+
+	mu   sync.Mutex
+	byID map[string]Reservation
+}
+
+func newReservationBook() *reservationBook {
+	b := &reservationBook{byID: make(map[string]Reservation), now: time.Now}
+	b.ttl.Store(int64(DefaultReservationTTL))
+	b.nextExpiry.Store(math.MaxInt64)
+	return b
+}
+
+func (b *reservationBook) setTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("reservation ttl must be positive")
+	}
+	b.ttl.Store(int64(ttl))
+	return nil
+}
+
+func (b *reservationBook) add(productID, quantity int) *Reservation {
+	r := Reservation{
+		ID:        strconv.FormatInt(b.nextID.Add(1), 10),
+		ProductID: productID,
+		Quantity:  quantity,
+		ExpiresAt: b.now().Add(time.Duration(b.ttl.Load())),
+	}
+	b.mu.Lock()
+	b.byID[r.ID] = r
+	if at := r.ExpiresAt.UnixNano(); at < b.nextExpiry.Load() {
+		b.nextExpiry.Store(at)
+	}
+	b.mu.Unlock()
+	return &r
+}
+
+// take removes the reservation so that it can be released or committed exactly once.
+func (b *reservationBook) take(id string) (Reservation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, ok := b.byID[id]
+	if !ok {
+		return Reservation{}, fmt.Errorf("%w: %s", ErrReservationNotFound, id)
+	}
+	delete(b.byID, id)
+	return r, nil
+}
+
+// expired removes and returns the reservations whose ExpiresAt has passed, for the store to
+// return their items to the available stock. It only scans the book once the earliest
+// reservation has expired, so calling it on every store operation is cheap.
+func (b *reservationBook) expired() []Reservation {
+	now := b.now()
+	if now.UnixNano() < b.nextExpiry.Load() {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var expired []Reservation
+	next := int64(math.MaxInt64)
+	for id, r := range b.byID {
+		if !now.Before(r.ExpiresAt) {
+			expired = append(expired, r)
+			delete(b.byID, id)
+		} else if at := r.ExpiresAt.UnixNano(); at < next {
+			next = at
+		}
+	}
+	b.nextExpiry.Store(next)
+	return expired
+}
+
+func validateReservation(quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	return nil
+}
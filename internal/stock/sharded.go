@@ -0,0 +1,151 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultShardCount is the number of shards used by NewShardedStockService when shards <= 0
+const DefaultShardCount = 64
+
+// stockShard holds the quantities of the products hashed to it. Padding keeps
+// neighbouring shard mutexes on different cache lines to avoid false sharing.
+type stockShard struct {
+	mu        sync.Mutex
+	available map[int]int
+	reserved  map[int]int // Held by outstanding reservations
+	_         [40]byte
+}
+
+// ShardedStockService is a stateful StockService whose products are spread over a fixed
+// number of shards, each guarded by a single mutex. Compared to InMemoryStockService it
+// allocates far fewer mutexes, at the cost of contention between products sharing a shard.
+type ShardedStockService struct {
+	shards       []stockShard
+	reservations *reservationBook
+}
+
+// NewShardedStockService creates a sharded store with the given quantities per product ID
+func NewShardedStockService(quantities map[int]int, shards int) *ShardedStockService {
+	if shards <= 0 {
+		shards = DefaultShardCount
+	}
+	s := &ShardedStockService{
+		shards:       make([]stockShard, shards),
+		reservations: newReservationBook(),
+	}
+	for i := range s.shards {
+		s.shards[i].available = make(map[int]int, len(quantities)/shards+1)
+		s.shards[i].reserved = make(map[int]int)
+	}
+	for id, q := range quantities {
+		s.shard(id).available[id] = q
+	}
+	return s
+}
+
+func (s *ShardedStockService) shard(id int) *stockShard {
+	return &s.shards[uint(id)%uint(len(s.shards))]
+}
+
+// SetReservationTTL sets how long new reservations hold their items (DefaultReservationTTL by default).
+func (s *ShardedStockService) SetReservationTTL(ttl time.Duration) error {
+	return s.reservations.setTTL(ttl)
+}
+
+// expire returns the items of expired reservations to the available stock.
+func (s *ShardedStockService) expire() {
+	for _, r := range s.reservations.expired() {
+		s.returnReserved(r, true)
+	}
+}
+
+// returnReserved ends the hold of r on its items, putting them back into the available stock unless they were sold.
+func (s *ShardedStockService) returnReserved(r Reservation, available bool) {
+	sh := s.shard(r.ProductID)
+	sh.mu.Lock()
+	if available {
+		sh.available[r.ProductID] += r.Quantity
+	}
+	if sh.reserved[r.ProductID] -= r.Quantity; sh.reserved[r.ProductID] == 0 {
+		delete(sh.reserved, r.ProductID)
+	}
+	sh.mu.Unlock()
+}
+
+// GetStockByProductID returns the available (unreserved) stock of the product
+func (s *ShardedStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	s.expire()
+	sh := s.shard(id)
+	sh.mu.Lock()
+	q, ok := sh.available[id]
+	sh.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrStockNotFound, id)
+	}
+	return &Stock{ProductID: id, Quantity: q}, nil
+}
+
+// SetQuantity sets the unsold stock of the product, including reserved items (see Store), creating the entry if needed
+func (s *ShardedStockService) SetQuantity(id, quantity int) error {
+	if quantity < 0 {
+		return fmt.Errorf("quantity must not be negative")
+	}
+	s.expire()
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if reserved := sh.reserved[id]; quantity < reserved {
+		return fmt.Errorf("%w: product %d has %d items reserved, cannot set its stock to %d", ErrInsufficientStock, id, reserved, quantity)
+	}
+	sh.available[id] = quantity - sh.reserved[id]
+	return nil
+}
+
+// Reserve takes quantity items of the product out of the available stock
+func (s *ShardedStockService) Reserve(productID, quantity int) (*Reservation, error) {
+	if err := validateReservation(quantity); err != nil {
+		return nil, err
+	}
+	s.expire()
+	sh := s.shard(productID)
+	sh.mu.Lock()
+	available, ok := sh.available[productID]
+	if !ok {
+		sh.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrStockNotFound, productID)
+	}
+	if available < quantity {
+		sh.mu.Unlock()
+		return nil, fmt.Errorf("%w: product %d has %d, requested %d", ErrInsufficientStock, productID, available, quantity)
+	}
+	sh.available[productID] = available - quantity
+	sh.reserved[productID] += quantity
+	sh.mu.Unlock()
+
+	return s.reservations.add(productID, quantity), nil
+}
+
+// Release returns the reserved items to the available stock
+func (s *ShardedStockService) Release(reservationID string) error {
+	s.expire()
+	r, err := s.reservations.take(reservationID)
+	if err != nil {
+		return err
+	}
+	s.returnReserved(r, true)
+	return nil
+}
+
+// Commit finalizes the reservation; the items stay out of the available stock
+func (s *ShardedStockService) Commit(reservationID string) error {
+	s.expire()
+	r, err := s.reservations.take(reservationID)
+	if err != nil {
+		return err
+	}
+	s.returnReserved(r, false)
+	return nil
+}
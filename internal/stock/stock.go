@@ -1,6 +1,7 @@
 package stock
 
 import (
+	"context"
 	"math/rand"
 	"time"
 
@@ -10,41 +11,75 @@ import (
 // Stock represents the stock information for a product
 // (In a real scenario, this could include more fields)
 type Stock struct {
-	ProductID int
-	Quantity  int
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
 }
 
 // StockService defines the interface for fetching stock details
 // as if from an external service.
 type StockService interface {
-	GetStockByProductID(id int) (*Stock, error)
+	GetStockByProductID(ctx context.Context, id int) (*Stock, error)
 }
 
 // SimulatedStockService simulates an external stock service.
-type SimulatedStockService struct{}
+// All randomness (latency, errors and quantities) is drawn from rng.
+// If a backend is set, stock data comes from it instead of being generated.
+type SimulatedStockService struct {
+	rng       *rand.Rand
+	latency   util.LatencyModel
+	backend   StockService
+	errorRate float64
+}
 
-// GetStockByProductID simulates a network call by sleeping for a random duration
-// and returns a randomly generated stock quantity for the given product ID.
-func (s *SimulatedStockService) GetStockByProductID(id int) (*Stock, error) {
-	// Simulate network latency between 0ms and 40ms
-	util.SimulateIO(40)
+// GetStockByProductID simulates a network call like SimulatedProductService.GetProductByID and returns
+// the stock from the backend or, without one, a randomly generated quantity for the given product ID.
+func (s *SimulatedStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	// Simulate network latency (uniform 0ms-40ms unless another model is configured)
+	if err := util.SimulateLatency(ctx, s.rng, s.latency); err != nil {
+		return nil, err
+	}
 
-	// 5% error simulation (generic)
-	if err := util.SimulateError(0.05, "network error: failed to fetch product"); err != nil {
+	if err := util.SimulateErrorWithRand(s.rng, s.errorRate, "network error: failed to fetch product"); err != nil {
 		return nil, err
 	}
 
+	if s.backend != nil {
+		return s.backend.GetStockByProductID(ctx, id)
+	}
+
 	// Generate random stock quantity between 0 and 100
 	stock := &Stock{
 		ProductID: id,
-		Quantity:  rand.Intn(101),
+		Quantity:  util.OrDefaultRand(s.rng).Intn(101),
 	}
 	return stock, nil
 }
 
-// NewSimulatedStockService returns a new instance of SimulatedStockService
+// NewSimulatedStockService returns a new instance of SimulatedStockService with a time-based seed
 func NewSimulatedStockService() StockService {
-	// Seed the random number generator (should be done once in main, but for PoC it's ok here)
-	rand.Seed(time.Now().UnixNano())
-	return &SimulatedStockService{}
+	return NewSimulatedStockServiceWithRand(util.NewRand(time.Now().UnixNano()))
+}
+
+// NewSimulatedStockServiceWithRand returns a SimulatedStockService drawing from rng,
+// so that a run with the same seed replays the same latencies, errors and quantities.
+func NewSimulatedStockServiceWithRand(rng *rand.Rand) StockService {
+	return NewSimulatedStockServiceWithLatency(rng, util.DefaultLatency)
+}
+
+// NewSimulatedStockServiceWithLatency returns a SimulatedStockService drawing from rng whose
+// calls take as long as sampled from the given latency model.
+func NewSimulatedStockServiceWithLatency(rng *rand.Rand, latency util.LatencyModel) StockService {
+	return NewSimulatedStockServiceWithBackend(rng, latency, nil)
+}
+
+// NewSimulatedStockServiceWithBackend layers latency and error simulation on top of backend,
+// e.g. an InMemoryStockService. A nil backend generates random quantities.
+func NewSimulatedStockServiceWithBackend(rng *rand.Rand, latency util.LatencyModel, backend StockService) StockService {
+	return NewSimulatedStockServiceWithErrorRate(rng, latency, backend, util.DefaultErrorRate)
+}
+
+// NewSimulatedStockServiceWithErrorRate is like NewSimulatedStockServiceWithBackend but fails calls with
+// probability errorRate instead of util.DefaultErrorRate.
+func NewSimulatedStockServiceWithErrorRate(rng *rand.Rand, latency util.LatencyModel, backend StockService, errorRate float64) StockService {
+	return &SimulatedStockService{rng: rng, latency: latency, backend: backend, errorRate: errorRate}
 }
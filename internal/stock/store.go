@@ -0,0 +1,164 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrStockNotFound is returned when the store has no stock entry for a product.
+var ErrStockNotFound = errors.New("stock not found")
+
+// stockEntry is the available and reserved quantity of one product, guarded by its own mutex.
+type stockEntry struct {
+	mu        sync.Mutex
+	available int
+	reserved  int // Held by outstanding reservations
+}
+
+// InMemoryStockService is a stateful StockService backed by a map of quantities per product.
+// Unlike SimulatedStockService's random quantities, a product's stock only changes when it is updated or reserved.
+// Every product has its own mutex, so reservations of different products never contend.
+type InMemoryStockService struct {
+	mu           sync.RWMutex // guards the entries map itself, not the quantities
+	entries      map[int]*stockEntry
+	reservations *reservationBook
+}
+
+// NewInMemoryStockService creates a store with the given quantities per product ID
+func NewInMemoryStockService(quantities map[int]int) *InMemoryStockService {
+	s := &InMemoryStockService{
+		entries:      make(map[int]*stockEntry, len(quantities)),
+		reservations: newReservationBook(),
+	}
+	for id, q := range quantities {
+		s.entries[id] = &stockEntry{available: q}
+	}
+	return s
+}
+
+// SetReservationTTL sets how long new reservations hold their items (DefaultReservationTTL by default).
+func (s *InMemoryStockService) SetReservationTTL(ttl time.Duration) error {
+	return s.reservations.setTTL(ttl)
+}
+
+// expire returns the items of expired reservations to the available stock.
+func (s *InMemoryStockService) expire() {
+	for _, r := range s.reservations.expired() {
+		if e, err := s.entry(r.ProductID); err == nil {
+			e.mu.Lock()
+			e.available += r.Quantity
+			e.reserved -= r.Quantity
+			e.mu.Unlock()
+		}
+	}
+}
+
+func (s *InMemoryStockService) entry(id int) (*stockEntry, error) {
+	s.mu.RLock()
+	e, ok := s.entries[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrStockNotFound, id)
+	}
+	return e, nil
+}
+
+// GetStockByProductID returns the available (unreserved) stock of the product
+func (s *InMemoryStockService) GetStockByProductID(ctx context.Context, id int) (*Stock, error) {
+	s.expire()
+	e, err := s.entry(id)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	q := e.available
+	e.mu.Unlock()
+	return &Stock{ProductID: id, Quantity: q}, nil
+}
+
+// SetQuantity sets the unsold stock of the product, including reserved items (see Store), creating the entry if needed
+func (s *InMemoryStockService) SetQuantity(id, quantity int) error {
+	if quantity < 0 {
+		return fmt.Errorf("quantity must not be negative")
+	}
+	s.expire()
+	e, err := s.entry(id)
+	if err != nil {
+		s.mu.Lock()
+		if e = s.entries[id]; e == nil { // Unless created concurrently since the read above
+			e = &stockEntry{}
+			s.entries[id] = e
+		}
+		s.mu.Unlock()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if quantity < e.reserved {
+		return fmt.Errorf("%w: product %d has %d items reserved, cannot set its stock to %d", ErrInsufficientStock, id, e.reserved, quantity)
+	}
+	e.available = quantity - e.reserved
+	return nil
+}
+
+// Reserve takes quantity items of the product out of the available stock
+func (s *InMemoryStockService) Reserve(productID, quantity int) (*Reservation, error) {
+	if err := validateReservation(quantity); err != nil {
+		return nil, err
+	}
+	s.expire()
+	e, err := s.entry(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if e.available < quantity {
+		available := e.available
+		e.mu.Unlock()
+		return nil, fmt.Errorf("%w: product %d has %d, requested %d", ErrInsufficientStock, productID, available, quantity)
+	}
+	e.available -= quantity
+	e.reserved += quantity
+	e.mu.Unlock()
+
+	return s.reservations.add(productID, quantity), nil
+}
+
+// Release returns the reserved items to the available stock
+func (s *InMemoryStockService) Release(reservationID string) error {
+	s.expire()
+	r, err := s.reservations.take(reservationID)
+	if err != nil {
+		return err
+	}
+	e, err := s.entry(r.ProductID)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.available += r.Quantity
+	e.reserved -= r.Quantity
+	e.mu.Unlock()
+	return nil
+}
+
+// Commit finalizes the reservation; the items stay out of the available stock
+func (s *InMemoryStockService) Commit(reservationID string) error {
+	s.expire()
+	r, err := s.reservations.take(reservationID)
+	if err != nil {
+		return err
+	}
+	e, err := s.entry(r.ProductID)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.reserved -= r.Quantity
+	e.mu.Unlock()
+	return nil
+}
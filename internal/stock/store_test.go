@@ -0,0 +1,221 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newStores(quantities map[int]int) map[string]Store {
+	return map[string]Store{
+		"PerProductMutex": NewInMemoryStockService(quantities),
+		"Sharded":         NewShardedStockService(quantities, 0),
+	}
+}
+
+func TestReserveReleaseCommit(t *testing.T) {
+	for name, s := range newStores(map[int]int{1: 5}) {
+		t.Run(name, func(t *testing.T) {
+			r1, err := s.Reserve(1, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Reserve(1, 3); !errors.Is(err, ErrInsufficientStock) {
+				t.Fatalf("expected ErrInsufficientStock, got %v", err)
+			}
+			r2, err := s.Reserve(1, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Release(r1.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Commit(r2.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Release(r1.ID); !errors.Is(err, ErrReservationNotFound) {
+				t.Fatalf("expected ErrReservationNotFound on double release, got %v", err)
+			}
+
+			stk, err := s.GetStockByProductID(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stk.Quantity != 3 {
+				t.Fatalf("expected 3 items left after releasing 3 and committing 2, got %d", stk.Quantity)
+			}
+		})
+	}
+}
+
+// TestReserveNeverOversells hammers a handful of products from many goroutines.
+// Run it with -race: go test -race -run NeverOversells ./internal/stock
+func TestReserveNeverOversells(t *testing.T) {
+	const (
+		products   = 4
+		perProduct = 100
+		goroutines = 64
+		attempts   = 200
+	)
+	quantities := make(map[int]int, products)
+	for id := 1; id <= products; id++ {
+		quantities[id] = perProduct
+	}
+
+	for name, s := range newStores(quantities) {
+		t.Run(name, func(t *testing.T) {
+			var committed [products + 1]atomic.Int64
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < attempts; i++ {
+						id := (g+i)%products + 1
+						r, err := s.Reserve(id, 1+i%3)
+						if errors.Is(err, ErrInsufficientStock) {
+							continue
+						}
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						// Release every other reservation so stock keeps moving in both directions
+						if i%2 == 0 {
+							if err := s.Release(r.ID); err != nil {
+								t.Error(err)
+							}
+							continue
+						}
+						if err := s.Commit(r.ID); err != nil {
+							t.Error(err)
+						}
+						committed[id].Add(int64(r.Quantity))
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			for id := 1; id <= products; id++ {
+				stk, err := s.GetStockByProductID(context.Background(), id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stk.Quantity < 0 {
+					t.Fatalf("product %d: negative stock %d", id, stk.Quantity)
+				}
+				if sold := committed[id].Load(); sold+int64(stk.Quantity) != perProduct {
+					t.Fatalf("product %d: sold %d + available %d != %d", id, sold, stk.Quantity, perProduct)
+				}
+			}
+		})
+	}
+}
+
+func TestSetQuantityKeepsReservedItems(t *testing.T) {
+	for name, s := range newStores(map[int]int{1: 5}) {
+		t.Run(name, func(t *testing.T) {
+			r, err := s.Reserve(1, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetQuantity(1, 2); !errors.Is(err, ErrInsufficientStock) {
+				t.Fatalf("SetQuantity below the reserved items: expected ErrInsufficientStock, got %v", err)
+			}
+			if err := s.SetQuantity(1, 10); err != nil {
+				t.Fatal(err)
+			}
+			if stk, _ := s.GetStockByProductID(context.Background(), 1); stk.Quantity != 7 {
+				t.Fatalf("expected 7 available while 3 are reserved, got %d", stk.Quantity)
+			}
+			if err := s.Release(r.ID); err != nil {
+				t.Fatal(err)
+			}
+			if stk, _ := s.GetStockByProductID(context.Background(), 1); stk.Quantity != 10 {
+				t.Fatalf("expected the 10 items that were set after the release, got %d", stk.Quantity)
+			}
+		})
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	for name, s := range newStores(map[int]int{1: 5}) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			clock := func() time.Time { return now }
+			switch s := s.(type) {
+			case *InMemoryStockService:
+				s.reservations.now = clock
+			case *ShardedStockService:
+				s.reservations.now = clock
+			}
+			if err := s.SetReservationTTL(time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetReservationTTL(0); err == nil {
+				t.Fatal("expected an error for a zero ttl")
+			}
+
+			expiring, err := s.Reserve(1, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !expiring.ExpiresAt.Equal(now.Add(time.Minute)) {
+				t.Fatalf("expected expiry at %v, got %v", now.Add(time.Minute), expiring.ExpiresAt)
+			}
+			now = now.Add(30 * time.Second)
+			kept, err := s.Reserve(1, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			now = now.Add(30 * time.Second)
+			if stk, _ := s.GetStockByProductID(context.Background(), 1); stk.Quantity != 4 {
+				t.Fatalf("expected the expired reservation back in stock, got %d available", stk.Quantity)
+			}
+			if err := s.Commit(expiring.ID); !errors.Is(err, ErrReservationNotFound) {
+				t.Fatalf("expected ErrReservationNotFound for the expired reservation, got %v", err)
+			}
+			if err := s.Commit(kept.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetQuantity(1, 0); err != nil {
+				t.Fatalf("no items are reserved any more, SetQuantity(0) failed: %v", err)
+			}
+		})
+	}
+}
+
+/*
+go test -bench=BenchmarkReserveRelease -run=^$ ./internal/stock -cpu 1,4,16 -benchmem
+*/
+func BenchmarkReserveRelease(b *testing.B) {
+	const products = 10_000
+	quantities := make(map[int]int, products)
+	for id := 1; id <= products; id++ {
+		quantities[id] = 1 << 30
+	}
+
+	for name, s := range newStores(quantities) {
+		b.Run(name, func(b *testing.B) {
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					id := int(next.Add(1)%products) + 1
+					r, err := s.Reserve(id, 1)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := s.Release(r.ID); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
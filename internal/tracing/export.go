@@ -0,0 +1,257 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter sends finished spans somewhere. Export is called from a single goroutine.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// OpenExporter returns an OTLP/HTTP exporter if otlpEndpoint is set, a JSON lines exporter
+// writing to file ("stdout" for standard output) if file is set, or nil if neither is.
+// Spans are exported as coming from the service named service.
+func OpenExporter(otlpEndpoint, file, service string) (Exporter, error) {
+	switch {
+	case otlpEndpoint != "" && file != "":
+		return nil, fmt.Errorf("tracing: export to an OTLP endpoint or a file, not both")
+	case otlpEndpoint != "":
+		return NewOTLPExporter(otlpEndpoint, service, nil)
+	case file == "stdout":
+		return NewWriterExporter(nopCloser{os.Stdout}, service), nil
+	case file != "":
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+		return NewWriterExporter(f, service), nil
+	}
+	return nil, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// WriterExporter writes one JSON object per span and line, for offline use without a collector.
+type WriterExporter struct {
+	mu      sync.Mutex
+	w       io.WriteCloser
+	service string
+}
+
+// NewWriterExporter returns an exporter writing to w, which is closed on Shutdown.
+func NewWriterExporter(w io.WriteCloser, service string) *WriterExporter {
+	return &WriterExporter{w: w, service: service}
+}
+
+// spanLine is the JSON lines representation of a span.
+type spanLine struct {
+	Service    string         `json:"service"`
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Parent     string         `json:"parentSpanId,omitempty"`
+	Name       string         `json:"name"`
+	Kind       string         `json:"kind"`
+	Start      time.Time      `json:"start"`
+	DurationMs float64        `json:"durationMs"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Export implements Exporter.
+func (e *WriterExporter) Export(ctx context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		line := spanLine{
+			Service:    e.service,
+			TraceID:    s.TraceID.String(),
+			SpanID:     s.SpanID.String(),
+			Name:       s.Name,
+			Kind:       s.Kind.String(),
+			Start:      s.Start,
+			DurationMs: float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			Error:      s.Err,
+		}
+		if s.Parent.IsValid() {
+			line.Parent = s.Parent.String()
+		}
+		if len(s.Attributes) > 0 {
+			line.Attributes = make(map[string]any, len(s.Attributes))
+			for _, a := range s.Attributes {
+				line.Attributes[a.Key] = a.Value
+			}
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown implements Exporter.
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.Close()
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector (or Jaeger, Tempo, ...) with OTLP/HTTP
+// in its JSON encoding.
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter returns an exporter posting to endpoint, e.g. http://localhost:4318. If the
+// endpoint has no path, the standard /v1/traces is used. A nil client times out after 10s.
+func NewOTLPExporter(endpoint, service string, client *http.Client) (*OTLPExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("tracing: OTLP endpoint %q must be an http:// or https:// URL", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OTLPExporter{url: u.String(), service: service, client: client}, nil
+}
+
+// OTLP/JSON message types, see opentelemetry-proto's trace.proto. IDs are hex encoded and
+// 64-bit integers are strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string         `json:"traceId"`
+		SpanID       string         `json:"spanId"`
+		ParentSpanID string         `json:"parentSpanId,omitempty"`
+		Name         string         `json:"name"`
+		Kind         int            `json:"kind"`
+		Start        string         `json:"startTimeUnixNano"`
+		End          string         `json:"endTimeUnixNano"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		Status       otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		String *string  `json:"stringValue,omitempty"`
+		Int    *string  `json:"intValue,omitempty"`
+		Double *float64 `json:"doubleValue,omitempty"`
+		Bool   *bool    `json:"boolValue,omitempty"`
+	}
+)
+
+func otlpAttribute(a Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: a.Key}
+	switch v := a.Value.(type) {
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.Int = &s
+	case float64:
+		kv.Value.Double = &v
+	case bool:
+		kv.Value.Bool = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.String = &s
+	}
+	return kv
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/idagdelen/go-bottlenecks"}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID: s.TraceID.String(),
+			SpanID:  s.SpanID.String(),
+			Name:    s.Name,
+			Kind:    int(s.Kind),
+			Start:   strconv.FormatInt(s.Start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = s.Parent.String()
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttribute(a))
+		}
+		if s.Failed {
+			span.Status = otlpStatus{Code: 2, Message: s.Err}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttribute(String("service.name", e.service))}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP endpoint %s: status %d: %s", e.url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package tracing records OpenTelemetry-style spans without the OpenTelemetry SDK. Spans are
+// batched and handed to an Exporter, e.g. OTLP/HTTP to a collector or JSON lines to a file, and
+// span contexts cross process boundaries in W3C traceparent headers.
+//
+// A Tracer starts root spans, typically in its HTTP middleware. Code further down the call chain
+// starts child spans with the package-level Start, which uses the tracer of the span in the context
+// and does nothing if there is none, so instrumented code needs no tracer of its own.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that is propagated to children and to other services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether sc has a trace and a span ID.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// SpanKind describes the relationship of a span to the other spans of its trace. The values
+// match the OTLP enum.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Attribute is a key-value pair describing a span. Values are strings, int64s, float64s or bools.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{key, int64(value)} }
+
+// Float returns a floating-point attribute.
+func Float(key string, value float64) Attribute { return Attribute{key, value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// SpanData is a finished span as handed to exporters.
+type SpanData struct {
+	SpanContext
+	Parent     SpanID
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	Err        string // Status message if the span failed, empty if it did not
+	Failed     bool
+}
+
+// Span is an operation being timed. A nil *Span, returned when there is no tracer, ignores
+// every call, so callers never have to check.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu   sync.Mutex
+	data SpanData
+	done bool
+}
+
+// Context returns the span's context, the zero SpanContext for a nil span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the span's name, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err's message. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Failed = true
+	s.data.Err = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying s.
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteParent returns a copy of ctx in which spans started by a Tracer continue the
+// trace of sc, which came from another process (see Extract).
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanOption configures a span at start.
+type SpanOption func(*Span)
+
+// WithKind sets the span kind (KindInternal by default).
+func WithKind(kind SpanKind) SpanOption {
+	return func(s *Span) { s.data.Kind = kind }
+}
+
+// WithAttributes sets attributes at start.
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(s *Span) { s.data.Attributes = append(s.data.Attributes, attrs...) }
+}
+
+// Start starts a child of the span in ctx, using that span's tracer. Without a span in ctx it
+// returns ctx and a nil span.
+func Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name, opts...)
+}
+
+// Options configure a Tracer.
+type Options struct {
+	SampleRatio float64       // Share of new traces that are recorded; traces continued from another service follow its decision
+	QueueSize   int           // Finished spans buffered for export (2048 if 0); spans are dropped when it is full
+	BatchSize   int           // Spans per export (512 if 0)
+	Interval    time.Duration // Time between exports (1s if 0)
+}
+
+// Tracer starts spans and exports them in batches from a background goroutine. A nil *Tracer
+// starts no spans.
+type Tracer struct {
+	exporter Exporter
+	opts     Options
+
+	queue    chan SpanData
+	flush    chan chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+	dropped  atomic.Int64
+}
+
+// NewTracer returns a tracer exporting to exporter.
+func NewTracer(exporter Exporter, opts Options) *Tracer {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 2048
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	t := &Tracer{
+		exporter: exporter,
+		opts:     opts,
+		queue:    make(chan SpanData, opts.QueueSize),
+		flush:    make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span. It is a child of the span in ctx if there is one, else of the remote
+// parent in ctx if there is one, else the root of a new trace.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{Name: name, Kind: KindInternal, Start: time.Now()}}
+	switch parent, remote := SpanFromContext(ctx), ctx.Value(remoteKey{}); {
+	case parent != nil:
+		s.sc = SpanContext{TraceID: parent.sc.TraceID, Sampled: parent.sc.Sampled}
+		s.data.Parent = parent.sc.SpanID
+	case remote != nil:
+		sc := remote.(SpanContext)
+		s.sc = SpanContext{TraceID: sc.TraceID, Sampled: sc.Sampled}
+		s.data.Parent = sc.SpanID
+	default:
+		rand.Read(s.sc.TraceID[:])
+		s.sc.Sampled = t.sample(s.sc.TraceID)
+	}
+	rand.Read(s.sc.SpanID[:])
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.data.SpanContext = s.sc
+	return ContextWithSpan(ctx, s), s
+}
+
+// sample decides from the trace ID, so that every service sampling at the same ratio agrees.
+func (t *Tracer) sample(id TraceID) bool {
+	switch {
+	case t.opts.SampleRatio >= 1:
+		return true
+	case t.opts.SampleRatio <= 0:
+		return false
+	}
+	var x uint64
+	for _, b := range id[8:] {
+		x = x<<8 | uint64(b)
+	}
+	return x < uint64(t.opts.SampleRatio*math.MaxUint64)
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of spans dropped because the export queue was full.
+func (t *Tracer) Dropped() int64 { return t.dropped.Load() }
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.opts.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			log.Printf("tracing: exporting %d spans: %v", len(batch), err)
+		}
+		cancel()
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) == t.opts.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) == t.opts.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-t.flush:
+			drain()
+			close(done)
+		case <-t.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// Flush exports the spans ended so far.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans and shuts the exporter down. Spans ended afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.stopOnce.Do(func() { close(t.stop) })
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recorder collects exported spans.
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) Export(ctx context.Context, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recorder) Shutdown(ctx context.Context) error { return nil }
+
+func (r *recorder) byName(t *testing.T, name string) SpanData {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %q in %d spans", name, len(r.spans))
+	return SpanData{}
+}
+
+func newTestTracer(t *testing.T) (*Tracer, *recorder) {
+	rec := &recorder{}
+	tr := NewTracer(rec, Options{SampleRatio: 1})
+	t.Cleanup(func() { tr.Shutdown(context.Background()) })
+	return tr, rec
+}
+
+func TestParentChild(t *testing.T) {
+	tr, rec := newTestTracer(t)
+
+	ctx, root := tr.Start(context.Background(), "root")
+	_, child := Start(ctx, "child", WithAttributes(Int("n", 3)))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r, c := rec.byName(t, "root"), rec.byName(t, "child")
+	if r.Parent.IsValid() {
+		t.Errorf("root has parent %s", r.Parent)
+	}
+	if c.TraceID != r.TraceID || c.Parent != r.SpanID {
+		t.Errorf("child %s/%s, want trace %s parent %s", c.TraceID, c.Parent, r.TraceID, r.SpanID)
+	}
+	if !c.Failed || c.Err != "boom" {
+		t.Errorf("child error = %v %q", c.Failed, c.Err)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0] != Int("n", 3) {
+		t.Errorf("child attributes = %v", c.Attributes)
+	}
+}
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "orphan")
+	if span != nil || ctx != context.Background() {
+		t.Fatalf("Start without a parent = %v", span)
+	}
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+}
+
+func TestSampling(t *testing.T) {
+	rec := &recorder{}
+	tr := NewTracer(rec, Options{SampleRatio: 0})
+	_, span := tr.Start(context.Background(), "unsampled")
+	span.End()
+	tr.Shutdown(context.Background())
+	if len(rec.spans) != 0 {
+		t.Fatalf("exported %d spans at ratio 0", len(rec.spans))
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	const v = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("parsed %+v", sc)
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("ParseTraceparent(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMiddlewareContinuesRemoteTrace(t *testing.T) {
+	tr, rec := newTestTracer(t)
+
+	r := chi.NewRouter()
+	r.Use(tr.Middleware)
+	r.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "lookup")
+		span.End()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	tr.Flush(context.Background())
+
+	server := rec.byName(t, "GET /products/{id}")
+	if server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("server span %s/%s does not continue the remote trace", server.TraceID, server.Parent)
+	}
+	if server.Kind != KindServer || !server.Failed {
+		t.Errorf("server span kind %v, failed %v", server.Kind, server.Failed)
+	}
+	if lookup := rec.byName(t, "lookup"); lookup.Parent != server.SpanID {
+		t.Errorf("lookup parent %s, want %s", lookup.Parent, server.SpanID)
+	}
+}
+
+func TestTransportInjectsTraceparent(t *testing.T) {
+	tr, rec := newTestTracer(t)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(TraceparentHeader)
+	}))
+	defer srv.Close()
+
+	ctx, root := tr.Start(context.Background(), "root")
+	client := &http.Client{Transport: NewTransport(nil)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	root.End()
+	tr.Flush(context.Background())
+
+	sc, err := ParseTraceparent(got)
+	if err != nil {
+		t.Fatalf("server got traceparent %q: %v", got, err)
+	}
+	span := rec.byName(t, "HTTP GET")
+	if sc.TraceID != root.Context().TraceID || sc.SpanID != span.SpanID {
+		t.Errorf("traceparent %q, want trace %s span %s", got, root.Context().TraceID, span.SpanID)
+	}
+	if span.Parent != root.Context().SpanID || span.Kind != KindClient {
+		t.Errorf("client span parent %s kind %v", span.Parent, span.Kind)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]any
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer srv.Close()
+
+	exp, err := NewOTLPExporter(srv.URL, "test-service", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTracer(exp, Options{SampleRatio: 1})
+	_, span := tr.Start(context.Background(), "op", WithAttributes(String("k", "v"), Int("n", 1)))
+	span.RecordError(errors.New("failed"))
+	span.End()
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/traces" {
+		t.Errorf("posted to %q", path)
+	}
+	b, _ := json.Marshal(body)
+	for _, want := range []string{
+		`"stringValue":"test-service"`,
+		`"name":"op"`,
+		`"traceId":"` + span.Context().TraceID.String() + `"`,
+		`"intValue":"1"`,
+		`"status":{"code":2,"message":"failed"}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("request lacks %s:\n%s", want, b)
+		}
+	}
+
+	if _, err := NewOTLPExporter("localhost:4318", "x", nil); err == nil {
+		t.Error("endpoint without scheme accepted")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var b strings.Builder
+	exp := NewWriterExporter(nopCloser{&b}, "svc")
+	tr := NewTracer(exp, Options{SampleRatio: 1})
+	_, span := tr.Start(context.Background(), "op", WithAttributes(Bool("ok", true)))
+	span.End()
+	tr.Shutdown(context.Background())
+
+	var line spanLine
+	if err := json.Unmarshal([]byte(b.String()), &line); err != nil {
+		t.Fatalf("%v: %q", err, b.String())
+	}
+	if line.Service != "svc" || line.Name != "op" || line.Attributes["ok"] != true || line.Kind != "internal" {
+		t.Fatalf("line = %+v", line)
+	}
+}
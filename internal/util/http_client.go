@@ -0,0 +1,25 @@
+package util
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+)
+
+// NewHTTPClient returns an http.Client with a connection pool sized for fan-out calls to one host.
+// The default transport keeps only 2 idle connections per host, which makes concurrent
+// enrichment open and close connections constantly. Zero values pick 100 connections and a 5s timeout.
+// Requests made with a traced context get a client span and a traceparent header.
+func NewHTTPClient(maxIdleConnsPerHost int, timeout time.Duration) *http.Client {
+	if maxIdleConnsPerHost <= 0 {
+		maxIdleConnsPerHost = 100
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConnsPerHost
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	return &http.Client{Transport: tracing.NewTransport(transport), Timeout: timeout}
+}
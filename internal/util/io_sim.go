@@ -1,14 +1,19 @@
 package util
 
 import (
-	"fmt"
+	"errors"
 	"math/rand"
 	"time"
 )
 
 // SimulateIO simulates an IO-bound wait with a random delay between 0-40ms
 func SimulateIO(ms int) {
-	delay := time.Duration(rand.Intn(ms+1)) * time.Millisecond
+	SimulateIOWithRand(defaultRand, ms)
+}
+
+// SimulateIOWithRand is like SimulateIO but draws the delay from rng (the default RNG if nil)
+func SimulateIOWithRand(rng *rand.Rand, ms int) {
+	delay := time.Duration(OrDefaultRand(rng).Intn(ms+1)) * time.Millisecond
 	ch := make(chan struct{})
 	select {
 	case <-time.After(delay):
@@ -18,11 +23,20 @@ func SimulateIO(ms int) {
 	}
 }
 
+// DefaultErrorRate is the probability that a call to a simulated service fails with a generic
+// network error, drawn with SimulateErrorWithRand, unless the service is given another rate
+const DefaultErrorRate = 0.05
+
 // SimulateError returns an error with the given probability (0.0-1.0). If no error, returns nil.
 // Example: probability=0.05 means 5% chance to return error.
 func SimulateError(probability float64, errMsg string) error {
-	if rand.Float64() < probability {
-		return fmt.Errorf(errMsg)
+	return SimulateErrorWithRand(defaultRand, probability, errMsg)
+}
+
+// SimulateErrorWithRand is like SimulateError but draws from rng (the default RNG if nil)
+func SimulateErrorWithRand(rng *rand.Rand, probability float64, errMsg string) error {
+	if OrDefaultRand(rng).Float64() < probability {
+		return errors.New(errMsg)
 	}
 	return nil
 }
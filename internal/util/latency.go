@@ -0,0 +1,309 @@
+package util
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LatencyModel describes how long a simulated call takes.
+// Implementations must be safe for concurrent use; randomness comes from the given rng.
+type LatencyModel interface {
+	Sample(rng *rand.Rand) time.Duration
+	String() string
+}
+
+// MaxLatency caps every simulated latency, see SimulateLatency. The random models clamp their samples
+// to it, because heavy tails such as a Pareto with a small Alpha would otherwise draw hours, or
+// overflow time.Duration.
+const MaxLatency = time.Minute
+
+// clampLatency converts a sampled duration in nanoseconds to a Duration in [0, MaxLatency].
+func clampLatency(ns float64) time.Duration {
+	if ns >= float64(MaxLatency) {
+		return MaxLatency
+	}
+	if ns <= 0 || math.IsNaN(ns) {
+		return 0
+	}
+	return time.Duration(ns)
+}
+
+// DefaultLatency matches the original SimulateIO(40) behaviour: uniform between 0 and 40ms
+var DefaultLatency LatencyModel = UniformLatency{Min: 0, Max: 40 * time.Millisecond}
+
+// ConstantLatency always waits D.
+type ConstantLatency struct {
+	D time.Duration
+}
+
+func (l ConstantLatency) Sample(*rand.Rand) time.Duration { return l.D }
+func (l ConstantLatency) String() string                  { return fmt.Sprintf("constant:%s", l.D) }
+
+// UniformLatency waits a uniformly distributed duration in [Min, Max].
+type UniformLatency struct {
+	Min, Max time.Duration
+}
+
+func (l UniformLatency) Sample(rng *rand.Rand) time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + time.Duration(rng.Int63n(int64(l.Max-l.Min)+1))
+}
+func (l UniformLatency) String() string { return fmt.Sprintf("uniform:%s,%s", l.Min, l.Max) }
+
+// NormalLatency waits a normally distributed duration, clamped at zero.
+type NormalLatency struct {
+	Mean, StdDev time.Duration
+}
+
+func (l NormalLatency) Sample(rng *rand.Rand) time.Duration {
+	return clampLatency(rng.NormFloat64()*float64(l.StdDev) + float64(l.Mean))
+}
+func (l NormalLatency) String() string { return fmt.Sprintf("normal:%s,%s", l.Mean, l.StdDev) }
+
+// LogNormalLatency waits a log-normally distributed duration with the given median.
+// Sigma is the standard deviation of the underlying normal distribution; larger values give a longer tail.
+type LogNormalLatency struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (l LogNormalLatency) Sample(rng *rand.Rand) time.Duration {
+	return clampLatency(float64(l.Median) * math.Exp(l.Sigma*rng.NormFloat64()))
+}
+func (l LogNormalLatency) String() string { return fmt.Sprintf("lognormal:%s,%g", l.Median, l.Sigma) }
+
+// ParetoLatency waits a Pareto distributed duration: never less than Scale, with a long tail
+// controlled by Alpha (smaller Alpha means heavier tail).
+type ParetoLatency struct {
+	Scale time.Duration
+	Alpha float64
+}
+
+func (l ParetoLatency) Sample(rng *rand.Rand) time.Duration {
+	u := 1 - rng.Float64() // (0, 1]
+	return clampLatency(float64(l.Scale) / math.Pow(u, 1/l.Alpha))
+}
+func (l ParetoLatency) String() string { return fmt.Sprintf("pareto:%s,%g", l.Scale, l.Alpha) }
+
+// LatencyBucket is one bucket of an empirical latency histogram.
+// It covers durations up to UpperBound, starting at the previous bucket's UpperBound.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// EmpiricalLatency samples from a recorded latency histogram.
+// A bucket is chosen with probability proportional to its count, then a duration is
+// drawn uniformly within the bucket.
+type EmpiricalLatency struct {
+	source     string
+	buckets    []LatencyBucket
+	cumulative []int
+}
+
+// NewEmpiricalLatency builds an EmpiricalLatency from histogram buckets.
+func NewEmpiricalLatency(buckets []LatencyBucket) (*EmpiricalLatency, error) {
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("empirical latency: no buckets")
+	}
+	sorted := append([]LatencyBucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UpperBound < sorted[j].UpperBound })
+
+	l := &EmpiricalLatency{buckets: sorted, cumulative: make([]int, len(sorted))}
+	total := 0
+	for i, b := range sorted {
+		if b.Count < 0 || b.UpperBound < 0 {
+			return nil, fmt.Errorf("empirical latency: invalid bucket %s %d", b.UpperBound, b.Count)
+		}
+		total += b.Count
+		l.cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("empirical latency: histogram is empty")
+	}
+	return l, nil
+}
+
+// LoadEmpiricalLatency reads a histogram file with one "<upper bound> <count>" pair per line,
+// e.g. "25ms 130". Empty lines and lines starting with # are ignored.
+func LoadEmpiricalLatency(path string) (*EmpiricalLatency, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("empirical latency: %w", err)
+	}
+	defer f.Close()
+
+	var buckets []LatencyBucket
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("empirical latency: %s:%d: expected \"<upper bound> <count>\"", path, line)
+		}
+		upper, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("empirical latency: %s:%d: %w", path, line, err)
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("empirical latency: %s:%d: %w", path, line, err)
+		}
+		buckets = append(buckets, LatencyBucket{UpperBound: upper, Count: count})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("empirical latency: %w", err)
+	}
+
+	l, err := NewEmpiricalLatency(buckets)
+	if err != nil {
+		return nil, err
+	}
+	l.source = path
+	return l, nil
+}
+
+func (l *EmpiricalLatency) Sample(rng *rand.Rand) time.Duration {
+	n := rng.Intn(l.cumulative[len(l.cumulative)-1])
+	i := sort.SearchInts(l.cumulative, n+1)
+	var lower time.Duration
+	if i > 0 {
+		lower = l.buckets[i-1].UpperBound
+	}
+	return UniformLatency{Min: lower, Max: l.buckets[i].UpperBound}.Sample(rng)
+}
+
+func (l *EmpiricalLatency) String() string {
+	if l.source != "" {
+		return "empirical:" + l.source
+	}
+	return fmt.Sprintf("empirical:%d buckets", len(l.buckets))
+}
+
+// SimulateLatency waits for a duration sampled from model, at most MaxLatency, drawing from rng
+// (the default RNG if nil). It returns ctx.Err() if ctx is done first, like a network call abandoned by its caller.
+func SimulateLatency(ctx context.Context, rng *rand.Rand, model LatencyModel) error {
+	if model == nil {
+		model = DefaultLatency
+	}
+	d := min(model.Sample(OrDefaultRand(rng)), MaxLatency)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseLatencyModel parses a latency spec of the form "<kind>:<args>":
+//
+//	constant:10ms
+//	uniform:0ms,40ms
+//	normal:20ms,5ms        (mean, stddev)
+//	lognormal:15ms,0.6     (median, sigma)
+//	pareto:5ms,1.5         (scale, alpha)
+//	empirical:latency.hist (histogram file, see LoadEmpiricalLatency)
+func ParseLatencyModel(spec string) (LatencyModel, error) {
+	kind, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	if kind == "empirical" {
+		return LoadEmpiricalLatency(args)
+	}
+
+	parts := strings.Split(args, ",")
+	durationArg := func(i int) (time.Duration, error) {
+		d, err := time.ParseDuration(strings.TrimSpace(parts[i]))
+		if err != nil {
+			return 0, fmt.Errorf("latency %q: %w", spec, err)
+		}
+		if d < 0 {
+			return 0, fmt.Errorf("latency %q: negative duration", spec)
+		}
+		return d, nil
+	}
+	floatArg := func(i int) (float64, error) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil || f <= 0 {
+			return 0, fmt.Errorf("latency %q: expected a positive number, got %q", spec, parts[i])
+		}
+		return f, nil
+	}
+	wantArgs := map[string]int{"constant": 1, "uniform": 2, "normal": 2, "lognormal": 2, "pareto": 2}
+	n, ok := wantArgs[kind]
+	if !ok {
+		return nil, fmt.Errorf("latency %q: unknown model %q", spec, kind)
+	}
+	if len(parts) != n {
+		return nil, fmt.Errorf("latency %q: %s expects %d argument(s)", spec, kind, n)
+	}
+
+	first, err := durationArg(0)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "constant":
+		return ConstantLatency{D: first}, nil
+	case "uniform", "normal":
+		second, err := durationArg(1)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "uniform" {
+			if second < first {
+				return nil, fmt.Errorf("latency %q: max is less than min", spec)
+			}
+			return UniformLatency{Min: first, Max: second}, nil
+		}
+		return NormalLatency{Mean: first, StdDev: second}, nil
+	default:
+		second, err := floatArg(1)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "lognormal" {
+			return LogNormalLatency{Median: first, Sigma: second}, nil
+		}
+		return ParetoLatency{Scale: first, Alpha: second}, nil
+	}
+}
+
+// LoadLatencyConfig reads a JSON file mapping service names to latency specs,
+// e.g. {"product": "lognormal:15ms,0.6", "stock": "pareto:5ms,1.8"}.
+func LoadLatencyConfig(path string) (map[string]LatencyModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("latency config: %w", err)
+	}
+	var specs map[string]string
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("latency config %s: %w", path, err)
+	}
+	models := make(map[string]LatencyModel, len(specs))
+	for name, spec := range specs {
+		m, err := ParseLatencyModel(spec)
+		if err != nil {
+			return nil, fmt.Errorf("latency config %s: %s: %w", path, name, err)
+		}
+		models[name] = m
+	}
+	return models, nil
+}
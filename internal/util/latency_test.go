@@ -0,0 +1,94 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseLatencyModel(t *testing.T) {
+	tests := []struct {
+		spec string
+		want LatencyModel
+	}{
+		{"constant:10ms", ConstantLatency{D: 10 * time.Millisecond}},
+		{"uniform:0ms,40ms", UniformLatency{Min: 0, Max: 40 * time.Millisecond}},
+		{"normal:20ms,5ms", NormalLatency{Mean: 20 * time.Millisecond, StdDev: 5 * time.Millisecond}},
+		{"lognormal:15ms,0.6", LogNormalLatency{Median: 15 * time.Millisecond, Sigma: 0.6}},
+		{"pareto:5ms,1.5", ParetoLatency{Scale: 5 * time.Millisecond, Alpha: 1.5}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLatencyModel(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseLatencyModel(%q): %v", tt.spec, err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseLatencyModel(%q) = %#v, want %#v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "gamma:1ms", "uniform:40ms,0ms", "pareto:5ms,-1", "constant:abc"} {
+		if _, err := ParseLatencyModel(spec); err == nil {
+			t.Fatalf("ParseLatencyModel(%q): expected error", spec)
+		}
+	}
+}
+
+func TestEmpiricalLatency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latency.hist")
+	hist := "# upper bound, count\n10ms 0\n20ms 5\n"
+	if err := os.WriteFile(path, []byte(hist), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ParseLatencyModel("empirical:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := NewRand(1)
+	for i := 0; i < 1000; i++ {
+		// All samples must fall into the only non-empty bucket
+		if d := m.Sample(rng); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("sample %s outside of (10ms, 20ms]", d)
+		}
+	}
+}
+
+func TestHeavyTailsClampedToMaxLatency(t *testing.T) {
+	rng := NewRand(1)
+	var capped bool
+	for _, m := range []LatencyModel{
+		ParetoLatency{Scale: 5 * time.Millisecond, Alpha: 0.01},
+		LogNormalLatency{Median: 15 * time.Millisecond, Sigma: 50},
+		NormalLatency{Mean: 0, StdDev: 1000 * time.Hour},
+	} {
+		for i := 0; i < 1000; i++ {
+			d := m.Sample(rng)
+			if d < 0 || d > MaxLatency {
+				t.Fatalf("%s: sample %s outside of [0, %s]", m, d, MaxLatency)
+			}
+			capped = capped || d == MaxLatency
+		}
+	}
+	if !capped {
+		t.Fatal("no sample reached MaxLatency")
+	}
+}
+
+func TestSimulateLatencyEndsWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := SimulateLatency(ctx, nil, ConstantLatency{D: time.Hour}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("SimulateLatency returned after %s, want shortly after the 10ms deadline", elapsed)
+	}
+	if err := SimulateLatency(context.Background(), nil, ConstantLatency{D: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedSource makes a rand.Source64 safe for concurrent use, like the global source in math/rand.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	n := s.src.Int63()
+	s.mu.Unlock()
+	return n
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	n := s.src.Uint64()
+	s.mu.Unlock()
+	return n
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	s.src.Seed(seed)
+	s.mu.Unlock()
+}
+
+// NewRand returns a *rand.Rand with the given seed that is safe for concurrent use.
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// DeriveSeed derives a per-component seed from a base seed, so that services seeded
+// from the same --seed value don't consume the same random stream.
+func DeriveSeed(seed int64, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return seed ^ int64(h.Sum64())
+}
+
+// defaultRand is used by SimulateIO, SimulateError and by simulators created without their own RNG
+var defaultRand = NewRand(time.Now().UnixNano())
+
+// SetSeed reseeds the default RNG. Call it before any simulator is used.
+func SetSeed(seed int64) {
+	defaultRand.Seed(seed)
+}
+
+// OrDefaultRand returns rng, or the default RNG if rng is nil.
+func OrDefaultRand(rng *rand.Rand) *rand.Rand {
+	if rng == nil {
+		return defaultRand
+	}
+	return rng
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// draws returns the first n numbers and latency samples of every model drawn from rng.
+func draws(rng *rand.Rand, n int) []int64 {
+	models := []LatencyModel{
+		DefaultLatency,
+		NormalLatency{Mean: 20 * time.Millisecond, StdDev: 5 * time.Millisecond},
+		LogNormalLatency{Median: 15 * time.Millisecond, Sigma: 0.6},
+		ParetoLatency{Scale: 5 * time.Millisecond, Alpha: 1.5},
+	}
+	var out []int64
+	for range n {
+		out = append(out, rng.Int63())
+		for _, m := range models {
+			out = append(out, int64(m.Sample(rng)))
+		}
+	}
+	return out
+}
+
+func TestNewRandIsReproducible(t *testing.T) {
+	a, b := draws(NewRand(42), 100), draws(NewRand(42), 100)
+	if !slices.Equal(a, b) {
+		t.Fatal("the same seed produced different sequences")
+	}
+	if slices.Equal(a, draws(NewRand(43), 100)) {
+		t.Fatal("different seeds produced the same sequence")
+	}
+}
+
+func TestSetSeedReseedsDefaultRand(t *testing.T) {
+	SetSeed(42)
+	a := draws(OrDefaultRand(nil), 100)
+	SetSeed(42)
+	if b := draws(OrDefaultRand(nil), 100); !slices.Equal(a, b) {
+		t.Fatal("reseeding the default RNG did not replay its sequence")
+	}
+	if !slices.Equal(a, draws(NewRand(42), 100)) {
+		t.Fatal("the default RNG seeded with 42 differs from NewRand(42)")
+	}
+}
+
+func TestDeriveSeed(t *testing.T) {
+	if DeriveSeed(42, "product") != DeriveSeed(42, "product") {
+		t.Fatal("DeriveSeed is not deterministic")
+	}
+	seeds := []int64{42, DeriveSeed(42, "product"), DeriveSeed(42, "stock"), DeriveSeed(42, "ads"), DeriveSeed(43, "product")}
+	for i, s := range seeds {
+		if slices.Contains(seeds[i+1:], s) {
+			t.Fatalf("seeds collide: %v", seeds)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+)
+
+// adEventRequest is the request body of the impression and click endpoints
+type adEventRequest struct {
+	RequestID string `json:"requestId"`
+	ProductID int    `json:"productId"`
+}
+
+// HandleAdImpression records that a sponsored product was shown
+// @Summary Record Ad Impression
+// @Description Records an impression of a sponsored product served by the given search request; 404 if that request did not serve it
+// @Tags ads
+// @Accept json
+// @Param event body adEventRequest true "Search request ID and sponsored product ID"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/ads/impression [post]
+func (s *Server) HandleAdImpression(w http.ResponseWriter, r *http.Request) {
+	s.handleAdEvent(w, r, ads.EventImpression)
+}
+
+// HandleAdClick records that a sponsored product was clicked
+// @Summary Record Ad Click
+// @Description Records a click on a sponsored product served by the given search request; 404 if that request did not serve it
+// @Tags ads
+// @Accept json
+// @Param event body adEventRequest true "Search request ID and sponsored product ID"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 500 {object} Response
+// @Router /api/ads/click [post]
+func (s *Server) HandleAdClick(w http.ResponseWriter, r *http.Request) {
+	s.handleAdEvent(w, r, ads.EventClick)
+}
+
+// HandleAdStats returns impression and click counters per product
+// @Summary Ad Stats
+// @Description Returns impressions, clicks and CTR per sponsored product
+// @Tags ads
+// @Produce json
+// @Success 200 {object} Response
+// @Router /api/ads/stats [get]
+func (s *Server) HandleAdStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"products": s.adEvents.Stats(),
+		},
+	})
+}
+
+func (s *Server) handleAdEvent(w http.ResponseWriter, r *http.Request, eventType ads.EventType) {
+	var req adEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid request body"})
+		return
+	}
+
+	err := s.adEvents.Record(ads.Event{
+		Type:      eventType,
+		RequestID: req.RequestID,
+		ProductID: req.ProductID,
+	})
+	if errors.Is(err, ads.ErrInvalidEvent) {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: err.Error()})
+		return
+	}
+	if errors.Is(err, ads.ErrAdNotServed) {
+		writeJSON(w, http.StatusNotFound, Response{Success: false, Message: err.Error()})
+		return
+	}
+	if errors.Is(err, ads.ErrDuplicateEvent) {
+		// A retry of an event whose response was lost; it is counted once
+		writeJSON(w, http.StatusOK, Response{Success: true, Message: "Event already recorded"})
+		return
+	}
+	if errors.Is(err, ads.ErrSpill) {
+		// The event is recorded, only an older one was lost; failing would make clients retry it
+		logging.FromContext(r.Context()).Error("spilling an evicted ad event failed", "error", err)
+	} else if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Event recorded"})
+}
+
+// writeJSON writes the response with the given status code as JSON
+func writeJSON(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+// postAdEvent posts an impression or click event to h and returns the recorded response.
+func postAdEvent(h http.Handler, kind, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/ads/"+kind, strings.NewReader(body)))
+	return rec
+}
+
+// servedAd searches on r until a search serves an ad and returns its request and product ID.
+// Ads are optional, so a single search may serve none.
+func servedAd(t *testing.T, r http.Handler) (requestID string, productID int) {
+	t.Helper()
+	for range 20 {
+		var resp struct {
+			Data struct {
+				RequestID string            `json:"requestId"`
+				Result    []EnrichedProduct `json:"result"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(serve(r, "GET", "/api/search?term=phone&itemCount=10&adSlots=2").Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range resp.Data.Result {
+			if p.Sponsored && resp.Data.RequestID != "" {
+				return resp.Data.RequestID, p.ID
+			}
+		}
+	}
+	t.Fatal("no search served an ad")
+	return "", 0
+}
+
+// adEventBody returns the body of an impression or click request.
+func adEventBody(requestID string, productID int) string {
+	return fmt.Sprintf(`{"requestId": %q, "productId": %d}`, requestID, productID)
+}
+
+// adStats returns the counters served by /api/ads/stats.
+func adStats(t *testing.T, r http.Handler) []ads.ProductStats {
+	t.Helper()
+	var stats struct {
+		Data struct {
+			Products []ads.ProductStats `json:"products"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(serve(r, "GET", "/api/ads/stats").Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	return stats.Data.Products
+}
+
+func TestAdEventsOnlyForServedAds(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	newTestServer(t).Routes(r)
+	requestID, productID := servedAd(t, r)
+
+	for _, tt := range []struct {
+		kind, body string
+		want       int
+	}{
+		{"impression", adEventBody(requestID, productID), http.StatusOK},
+		{"click", adEventBody(requestID, productID), http.StatusOK},
+		// Retries are answered like the first call but not counted again
+		{"impression", adEventBody(requestID, productID), http.StatusOK},
+		{"click", adEventBody(requestID, productID), http.StatusOK},
+		{"impression", adEventBody("made-up", productID), http.StatusNotFound},
+		{"click", adEventBody(requestID, 1_000_000), http.StatusNotFound},
+		{"click", adEventBody("", productID), http.StatusBadRequest},
+		{"impression", `{"requestId": `, http.StatusBadRequest},
+	} {
+		if rec := postAdEvent(r, tt.kind, tt.body); rec.Code != tt.want {
+			t.Errorf("POST /api/ads/%s %s = %d, want %d: %s", tt.kind, tt.body, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	want := ads.ProductStats{ProductID: productID, Impressions: 1, Clicks: 1, CTR: 1}
+	if stats := adStats(t, r); len(stats) != 1 || stats[0] != want {
+		t.Fatalf("stats = %+v, want only the accepted events %+v", stats, want)
+	}
+}
+
+func TestAdEventSpillFailureDoesNotFailRequest(t *testing.T) {
+	svc, err := NewServices(benchSim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	// With room for one event, the click evicts the impression, which cannot be spilled
+	events := ads.NewEventLog(1, failingWriter{})
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	NewServer(svc, search.SearchProductsHeapOptimizedByVector, EnrichSequential, ServerOptions{AdEvents: events}).Routes(r)
+	requestID, productID := servedAd(t, r)
+
+	for _, kind := range []string{"impression", "click"} {
+		if rec := postAdEvent(r, kind, adEventBody(requestID, productID)); rec.Code != http.StatusOK {
+			t.Fatalf("POST /api/ads/%s = %d, want 200: %s", kind, rec.Code, rec.Body)
+		}
+	}
+	want := ads.ProductStats{ProductID: productID, Impressions: 1, Clicks: 1, CTR: 1}
+	if stats := adStats(t, r); len(stats) != 1 || stats[0] != want {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+// failingWriter fails every write, like a spill file on a full disk.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/profiler"
+	"github.com/idagdelen/go-bottlenecks/internal/runtimetrace"
+)
+
+// HandleDebugConfig returns the effective configuration
+// @Summary Effective Configuration
+// @Description Returns the configuration the server runs with, after merging defaults, the config file, environment variables and flags. Secrets are masked
+// @Tags debug
+// @Produce json
+// @Success 200 {object} Response
+// @Router /debug/config [get]
+func (s *Server) HandleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: s.config.Redacted()})
+}
+
+// maxTraceSeconds bounds the length of a capture started through the API
+const maxTraceSeconds = 300
+
+// HandleTraceStatus returns the running trace capture and the kept trace files
+// @Summary Trace Status
+// @Description Returns the running runtime trace capture, whether the flight recorder is on and the trace files kept in the trace directory
+// @Tags debug
+// @Produce json
+// @Success 200 {object} Response
+// @Router /debug/trace [get]
+func (s *Server) HandleTraceStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: s.traces.Status()})
+}
+
+// HandleStartTrace starts a runtime trace capture
+// @Summary Start Trace
+// @Description Captures a runtime trace into a new timestamped file in the trace directory for the given number of seconds. Open it with go tool trace
+// @Tags debug
+// @Param seconds query int false "Length of the capture in seconds (default: 10, max: 300)"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 409 {object} Response
+// @Router /debug/trace/start [post]
+func (s *Server) HandleStartTrace(w http.ResponseWriter, r *http.Request) {
+	seconds := 10
+	if raw := r.URL.Query().Get("seconds"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxTraceSeconds {
+			writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: fmt.Sprintf("seconds must be between 1 and %d", maxTraceSeconds)})
+			return
+		}
+		seconds = n
+	}
+
+	capture, err := s.traces.Start(time.Duration(seconds) * time.Second)
+	if errors.Is(err, runtimetrace.ErrActive) {
+		writeJSON(w, http.StatusConflict, Response{Success: false, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Trace started", Data: capture})
+}
+
+// HandleStopTrace stops the running runtime trace capture early
+// @Summary Stop Trace
+// @Description Stops the running runtime trace capture before its scheduled end
+// @Tags debug
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 409 {object} Response
+// @Router /debug/trace/stop [post]
+func (s *Server) HandleStopTrace(w http.ResponseWriter, r *http.Request) {
+	capture, err := s.traces.Stop()
+	if errors.Is(err, runtimetrace.ErrNotActive) {
+		writeJSON(w, http.StatusConflict, Response{Success: false, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Trace stopped", Data: capture})
+}
+
+// HandleDumpFlightRecorder writes the flight recorder's window to a file
+// @Summary Dump Flight Recorder
+// @Description Writes the last seconds of runtime trace kept by the flight recorder (-flight-recorder) to a new timestamped file in the trace directory
+// @Tags debug
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 409 {object} Response
+// @Router /debug/trace/flight [post]
+func (s *Server) HandleDumpFlightRecorder(w http.ResponseWriter, r *http.Request) {
+	file, err := s.traces.DumpFlightRecorder()
+	if errors.Is(err, runtimetrace.ErrNoFlightRecorder) {
+		writeJSON(w, http.StatusConflict, Response{Success: false, Message: "the flight recorder is off, start the server with -flight-recorder"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Flight recorder dumped", Data: map[string]string{"file": file}})
+}
+
+// profileListing is the response data of HandleListProfiles
+type profileListing struct {
+	Dir       string              `json:"dir"`
+	Interval  string              `json:"interval"`  // "0s" if continuous profiling is off
+	Snapshots []profiler.Snapshot `json:"snapshots"` // Oldest first
+}
+
+// HandleListProfiles lists the continuous profiling snapshots
+// @Summary Profiling Snapshots
+// @Description Lists the snapshots of the continuous profiler (debug.profileInterval), oldest first, with their labels (git revision, configuration hash, ...) and profile files. Download two with /debug/profiles/{snapshot}/{file} and compare them with go tool pprof -diff_base
+// @Tags debug
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 500 {object} Response
+// @Router /debug/profiles [get]
+func (s *Server) HandleListProfiles(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := profiler.List(s.config.Debug.ProfileDir)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: profileListing{
+		Dir:       s.config.Debug.ProfileDir,
+		Interval:  s.config.Debug.ProfileInterval.String(),
+		Snapshots: snapshots,
+	}})
+}
+
+// HandleGetProfile downloads a profile of a continuous profiling snapshot
+// @Summary Download Profile
+// @Description Returns a profile file of a snapshot listed by /debug/profiles, e.g. go tool pprof http://localhost:6060/debug/profiles/20260101T120000Z/heap.pprof
+// @Tags debug
+// @Param snapshot path string true "Snapshot name, e.g. 20260101T120000Z"
+// @Param file path string true "Profile file, e.g. heap.pprof"
+// @Produce octet-stream
+// @Success 200 {file} binary
+// @Failure 404 {object} Response
+// @Router /debug/profiles/{snapshot}/{file} [get]
+func (s *Server) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	path, err := profiler.File(s.config.Debug.ProfileDir, chi.URLParam(r, "snapshot"), chi.URLParam(r, "file"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Response{Success: false, Message: err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/runtimetrace"
+)
+
+func TestTraceHandlers(t *testing.T) {
+	svc, err := NewServices(benchSim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	traces := runtimetrace.NewController(t.TempDir(), 0)
+	t.Cleanup(func() { traces.Close() })
+	r := chi.NewRouter()
+	r.Route("/debug", NewServer(svc, nil, nil, ServerOptions{Traces: traces}).DebugRoutes)
+
+	// The trace endpoints change state, so they only accept POST
+	for _, target := range []string{"/debug/trace/start", "/debug/trace/stop", "/debug/trace/flight"} {
+		if got := serve(r, "GET", target).Code; got != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s = %d, want 405", target, got)
+		}
+	}
+
+	for _, step := range []struct {
+		method, target string
+		want           int
+	}{
+		{"POST", "/debug/trace/start?seconds=0", http.StatusBadRequest},
+		{"POST", "/debug/trace/start?seconds=301", http.StatusBadRequest},
+		{"POST", "/debug/trace/stop", http.StatusConflict},
+		{"POST", "/debug/trace/start?seconds=60", http.StatusOK},
+		{"POST", "/debug/trace/start", http.StatusConflict},
+		{"GET", "/debug/trace", http.StatusOK},
+		{"POST", "/debug/trace/stop", http.StatusOK},
+		{"POST", "/debug/trace/flight", http.StatusConflict},
+	} {
+		if got := serve(r, step.method, step.target).Code; got != step.want {
+			t.Errorf("%s %s = %d, want %d", step.method, step.target, got, step.want)
+		}
+	}
+
+	var status struct {
+		Data runtimetrace.Status `json:"data"`
+	}
+	if err := json.NewDecoder(serve(r, "GET", "/debug/trace").Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Data.Active != nil || len(status.Data.Files) != 1 {
+		t.Errorf("status after a stopped capture = %+v, want one file and no active capture", status.Data)
+	}
+
+	if err := traces.StartFlightRecorder(time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(r, "POST", "/debug/trace/flight").Code; got != http.StatusOK {
+		t.Errorf("POST /debug/trace/flight with the flight recorder on = %d, want 200", got)
+	}
+}
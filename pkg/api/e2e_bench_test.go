@@ -0,0 +1,167 @@
+package api
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/loadgen"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+	"github.com/idagdelen/go-bottlenecks/internal/metrics"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+)
+
+var (
+	e2eBackends    = flag.String("e2e-backends", config.BackendHeap+","+config.BackendBruteForce, "search backends of BenchmarkSearchEndToEnd, comma separated")
+	e2eStrategies  = flag.String("e2e-strategies", strings.Join(config.EnrichStrategies, ","), "enrichment strategies of BenchmarkSearchEndToEnd, comma separated")
+	e2eItemCounts  = flag.String("e2e-items", "10,50", "page sizes (itemCount) of BenchmarkSearchEndToEnd, comma separated")
+	e2eLatencies   = flag.String("e2e-latencies", "constant:0s;lognormal:1ms,0.5", "latency models of the simulated services in BenchmarkSearchEndToEnd, semicolon separated")
+	e2eParallelism = flag.Int("e2e-parallelism", 4, "client goroutines per GOMAXPROCS in BenchmarkSearchEndToEnd")
+)
+
+/*
+BenchmarkSearchEndToEnd serves /api/search through Server.Router, with the middleware of cmd/main.go,
+and a real HTTP connection, for every combination of backend, strategy, page size and latency model.
+Besides ns/op and allocs/op (of client and server together) it reports req/s and the p50 and p99
+latency seen by the client:
+
+go test -bench=SearchEndToEnd -run=^$ ./pkg/api -benchtime 2s -count 5 | go run ./cmd/benchcmp -history bench-history.jsonl -
+go test -bench='SearchEndToEnd/backend=heap/strategy=parallel/' -run=^$ ./pkg/api -args -e2e-items=100 -e2e-latencies='pareto:2ms,1.5'
+
+With -transport=http or grpc the product and stock services keep the latencies of -latency-config.
+*/
+func BenchmarkSearchEndToEnd(b *testing.B) {
+	var latencies []util.LatencyModel
+	for _, spec := range strings.Split(*e2eLatencies, ";") {
+		m, err := util.ParseLatencyModel(spec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		latencies = append(latencies, m)
+	}
+	terms := loadgen.CatalogTerms()
+
+	for _, backend := range strings.Split(*e2eBackends, ",") {
+		for _, strategy := range strings.Split(*e2eStrategies, ",") {
+			for _, items := range strings.Split(*e2eItemCounts, ",") {
+				for _, latency := range latencies {
+					name := fmt.Sprintf("backend=%s/strategy=%s/items=%s/latency=%s", backend, strategy, items, latency)
+					b.Run(name, func(b *testing.B) {
+						benchmarkSearchEndToEnd(b, backend, strategy, items, latency, terms)
+					})
+				}
+			}
+		}
+	}
+}
+
+func benchmarkSearchEndToEnd(b *testing.B, backend, strategy, items string, latency util.LatencyModel, terms []string) {
+	cfg := config.Default()
+	cfg.Search.Backend, cfg.Search.Strategy = backend, strategy
+	srv := httptest.NewServer(newEndToEndRouter(b, cfg, latency))
+	defer srv.Close()
+	client := util.NewHTTPClient(*e2eParallelism*100, 0)
+	defer client.CloseIdleConnections()
+
+	var (
+		mu       sync.Mutex
+		latHist  = &loadgen.Histogram{}
+		workers  atomic.Int64
+		failures atomic.Int64
+	)
+	b.SetParallelism(*e2eParallelism)
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		picker, err := loadgen.NewPicker(terms, 1.1, *seed+workers.Add(1))
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		h := &loadgen.Histogram{}
+		for pb.Next() {
+			target := srv.URL + "/api/search?itemCount=" + items + "&term=" + url.QueryEscape(picker.Next())
+			sent := time.Now()
+			resp, err := client.Get(target)
+			if err != nil {
+				failures.Add(1)
+				continue
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			h.Record(time.Since(sent))
+			if resp.StatusCode != http.StatusOK {
+				failures.Add(1)
+			}
+		}
+		mu.Lock()
+		latHist.Merge(h)
+		mu.Unlock()
+	})
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	if n := failures.Load(); n > 0 {
+		b.Errorf("%d of %d requests failed", n, b.N)
+	}
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "req/s")
+	b.ReportMetric(float64(latHist.Percentile(50).Microseconds())/1000, "p50-ms")
+	b.ReportMetric(float64(latHist.Percentile(99).Microseconds())/1000, "p99-ms")
+}
+
+// newEndToEndRouter wires the API like cmd/main.go does, with the simulated services of the
+// benchmark flags answering after latency and logs written as JSON to io.Discard. No spans are
+// exported and no requests recorded, as without tracing or recording configured.
+func newEndToEndRouter(tb testing.TB, cfg *config.Config, latency util.LatencyModel) http.Handler {
+	sim := benchSim
+	sim.ProductLatency, sim.StockLatency, sim.AdsLatency = latency, latency, latency
+	svc, err := NewServices(sim)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { svc.Close() })
+	apiMetrics := NewMetrics(metrics.NewRegistry())
+	svc.Instrument(apiMetrics)
+	searcher, err := SearcherByName(cfg.Search.Backend, cfg.Search.QdrantURL)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	enricher, err := EnricherByName(cfg.Search.Strategy, cfg.Search.EnrichWorkers)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	logger, err := logging.New(io.Discard, logging.Options{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return NewServer(svc, searcher, enricher, ServerOptions{
+		Config:   cfg,
+		Metrics:  apiMetrics,
+		Logger:   logger,
+		Backend:  cfg.Search.Backend,
+		Strategy: cfg.Search.Strategy,
+	}).Router()
+}
+
+func TestSearchEndToEndRouter(t *testing.T) {
+	for _, strategy := range config.EnrichStrategies {
+		cfg := config.Default()
+		cfg.Search.Strategy = strategy
+		rec := httptest.NewRecorder()
+		newEndToEndRouter(t, cfg, util.ConstantLatency{}).ServeHTTP(rec, httptest.NewRequest("GET", "/api/search?term=phone&itemCount=5", nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name"`) {
+			t.Errorf("%s: status %d, body %s", strategy, rec.Code, rec.Body)
+		}
+	}
+}
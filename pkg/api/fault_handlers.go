@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+)
+
+// HandleListFaults returns the active fault rules
+// @Summary List Faults
+// @Description Returns the fault rules currently applied to the simulated services
+// @Tags admin
+// @Produce json
+// @Success 200 {object} Response
+// @Router /admin/faults [get]
+func (s *Server) HandleListFaults(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"rules": s.services.Faults.Rules(),
+		},
+	})
+}
+
+// HandleAddFault adds a fault rule
+// @Summary Add Fault
+// @Description Adds a fault rule (error, timeout, slow or panic) for a simulated service (product, stock, ads or *), optionally limited to product IDs and a brownout window
+// @Tags admin
+// @Accept json
+// @Param rule body fault.Rule true "Fault rule, e.g. {\"service\":\"stock\",\"kind\":\"slow\",\"probability\":0.5,\"delay\":\"200ms\",\"brownout\":{\"on\":\"10s\",\"off\":\"20s\"}}"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Router /admin/faults [post]
+func (s *Server) HandleAddFault(w http.ResponseWriter, r *http.Request) {
+	var rule fault.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid request body: " + err.Error()})
+		return
+	}
+
+	added, err := s.services.Faults.Add(rule)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Fault added", Data: added})
+}
+
+// HandleDeleteFault removes a fault rule
+// @Summary Delete Fault
+// @Description Removes the fault rule with the given ID
+// @Tags admin
+// @Param id path string true "Fault rule ID"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Router /admin/faults/{id} [delete]
+func (s *Server) HandleDeleteFault(w http.ResponseWriter, r *http.Request) {
+	if !s.services.Faults.Remove(chi.URLParam(r, "id")) {
+		writeJSON(w, http.StatusNotFound, Response{Success: false, Message: "fault rule not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Fault removed"})
+}
+
+// HandleClearFaults removes all fault rules
+// @Summary Clear Faults
+// @Description Removes all fault rules
+// @Tags admin
+// @Produce json
+// @Success 200 {object} Response
+// @Router /admin/faults [delete]
+func (s *Server) HandleClearFaults(w http.ResponseWriter, r *http.Request) {
+	s.services.Faults.Clear()
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "All faults removed"})
+}
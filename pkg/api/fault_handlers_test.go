@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+)
+
+func TestFaultHandlers(t *testing.T) {
+	s := newTestServer(t)
+	r := chi.NewRouter()
+	r.Route("/admin", s.AdminRoutes)
+
+	add := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/admin/faults", strings.NewReader(body)))
+		return rec
+	}
+	for _, body := range []string{`not json`, `{"service":"stock","kind":"explode","probability":1}`, `{"kind":"slow","probability":1}`, `{"service":"stocks","kind":"error","probability":1}`} {
+		if rec := add(body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := add(`{"service":"stock","productIds":[42],"kind":"error","probability":1,"message":"disk full"}`)
+	var added struct {
+		Data fault.Rule `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&added); err != nil || rec.Code != http.StatusOK || added.Data.ID == "" {
+		t.Fatalf("POST = %d, %+v, %v", rec.Code, added, err)
+	}
+	add(`{"service":"ads","kind":"timeout","probability":0.5,"delay":"10ms"}`)
+
+	var listed struct {
+		Data struct {
+			Rules []fault.Rule `json:"rules"`
+		} `json:"data"`
+	}
+	rec = serve(r, "GET", "/admin/faults")
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed.Data.Rules) != 2 || listed.Data.Rules[0].Message != "disk full" {
+		t.Fatalf("GET = %+v, %v", listed, err)
+	}
+	if _, err := s.services.Stock.GetStockByProductID(t.Context(), 42); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("stock/42 with the rule = %v, want the injected error", err)
+	}
+
+	if got := serve(r, "DELETE", "/admin/faults/"+added.Data.ID).Code; got != http.StatusOK {
+		t.Errorf("DELETE rule = %d, want 200", got)
+	}
+	if got := serve(r, "DELETE", "/admin/faults/"+added.Data.ID).Code; got != http.StatusNotFound {
+		t.Errorf("DELETE removed rule = %d, want 404", got)
+	}
+	if got := serve(r, "DELETE", "/admin/faults").Code; got != http.StatusOK || len(s.services.Faults.Rules()) != 0 {
+		t.Errorf("DELETE all = %d, rules left %v", got, s.services.Faults.Rules())
+	}
+}
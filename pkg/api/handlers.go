@@ -1,78 +1,167 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"runtime/pprof"
 	"runtime/trace"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
 	"github.com/idagdelen/go-bottlenecks/internal/product"
 	"github.com/idagdelen/go-bottlenecks/internal/search"
 	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
 )
 
-// ProductService instance (should be injected in real apps, global for PoC)
-var prodService = product.NewSimulatedProductService()
-
-// StockService instance (should be injected in real apps, global for PoC)
-var stockService = stock.NewSimulatedStockService()
-
-// AdsService instance (should be injected in real apps, global for PoC)
-var adsService = ads.NewAdsService(prodService, stockService)
-
 // HandleSearch handles search
 // @Summary Search Demo
 // @Description Searches the vectorized database with the given keyword and enriches the results with external services
 // @Tags search
 // @Param term query string true "Keyword to search for"
-// @Param itemCount query int false "Number of products to return (default: 10)"
+// @Param itemCount query int false "Number of products to return (default: 10, see search.defaultItemCount)"
+// @Param adSlots query int false "Number of sponsored products to inject into the results (default: 1, max: 5)"
+// @Param currency query string false "Currency to show prices in: TRY, USD, EUR, GBP or JPY (default: the locale's currency)"
+// @Param Accept-Language header string false "Locale used to format prices, e.g. tr-TR or en-US (default: tr-TR)"
+// @Param debug query string false "timings adds the parse, embed, search, enrich and ads stage timings to the body (all stages are always in the Server-Timing header)"
 // @Produce json
 // @Success 200 {object} Response
+// @Header 200 {string} Server-Timing "Stage timings, e.g. parse;dur=0.02, embed;dur=0.01, search;dur=1.3, enrich;dur=48.1, ads;dur=21.7, encode;dur=0.1, total;dur=49.6"
 // @Failure 400 {object} Response
 // @Router /api/search [get]
-func HandleSearch(w http.ResponseWriter, r *http.Request) {
-	ctx, task := trace.NewTask(r.Context(), "HandleSearch")
+func (s *Server) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	requestStart := time.Now()
+	timings := &stageTimings{}
+	ctx := withStageTimings(r.Context(), timings)
+	ctx, task := trace.NewTask(ctx, "HandleSearch")
 	defer task.End()
+	ctx, span := tracing.Start(ctx, "HandleSearch")
+	defer span.End()
+
+	// CPU samples of the request and of the goroutines it starts carry these labels, e.g. for
+	// go tool pprof -tagfocus=strategy=worker-pool; stages add a stage label
+	route := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+	labels := pprof.Labels("route", route, "backend", s.backend, "strategy", s.strategy)
+	pprof.Do(ctx, labels, func(ctx context.Context) {
+		TraceRegion(ctx, "HandleSearch-Request", func() {
+			searchTerm := r.URL.Query().Get("term")
+			itemCount := r.URL.Query().Get("itemCount")
+			debugTimings := slices.Contains(strings.Split(r.URL.Query().Get("debug"), ","), "timings")
+
+			parsedItemCount, err := strconv.Atoi(itemCount)
+			if err != nil {
+				parsedItemCount = s.config.Search.DefaultItemCount
+			}
+			adSlots := s.parseAdSlots(r.URL.Query().Get("adSlots"))
+			span.SetAttributes(tracing.String("search.term", searchTerm), tracing.Int("search.item_count", parsedItemCount), tracing.Int("ads.slots", adSlots))
+			logging.AddAttrs(ctx, slog.String("term", searchTerm), slog.Int("item_count", parsedItemCount))
+			prices, err := s.parsePricing(r)
+			if err != nil {
+				span.RecordError(err)
+				writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: err.Error()})
+				return
+			}
+			stageStart := timings.since(stageParse, requestStart)
+
+			searchRes := TraceRegionWithResult(ctx, "SearchProducts-Region", func() searchResult {
+				_, span := tracing.Start(ctx, "search", tracing.WithAttributes(tracing.String("search.backend", s.backend)))
+				defer span.End()
+				query := search.Embed(searchTerm)
+				stageStart = timings.since(stageEmbed, stageStart)
+				var products []search.ScoredProduct
+				var totalSum float64
+				pprof.Do(ctx, pprof.Labels("stage", stageSearch), func(context.Context) {
+					products, totalSum = s.searcher(query, parsedItemCount)
+				})
+				s.metrics.searchDuration.Observe(time.Since(stageStart).Seconds(), s.backend)
+				stageStart = timings.since(stageSearch, stageStart)
+				span.SetAttributes(tracing.Int("search.results", len(products)))
+				return searchResult{products: products, totalSum: totalSum}
+			})
+			products := searchRes.products
+			totalSum := searchRes.totalSum
+
+			result := TraceRegionWithResult(ctx, "EnrichProducts-Region", func() enrichResult {
+				ctx, span := tracing.Start(ctx, "enrich", tracing.WithAttributes(tracing.String("enrich.strategy", s.strategy)))
+				defer span.End()
+				var eps []EnrichedProduct
+				var sponsored []ads.SponsoredProduct
+				pprof.Do(ctx, pprof.Labels("stage", stageEnrich), func(ctx context.Context) {
+					eps, sponsored = s.enricher(ctx, s.services, products, adSlots)
+				})
+				s.metrics.enrichDuration.Observe(time.Since(stageStart).Seconds(), s.strategy)
+				stageStart = timings.since(stageEnrich, stageStart)
+				return enrichResult{enrichedProducts: eps, sponsored: sponsored}
+			})
 
-	TraceRegion(ctx, "HandleSearch-Request", func() {
-		searchTerm := r.URL.Query().Get("term")
-		itemCount := r.URL.Query().Get("itemCount")
+			if len(result.enrichedProducts) < len(products) {
+				logging.FromContext(ctx).Warn("enrichment dropped search hits",
+					"strategy", s.strategy, "hits", len(products), "enriched", len(result.enrichedProducts))
+			}
 
-		parsedItemCount, err := strconv.Atoi(itemCount)
-		if err != nil {
-			parsedItemCount = 10 // Default value
-		}
+			enrichedProducts := injectSponsoredProducts(result.enrichedProducts, result.sponsored)
+			s.localizePrices(enrichedProducts, prices)
+
+			// recommendedAd is kept for clients that only know about a single ad
+			var recommendedAdResp *EnrichedProduct
+			var sponsoredIDs []int
+			for i := range enrichedProducts {
+				if enrichedProducts[i].Sponsored {
+					if recommendedAdResp == nil {
+						recommendedAdResp = &enrichedProducts[i]
+					}
+					sponsoredIDs = append(sponsoredIDs, enrichedProducts[i].ID)
+				}
+			}
+			// Impressions and clicks are only accepted for the ads served here
+			requestID := middleware.GetReqID(r.Context())
+			s.adEvents.Served(requestID, sponsoredIDs)
+
+			endTime := time.Now()
+			resp := Response{
+				Success: true,
+				Message: "Search completed successfully",
+				Data: map[string]interface{}{
+					"requestId":     requestID,
+					"result":        enrichedProducts,
+					"totalSum":      totalSum,
+					"recommendedAd": recommendedAdResp,
+				},
+				Duration:  endTime.Sub(requestStart).String(),
+				StartTime: requestStart,
+				EndTime:   endTime,
+			}
+			if debugTimings {
+				// The body cannot time its own encoding, the Server-Timing header has encode and total too
+				resp.Timings = timings.list()
+			}
 
-		searchRes := TraceRegionWithResult(ctx, "SearchProducts-Region", func() searchResult {
-			products, totalSum := search.SearchProductsHeapOptimized(searchTerm, parsedItemCount)
-			return searchResult{products: products, totalSum: totalSum}
-		})
-		products := searchRes.products
-		totalSum := searchRes.totalSum
+			// Encode before writing so that the header can include the encode stage
+			var body bytes.Buffer
+			pprof.Do(ctx, pprof.Labels("stage", stageEncode), func(context.Context) {
+				json.NewEncoder(&body).Encode(resp)
+			})
+			timings.since(stageEncode, endTime)
+			timings.since(stageTotal, requestStart)
 
-		result := TraceRegionWithResult(ctx, "EnrichProducts-Region", func() enrichResult {
-			eps, ad := enrichProductsWithDetailsAndAdWorkerPool(products)
-			return enrichResult{enrichedProducts: eps, recommendedAdResp: ad}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Vary", "Accept-Language")
+			w.Header().Set("Server-Timing", timings.serverTiming())
+			w.Write(body.Bytes())
 		})
-
-		enrichedProducts := result.enrichedProducts
-		recommendedAdResp := result.recommendedAdResp
-
-		resp := Response{
-			Success: true,
-			Message: "Search completed successfully",
-			Data: map[string]interface{}{
-				"result":        enrichedProducts,
-				"totalSum":      totalSum,
-				"recommendedAd": recommendedAdResp,
-			},
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
 	})
 }
 
@@ -83,11 +172,11 @@ func HandleSearch(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Success 200 {object} Response
 // @Router /api/health [get]
-func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	// Prevent crash: recover from any panic in handler
 	defer func() {
 		if err := recover(); err != nil {
-			println("Recovered from panic in HandleHealthCheck:", err)
+			logging.FromContext(r.Context()).Error("recovered from panic", "handler", "HandleHealthCheck", "panic", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(Response{
 				Success: false,
@@ -109,22 +198,22 @@ func HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// enrichProductsWithDetailsAndAd enriches products with details and optionally adds a recommended ad
-// If withEnrichment is false, returns empty enrichedProducts and nil recommendedAdResp
-func enrichProductsWithDetailsAndAd(products []search.ScoredProduct) ([]EnrichedProduct, *EnrichedProduct) {
+// enrichProductsWithDetailsAndAd enriches products with details and fetches up to adSlots sponsored products
+func enrichProductsWithDetailsAndAd(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
 	var enrichedProducts []EnrichedProduct
 	var idList []int
 
 	for _, p := range products {
 		idList = append(idList, p.ID)
-		prod, err := prodService.GetProductByID(p.ID)
+		prod, err := svc.Products.GetProductByID(ctx, p.ID)
 		if err == nil && prod != nil {
-			stk, _ := stockService.GetStockByProductID(p.ID) // Ignore stock error for PoC
+			stk, _ := svc.Stock.GetStockByProductID(ctx, p.ID) // Ignore stock error for PoC
 			enrichedProducts = append(enrichedProducts, EnrichedProduct{
 				ID:          prod.ID,
 				Name:        prod.Name,
 				Description: prod.Description,
 				Price:       prod.FormatPrice(),
+				PriceValue:  prod.Price,
 				Score:       p.Score, // Add the score from search
 				Stock:       0,
 			})
@@ -134,29 +223,26 @@ func enrichProductsWithDetailsAndAd(products []search.ScoredProduct) ([]Enriched
 		}
 	}
 
-	// Get a recommended ad product using AdsService and the id list
-	recommendedAd, _ := adsService.RecommendProductByIDs(idList)
-	var recommendedAdResp *EnrichedProduct
-	if recommendedAd != nil {
-		recommendedAdResp = &EnrichedProduct{
-			ID:          recommendedAd.Product.ID,
-			Name:        recommendedAd.Product.Name,
-			Description: recommendedAd.Product.Description,
-			Price:       recommendedAd.Product.FormatPrice(),
-			Score:       0, // Not relevant for ad
-			Stock:       recommendedAd.Stock.Quantity,
-		}
-	}
-	return enrichedProducts, recommendedAdResp
+	// Get sponsored products using AdsService and the id list
+	sponsored := svc.recommendAds(ctx, idList, adSlots)
+	return enrichedProducts, sponsored
 }
 
-// sync.Pool for EnrichedProduct reuse across requests
-var enrichedProductPool = sync.Pool{
-	New: func() interface{} { return new(EnrichedProduct) },
+// labelEnrichGoroutine adds stage=enrich to the pprof labels of ctx and sets them on the calling
+// goroutine, which must be one started by an enrichment strategy. Goroutines inherit the labels
+// of their creator anyway; this also labels them where the caller set none, e.g. in benchmarks.
+func labelEnrichGoroutine(ctx context.Context) context.Context {
+	ctx = pprof.WithLabels(ctx, pprof.Labels("stage", stageEnrich))
+	pprof.SetGoroutineLabels(ctx)
+	return ctx
 }
 
-func enrichProductsWithDetailsAndAdWorkerPool(products []search.ScoredProduct) ([]EnrichedProduct, *EnrichedProduct) {
-	numWorkers := 10
+// sync.Pool for EnrichedProduct reuse across requests (see Services.getEnrichedProduct). The
+// strategies copy each item into their result and put it back, so it has no New: a Get that
+// returns nil is a miss.
+var enrichedProductPool sync.Pool
+
+func enrichProductsWithDetailsAndAdWorkerPool(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int, numWorkers int) ([]EnrichedProduct, []ads.SponsoredProduct) {
 	jobs := make(chan job, len(products))
 	results := make(chan result, len(products))
 
@@ -164,15 +250,18 @@ func enrichProductsWithDetailsAndAdWorkerPool(products []search.ScoredProduct) (
 	idList := make([]int, len(products))
 
 	worker := func() {
+		ctx := labelEnrichGoroutine(ctx)
 		for j := range jobs {
-			prod, err := prodService.GetProductByID(j.prod.ID)
+			svc.metrics.addEnrichQueueDepth(-1)
+			prod, err := svc.Products.GetProductByID(ctx, j.prod.ID)
 			if err == nil && prod != nil {
-				stk, _ := stockService.GetStockByProductID(j.prod.ID)
-				item := enrichedProductPool.Get().(*EnrichedProduct)
+				stk, _ := svc.Stock.GetStockByProductID(ctx, j.prod.ID)
+				item := svc.getEnrichedProduct()
 				item.ID = prod.ID
 				item.Name = prod.Name
 				item.Description = prod.Description
 				item.Price = prod.FormatPrice()
+				item.PriceValue = prod.Price
 				item.Score = j.prod.Score
 				item.Stock = 0
 				if stk != nil {
@@ -191,6 +280,7 @@ func enrichProductsWithDetailsAndAdWorkerPool(products []search.ScoredProduct) (
 
 	for i, p := range products {
 		idList[i] = p.ID
+		svc.metrics.addEnrichQueueDepth(1)
 		jobs <- job{idx: i, prod: p}
 	}
 	close(jobs)
@@ -199,26 +289,16 @@ func enrichProductsWithDetailsAndAdWorkerPool(products []search.ScoredProduct) (
 		res := <-results
 		if res.item != nil {
 			enrichedProducts = append(enrichedProducts, *res.item)
+			putEnrichedProduct(res.item)
 		}
 	}
 	close(results)
 
-	recommendedAd, _ := adsService.RecommendProductByIDs(idList)
-	var recommendedAdResp *EnrichedProduct
-	if recommendedAd != nil {
-		recommendedAdResp = &EnrichedProduct{
-			ID:          recommendedAd.Product.ID,
-			Name:        recommendedAd.Product.Name,
-			Description: recommendedAd.Product.Description,
-			Price:       recommendedAd.Product.FormatPrice(),
-			Score:       0,
-			Stock:       recommendedAd.Stock.Quantity,
-		}
-	}
-	return enrichedProducts, recommendedAdResp
+	sponsored := svc.recommendAds(ctx, idList, adSlots)
+	return enrichedProducts, sponsored
 }
 
-func enrichProductsWithDetailsParallelAndIndexBased(products []search.ScoredProduct) ([]EnrichedProduct, *EnrichedProduct) {
+func enrichProductsWithDetailsParallelAndIndexBased(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
 	var wg sync.WaitGroup
 	results := make([]*EnrichedProduct, len(products))
 	idList := make([]int, len(products))
@@ -228,15 +308,17 @@ func enrichProductsWithDetailsParallelAndIndexBased(products []search.ScoredProd
 		wg.Add(1)
 		go func(i int, p search.ScoredProduct) {
 			defer wg.Done()
+			ctx := labelEnrichGoroutine(ctx)
 
-			prod, err := prodService.GetProductByID(p.ID)
+			prod, err := svc.Products.GetProductByID(ctx, p.ID)
 			if err == nil && prod != nil {
-				stk, _ := stockService.GetStockByProductID(p.ID)
-				item := enrichedProductPool.Get().(*EnrichedProduct)
+				stk, _ := svc.Stock.GetStockByProductID(ctx, p.ID)
+				item := svc.getEnrichedProduct()
 				item.ID = prod.ID
 				item.Name = prod.Name
 				item.Description = prod.Description
 				item.Price = prod.FormatPrice()
+				item.PriceValue = prod.Price
 				item.Score = p.Score
 				item.Stock = 0
 				if stk != nil {
@@ -252,34 +334,23 @@ func enrichProductsWithDetailsParallelAndIndexBased(products []search.ScoredProd
 	for _, item := range results {
 		if item != nil {
 			finalResults = append(finalResults, *item)
+			putEnrichedProduct(item)
 		}
 	}
 
-	recommendedAd, _ := adsService.RecommendProductByIDs(idList)
-	var recommendedAdResp *EnrichedProduct
-	if recommendedAd != nil {
-		recommendedAdResp = &EnrichedProduct{
-			ID:          recommendedAd.Product.ID,
-			Name:        recommendedAd.Product.Name,
-			Description: recommendedAd.Product.Description,
-			Price:       recommendedAd.Product.FormatPrice(),
-			Score:       0,
-			Stock:       recommendedAd.Stock.Quantity,
-		}
-	}
-	return finalResults, recommendedAdResp
+	sponsored := svc.recommendAds(ctx, idList, adSlots)
+	return finalResults, sponsored
 }
 
 // enrichProductsWithDetailsAndAdIndexBased enriches products concurrently, each goroutine writes to its own index, then nils are cleaned up
-func enrichProductsWithDetailsParallelAndIndexBased_v2(products []search.ScoredProduct) ([]EnrichedProduct, *EnrichedProduct) {
+func enrichProductsWithDetailsParallelAndIndexBased_v2(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
 	var wg sync.WaitGroup
 	results := make([]*EnrichedProduct, len(products))
 	idList := make([]int, len(products))
 
-	recommendedAdCh := make(chan *ads.RecommendedProduct, 1)
+	sponsoredCh := make(chan []ads.SponsoredProduct, 1)
 	go func(ids []int) {
-		recommendedAd, _ := adsService.RecommendProductByIDs(ids)
-		recommendedAdCh <- recommendedAd
+		sponsoredCh <- svc.recommendAds(ctx, ids, adSlots)
 	}(func() []int {
 		ids := make([]int, len(products))
 		for i, p := range products {
@@ -294,14 +365,15 @@ func enrichProductsWithDetailsParallelAndIndexBased_v2(products []search.ScoredP
 
 		go func(i int, p search.ScoredProduct) {
 			defer wg.Done()
+			ctx := labelEnrichGoroutine(ctx)
 
 			var prod *product.Product
 			var stk *stock.Stock
 			var prodErr error
 			/* var stockWg sync.WaitGroup */
 
-			prod, prodErr = prodService.GetProductByID(p.ID)
-			stk, _ = stockService.GetStockByProductID(p.ID)
+			prod, prodErr = svc.Products.GetProductByID(ctx, p.ID)
+			stk, _ = svc.Stock.GetStockByProductID(ctx, p.ID)
 
 			/* 	stockWg.Add(2)
 			// Product goroutine
@@ -315,11 +387,12 @@ func enrichProductsWithDetailsParallelAndIndexBased_v2(products []search.ScoredP
 			stockWg.Wait() */
 
 			if prodErr == nil && prod != nil {
-				item := enrichedProductPool.Get().(*EnrichedProduct)
+				item := svc.getEnrichedProduct()
 				item.ID = prod.ID
 				item.Name = prod.Name
 				item.Description = prod.Description
 				item.Price = prod.FormatPrice()
+				item.PriceValue = prod.Price
 				item.Score = p.Score
 				item.Stock = 0
 				if stk != nil {
@@ -335,21 +408,59 @@ func enrichProductsWithDetailsParallelAndIndexBased_v2(products []search.ScoredP
 	for _, item := range results {
 		if item != nil {
 			finalResults = append(finalResults, *item)
+			putEnrichedProduct(item)
 		}
 	}
 
-	recommendedAd := <-recommendedAdCh
-	close(recommendedAdCh)
-	var recommendedAdResp *EnrichedProduct
-	if recommendedAd != nil {
-		recommendedAdResp = &EnrichedProduct{
-			ID:          recommendedAd.Product.ID,
-			Name:        recommendedAd.Product.Name,
-			Description: recommendedAd.Product.Description,
-			Price:       recommendedAd.Product.FormatPrice(),
-			Score:       0,
-			Stock:       recommendedAd.Stock.Quantity,
+	sponsored := <-sponsoredCh
+	close(sponsoredCh)
+	return finalResults, sponsored
+}
+
+// enrichProductsWithDetailsBatch fetches all products and all stock entries with one batch call
+// each, made concurrently. Services that cannot batch (see product.GetProducts) are called per ID.
+func enrichProductsWithDetailsBatch(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
+	idList := make([]int, len(products))
+	for i, p := range products {
+		idList[i] = p.ID
+	}
+
+	var stocks []*stock.Stock
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		stocks, _ = stock.GetStocks(labelEnrichGoroutine(ctx), svc.Stock, idList) // Ignore stock error for PoC
+	}()
+	prods, _ := product.GetProducts(ctx, svc.Products, idList)
+	wg.Wait()
+
+	byID := make(map[int]*product.Product, len(prods))
+	for _, prod := range prods {
+		byID[prod.ID] = prod
+	}
+	quantities := make(map[int]int, len(stocks))
+	for _, stk := range stocks {
+		quantities[stk.ProductID] = stk.Quantity
+	}
+
+	enrichedProducts := make([]EnrichedProduct, 0, len(products))
+	for _, p := range products {
+		prod := byID[p.ID]
+		if prod == nil {
+			continue
 		}
+		enrichedProducts = append(enrichedProducts, EnrichedProduct{
+			ID:          prod.ID,
+			Name:        prod.Name,
+			Description: prod.Description,
+			Price:       prod.FormatPrice(),
+			PriceValue:  prod.Price,
+			Score:       p.Score,
+			Stock:       quantities[p.ID],
+		})
 	}
-	return finalResults, recommendedAdResp
+
+	sponsored := svc.recommendAds(ctx, idList, adSlots)
+	return enrichedProducts, sponsored
 }
@@ -1,11 +1,136 @@
 package api
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/idagdelen/go-bottlenecks/internal/catalog"
+	"github.com/idagdelen/go-bottlenecks/internal/pb"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
 	"github.com/idagdelen/go-bottlenecks/internal/search"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+	"google.golang.org/grpc"
 )
 
+var (
+	seed          = flag.Int64("seed", 0, "seed for the simulated services (0 picks a time-based seed)")
+	stateful      = flag.Bool("stateful", false, "serve product and stock data from in-memory stores seeded from the search catalog")
+	stockShards   = flag.Int("stock-shards", 0, "number of lock shards of the stateful stock store (0 uses a mutex per product)")
+	latencyConfig = flag.String("latency-config", "", "JSON file mapping simulated services (product, stock, ads) to latency specs")
+	transport     = flag.String("transport", "inprocess", "how product and stock services are called: inprocess, http or grpc")
+)
+
+// benchSim configures the services built by every benchmark, set up by TestMain from the flags
+var benchSim SimulationConfig
+
+/*
+go test -bench=. -run=^$ ./pkg/api -args -seed=42 -latency-config=latency.json
+go test -bench=. -run=^$ ./pkg/api -args -transport=grpc
+*/
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	util.SetSeed(*seed)
+	sim := SimulationConfig{Seed: *seed, Stateful: *stateful, StockShards: *stockShards, ErrorRate: util.DefaultErrorRate}
+	if *latencyConfig != "" {
+		models, err := util.LoadLatencyConfig(*latencyConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sim.SetLatencies(models); err != nil {
+			log.Fatal(err)
+		}
+	}
+	stop := func() {}
+	if *transport != "inprocess" {
+		var err error
+		if stop, err = startRemoteServices(*transport, &sim); err != nil {
+			log.Fatal(err)
+		}
+	}
+	benchSim = sim
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// startRemoteServices serves simulated product and stock services on local listeners using the
+// given transport, like cmd/productsvc and cmd/stocksvc do, and points sim at them.
+func startRemoteServices(transport string, sim *SimulationConfig) (stop func(), err error) {
+	var productBackend product.ProductService
+	var stockBackend stock.StockService
+	if sim.Stateful {
+		products, quantities := catalog.Generate(sim.Seed)
+		productBackend = product.NewInMemoryProductService(products)
+		stockBackend = stock.NewInMemoryStockService(quantities)
+	}
+	latencyOrDefault := func(m util.LatencyModel) util.LatencyModel {
+		if m == nil {
+			return util.DefaultLatency
+		}
+		return m
+	}
+	prodSvc := product.NewSimulatedProductServiceWithBackend(
+		util.NewRand(util.DeriveSeed(sim.Seed, "product")), latencyOrDefault(sim.ProductLatency), productBackend)
+	stockSvc := stock.NewSimulatedStockServiceWithBackend(
+		util.NewRand(util.DeriveSeed(sim.Seed, "stock")), latencyOrDefault(sim.StockLatency), stockBackend)
+
+	switch transport {
+	case "http":
+		productSrv := httptest.NewServer(product.NewHTTPHandler(prodSvc))
+		stockSrv := httptest.NewServer(stock.NewHTTPHandler(stockSvc))
+		sim.ProductServiceURL, sim.StockServiceURL = productSrv.URL, stockSrv.URL
+		return func() {
+			productSrv.Close()
+			stockSrv.Close()
+		}, nil
+	case "grpc":
+		productLis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		stockLis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			productLis.Close()
+			return nil, err
+		}
+		productSrv := grpc.NewServer()
+		pb.RegisterProductServiceServer(productSrv, product.NewGRPCServer(prodSvc))
+		stockSrv := grpc.NewServer()
+		pb.RegisterStockServiceServer(stockSrv, stock.NewGRPCServer(stockSvc))
+		go productSrv.Serve(productLis)
+		go stockSrv.Serve(stockLis)
+		sim.ProductServiceURL = "grpc://" + productLis.Addr().String()
+		sim.StockServiceURL = "grpc://" + stockLis.Addr().String()
+		return func() {
+			productSrv.Stop()
+			stockSrv.Stop()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q, want inprocess, http or grpc", transport)
+	}
+}
+
+// newBenchServices builds a fresh set of services configured by the flags
+func newBenchServices(b *testing.B) *Services {
+	svc, err := NewServices(benchSim)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { svc.Close() })
+	return svc
+}
+
 /*
 go test -bench=BenchmarkEnrichProductsWithDetailsAndAdWorkerPool -run=^$ ./pkg/api -memprofile=mem.prof -trace=trace.out -cpu 10 -benchtime 3s
 go tool pprof -http=:8080 ./cpu.prof
@@ -22,9 +147,12 @@ func BenchmarkEnrichProductsWithDetailsAndAdWorkerPool(b *testing.B) {
 		}
 	}
 
+	svc := newBenchServices(b)
+	enrich := EnrichWorkerPool(10)
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = enrichProductsWithDetailsAndAdWorkerPool(products)
+		_, _ = enrich(context.Background(), svc, products, 1)
 	}
 }
 
@@ -44,8 +172,33 @@ func BenchmarkEnrichProductsWithDetailsAndAd(b *testing.B) {
 		}
 	}
 
+	svc := newBenchServices(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = EnrichSequential(context.Background(), svc, products, 1)
+	}
+}
+
+/*
+BenchmarkEnrichProductsWithDetailsBatch fetches the page with one batch call per service. Compare
+it with the other strategies over gRPC, where a batch is a single GetProducts/GetStocks request:
+
+go test -bench='BenchmarkEnrichProductsWithDetails(Batch|AndAdWorkerPool)$' -run=^$ ./pkg/api -args -transport=grpc
+*/
+func BenchmarkEnrichProductsWithDetailsBatch(b *testing.B) {
+	products := make([]search.ScoredProduct, 100)
+	for i := 0; i < 100; i++ {
+		products[i] = search.ScoredProduct{
+			Product: &search.Product{ID: i + 1, Name: "Product"},
+			Score:   float64(i) * 1.1,
+		}
+	}
+
+	svc := newBenchServices(b)
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = enrichProductsWithDetailsAndAd(products)
+		_, _ = EnrichBatch(context.Background(), svc, products, 1)
 	}
 }
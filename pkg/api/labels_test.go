@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime/pprof"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+// labelRecorder records the pprof labels of the contexts product lookups are made with.
+type labelRecorder struct {
+	next product.ProductService
+
+	mu     sync.Mutex
+	labels []map[string]string
+}
+
+func (r *labelRecorder) GetProductByID(ctx context.Context, id int) (*product.Product, error) {
+	labels := map[string]string{}
+	pprof.ForLabels(ctx, func(k, v string) bool {
+		labels[k] = v
+		return true
+	})
+	r.mu.Lock()
+	r.labels = append(r.labels, labels)
+	r.mu.Unlock()
+	return r.next.GetProductByID(ctx, id)
+}
+
+func TestSearchProfilerLabels(t *testing.T) {
+	for _, strategy := range config.EnrichStrategies {
+		svc, err := NewServices(benchSim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		rec := &labelRecorder{next: svc.Products}
+		svc.Products = rec
+		svc.Ads.ProductService = rec
+
+		// The labels use the names given in ServerOptions, not the configured ones
+		cfg := config.Default()
+		cfg.Search.Backend, cfg.Search.Strategy = config.BackendQdrant, config.StrategySequential
+		if strategy == config.StrategySequential {
+			cfg.Search.Strategy = config.StrategyParallel
+		}
+		enricher, _ := EnricherByName(strategy, 2)
+		s := NewServer(svc, search.SearchProductsHeapOptimizedByVector, enricher, ServerOptions{Config: cfg, Backend: config.BackendHeap, Strategy: strategy})
+		r := chi.NewRouter()
+		s.Routes(r)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/search?term=phone&itemCount=3", nil))
+
+		var enrich, ads int
+		for _, l := range rec.labels {
+			if l["route"] != "/api/search" || l["backend"] != config.BackendHeap || l["strategy"] != strategy {
+				t.Errorf("%s: lookup labeled %v", strategy, l)
+			}
+			switch l["stage"] {
+			case stageEnrich:
+				enrich++
+			case stageAds:
+				ads++
+			default:
+				t.Errorf("%s: lookup in stage %q", strategy, l["stage"])
+			}
+		}
+		if enrich != 3 || ads == 0 {
+			t.Errorf("%s: %d lookups in stage enrich, %d in stage ads", strategy, enrich, ads)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+// The connections to the services of -transport=http or grpc are pooled for the life of the
+// Services, on both the client and the server side, so the goroutines serving an idle connection
+// are not leaks. Any other goroutine of net/http or grpc still is.
+var pooledConns = []leakcheck.Option{
+	leakcheck.IgnoreFunction("net/http.(*persistConn).readLoop"),
+	leakcheck.IgnoreFunction("net/http.(*persistConn).writeLoop"),
+	leakcheck.IgnoreFunction("net/http.(*conn).serve"),
+	leakcheck.IgnoreFunction("google.golang.org/grpc/internal/transport.(*http2Client).reader"),
+	leakcheck.IgnoreFunction("google.golang.org/grpc/internal/transport.(*http2Server).HandleStreams"),
+	leakcheck.IgnoreFunction("google.golang.org/grpc/internal/transport.(*http2Server).keepalive"),
+	leakcheck.IgnoreFunction("google.golang.org/grpc/internal/transport.(*loopyWriter).run"),
+}
+
+func TestEnrichmentDoesNotLeakGoroutines(t *testing.T) {
+	hits, _ := search.SearchProductsHeapOptimizedByVector(search.Embed("phone"), 20)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, strategy := range config.EnrichStrategies {
+		t.Run(strategy, func(t *testing.T) {
+			svc, err := NewServices(benchSim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { svc.Close() })
+			enricher, _ := EnricherByName(strategy, 4)
+			cfg := config.Default()
+			cfg.Search.Strategy = strategy
+			r := chi.NewRouter()
+			NewServer(svc, search.SearchProductsHeapOptimizedByVector, enricher, ServerOptions{Config: cfg}).Routes(r)
+
+			leakcheck.Check(t, pooledConns...)
+			enricher(context.Background(), svc, hits, 3)
+			enricher(cancelled, svc, hits, 3)
+			enricher(context.Background(), svc, nil, 3)
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/search?term=phone&itemCount=10", nil))
+		})
+	}
+}
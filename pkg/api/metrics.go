@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+	"github.com/idagdelen/go-bottlenecks/internal/metrics"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+)
+
+// Metrics are the instruments of a Server and of the Services it calls, exported at /metrics.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	Registry *metrics.Registry
+
+	requestDuration    *metrics.Histogram // method, route, code
+	searchDuration     *metrics.Histogram // backend
+	enrichDuration     *metrics.Histogram // strategy
+	dependencyDuration *metrics.Histogram // service, operation
+	dependencyErrors   *metrics.Counter   // service, operation
+	enrichQueueDepth   *metrics.Gauge
+	cacheLookups       *metrics.Counter // cache, result
+}
+
+// NewMetrics registers the API's metrics and the Go runtime metrics on reg.
+func NewMetrics(reg *metrics.Registry) *Metrics {
+	m := &Metrics{
+		Registry: reg,
+		requestDuration: reg.NewHistogram("bottlenecks_http_request_duration_seconds",
+			"Latency of HTTP requests by route pattern and status code.", nil, "method", "route", "code"),
+		searchDuration: reg.NewHistogram("bottlenecks_search_duration_seconds",
+			"Latency of the search step of /api/search by search backend.", nil, "backend"),
+		enrichDuration: reg.NewHistogram("bottlenecks_enrich_duration_seconds",
+			"Latency of the enrichment step of /api/search by enrichment strategy.", nil, "strategy"),
+		dependencyDuration: reg.NewHistogram("bottlenecks_dependency_call_duration_seconds",
+			"Latency of calls to the product, stock and ads services, including injected faults.", nil, "service", "operation"),
+		dependencyErrors: reg.NewCounter("bottlenecks_dependency_errors_total",
+			"Failed calls to the product, stock and ads services.", "service", "operation"),
+		enrichQueueDepth: reg.NewGauge("bottlenecks_enrich_queue_depth",
+			"Products waiting for a worker of the worker-pool enrichment strategy."),
+		cacheLookups: reg.NewCounter("bottlenecks_cache_lookups_total",
+			"Cache and pool lookups by result (hit or miss).", "cache", "result"),
+	}
+	metrics.RegisterRuntime(reg)
+	return m
+}
+
+// Dependency operations recorded by Metrics
+const (
+	opGetProduct  = "get_product"
+	opGetStock    = "get_stock"
+	opGetProducts = "get_products"
+	opGetStocks   = "get_stocks"
+	opRecommend   = "recommend"
+)
+
+// cacheEnrichedProductPool labels lookups of enrichedProductPool
+const cacheEnrichedProductPool = "enriched_product_pool"
+
+func (m *Metrics) observeDependency(service, operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.dependencyDuration.Observe(time.Since(start).Seconds(), service, operation)
+	if err != nil {
+		m.dependencyErrors.Inc(service, operation)
+	}
+}
+
+func (m *Metrics) addEnrichQueueDepth(delta float64) {
+	if m == nil {
+		return
+	}
+	m.enrichQueueDepth.Add(delta)
+}
+
+func (m *Metrics) cacheLookup(cache string, hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.Inc(cache, result)
+}
+
+// Middleware records the latency of every request by its route pattern, e.g. /api/stock/reservations/{id}/commit,
+// so that IDs in paths do not create a series each. Unrouted requests are recorded as "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requestDuration.Observe(time.Since(start).Seconds(), r.Method, route, strconv.Itoa(status))
+	})
+}
+
+// Instrument records the latency and errors of every call to the product, stock and ads
+// services, the worker pool's queue depth and pool reuse in m, traces each of those calls
+// as a child span of the span in the call's context and logs failed calls. Calls the ads service makes to the product
+// and stock services are recorded too. m may be nil to trace only.
+func (svc *Services) Instrument(m *Metrics) {
+	svc.metrics = m
+	svc.Products = &instrumentedProductService{next: svc.Products, metrics: m}
+	svc.Stock = &instrumentedStockService{next: svc.Stock, metrics: m}
+	svc.Ads.ProductService = svc.Products
+	svc.Ads.StockService = svc.Stock
+}
+
+type instrumentedProductService struct {
+	next    product.ProductService
+	metrics *Metrics
+}
+
+func (s *instrumentedProductService) GetProductByID(ctx context.Context, id int) (*product.Product, error) {
+	ctx, span := startDependencySpan(ctx, "product.GetProductByID", tracing.Int("product.id", id))
+	defer span.End()
+	start := time.Now()
+	prod, err := s.next.GetProductByID(ctx, id)
+	s.metrics.observeDependency(fault.ServiceProduct, opGetProduct, start, err)
+	logDependencyError(ctx, fault.ServiceProduct, opGetProduct, err, slog.Int("product_id", id))
+	span.RecordError(err)
+	return prod, err
+}
+
+func (s *instrumentedProductService) GetProductsByIDs(ctx context.Context, ids []int) ([]*product.Product, error) {
+	ctx, span := startDependencySpan(ctx, "product.GetProductsByIDs", tracing.Int("product.count", len(ids)))
+	defer span.End()
+	start := time.Now()
+	products, err := product.GetProducts(ctx, s.next, ids)
+	s.metrics.observeDependency(fault.ServiceProduct, opGetProducts, start, err)
+	logDependencyError(ctx, fault.ServiceProduct, opGetProducts, err, slog.Int("product_count", len(ids)))
+	span.RecordError(err)
+	return products, err
+}
+
+type instrumentedStockService struct {
+	next    stock.StockService
+	metrics *Metrics
+}
+
+func (s *instrumentedStockService) GetStockByProductID(ctx context.Context, id int) (*stock.Stock, error) {
+	ctx, span := startDependencySpan(ctx, "stock.GetStockByProductID", tracing.Int("product.id", id))
+	defer span.End()
+	start := time.Now()
+	stk, err := s.next.GetStockByProductID(ctx, id)
+	s.metrics.observeDependency(fault.ServiceStock, opGetStock, start, err)
+	logDependencyError(ctx, fault.ServiceStock, opGetStock, err, slog.Int("product_id", id))
+	span.RecordError(err)
+	return stk, err
+}
+
+func (s *instrumentedStockService) GetStocksByProductIDs(ctx context.Context, ids []int) ([]*stock.Stock, error) {
+	ctx, span := startDependencySpan(ctx, "stock.GetStocksByProductIDs", tracing.Int("product.count", len(ids)))
+	defer span.End()
+	start := time.Now()
+	stocks, err := stock.GetStocks(ctx, s.next, ids)
+	s.metrics.observeDependency(fault.ServiceStock, opGetStocks, start, err)
+	logDependencyError(ctx, fault.ServiceStock, opGetStocks, err, slog.Int("product_count", len(ids)))
+	span.RecordError(err)
+	return stocks, err
+}
+
+// logDependencyError logs a failed call with the logger of the request ctx belongs to.
+func logDependencyError(ctx context.Context, service, operation string, err error, attrs ...slog.Attr) {
+	if err == nil {
+		return
+	}
+	attrs = append(attrs, slog.String("service", service), slog.String("operation", operation), slog.Any("error", err))
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "dependency call failed", attrs...)
+}
+
+// startDependencySpan starts a span for a call to the product, stock or ads service. Remote
+// calls get a client span of their own from the HTTP transport underneath.
+func startDependencySpan(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, name, tracing.WithAttributes(attrs...))
+}
+
+// recommendAds picks up to slots sponsored products for ids. Failures yield no ads.
+func (svc *Services) recommendAds(ctx context.Context, ids []int, slots int) []ads.SponsoredProduct {
+	ctx, span := startDependencySpan(ctx, "ads.RecommendProductsByIDs", tracing.Int("ads.candidates", len(ids)), tracing.Int("ads.slots", slots))
+	defer span.End()
+	start := time.Now()
+	var sponsored []ads.SponsoredProduct
+	var err error
+	pprof.Do(ctx, pprof.Labels("stage", stageAds), func(ctx context.Context) {
+		sponsored, err = svc.Ads.RecommendProductsByIDs(ctx, ids, slots)
+	})
+	svc.metrics.observeDependency(fault.ServiceAds, opRecommend, start, err)
+	stageTimingsFrom(ctx).since(stageAds, start)
+	logDependencyError(ctx, fault.ServiceAds, opRecommend, err, slog.Int("candidates", len(ids)))
+	span.RecordError(err)
+	return sponsored
+}
+
+// getEnrichedProduct takes an EnrichedProduct from enrichedProductPool, recording whether one
+// could be reused.
+func (svc *Services) getEnrichedProduct() *EnrichedProduct {
+	if item, ok := enrichedProductPool.Get().(*EnrichedProduct); ok {
+		svc.metrics.cacheLookup(cacheEnrichedProductPool, true)
+		return item
+	}
+	svc.metrics.cacheLookup(cacheEnrichedProductPool, false)
+	return new(EnrichedProduct)
+}
+
+// putEnrichedProduct returns item to enrichedProductPool once it has been copied into a result.
+func putEnrichedProduct(item *EnrichedProduct) {
+	*item = EnrichedProduct{}
+	enrichedProductPool.Put(item)
+}
+
+// HandleMetrics serves the metrics in the Prometheus text format
+// @Summary Prometheus Metrics
+// @Description Returns request, search, enrichment and dependency latency histograms, dependency error counts, the worker pool's queue depth, pool hits and Go runtime metrics in the Prometheus text exposition format
+// @Tags system
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (s *Server) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.metrics.Registry.Handler().ServeHTTP(w, r)
+}
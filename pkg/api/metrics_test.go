@@ -0,0 +1,63 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/metrics"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+func fakeSearcher(query []float64, pageSize int) ([]search.ScoredProduct, float64) {
+	return search.SearchProductsByVector(query, pageSize)
+}
+
+func TestSearchMetricsNameInjectedImplementations(t *testing.T) {
+	for _, tc := range []struct {
+		searcher          Searcher
+		enricher          Enricher
+		names             ServerOptions // Only Backend and Strategy are set
+		backend, strategy string
+	}{
+		{search.SearchProductsByVector, EnrichParallel, ServerOptions{Backend: config.BackendBruteForce, Strategy: config.StrategyParallel},
+			config.BackendBruteForce, config.StrategyParallel},
+		// An unnamed searcher is reported by its function name
+		{fakeSearcher, EnrichWorkerPool(3), ServerOptions{Strategy: config.StrategyWorkerPool}, "api.fakeSearcher", config.StrategyWorkerPool},
+	} {
+		svc, err := NewServices(benchSim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		m := NewMetrics(metrics.NewRegistry())
+		svc.Instrument(m)
+		// The configured backend and strategy differ from the named ones and must not be reported
+		cfg := config.Default()
+		cfg.Search.Backend, cfg.Search.Strategy = config.BackendQdrant, config.StrategySequential
+		r := chi.NewRouter()
+		opts := tc.names
+		opts.Config, opts.Metrics = cfg, m
+		NewServer(svc, tc.searcher, tc.enricher, opts).Routes(r)
+
+		for range 5 {
+			serve(r, "GET", "/api/search?term=phone&itemCount=10")
+		}
+		body := serve(r, "GET", "/metrics").Body.String()
+		for _, want := range []string{
+			`bottlenecks_search_duration_seconds_count{backend="` + tc.backend + `"} 5`,
+			`bottlenecks_enrich_duration_seconds_count{strategy="` + tc.strategy + `"} 5`,
+			`bottlenecks_cache_lookups_total{cache="enriched_product_pool",result="hit"}`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s/%s: /metrics lacks %s", tc.backend, tc.strategy, want)
+			}
+		}
+		for _, unwanted := range []string{`backend="` + config.BackendQdrant + `"`, `strategy="` + config.StrategySequential + `"`} {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("%s/%s: /metrics is labelled from the config: %s", tc.backend, tc.strategy, unwanted)
+			}
+		}
+	}
+}
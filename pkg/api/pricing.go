@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+)
+
+// pricing is how prices are shown in a response
+type pricing struct {
+	locale   money.Locale
+	currency string
+}
+
+// parsePricing reads the locale from the Accept-Language header and the currency from the currency
+// query parameter, which defaults to the locale's currency.
+func (s *Server) parsePricing(r *http.Request) (pricing, error) {
+	p := pricing{locale: money.MatchLocale(r.Header.Get("Accept-Language"))}
+	p.currency = p.locale.Currency
+	if c := r.URL.Query().Get("currency"); c != "" {
+		p.currency = strings.ToUpper(c)
+	}
+	if !s.rates.Supports(p.currency) {
+		return pricing{}, fmt.Errorf("unsupported currency %q", p.currency)
+	}
+	return p, nil
+}
+
+// localizePrices converts and formats the prices of items in place. The enrichers format prices
+// for money.DefaultLocale in the stored currency, so that case is left untouched.
+// Items whose price cannot be converted keep it in the stored currency.
+func (s *Server) localizePrices(items []EnrichedProduct, p pricing) {
+	for i := range items {
+		item := &items[i]
+		if item.PriceValue.Currency == p.currency && p.locale == money.DefaultLocale {
+			continue
+		}
+		if converted, err := s.rates.Convert(item.PriceValue, p.currency); err == nil {
+			item.PriceValue = converted
+		}
+		item.Price = money.Format(item.PriceValue, p.locale)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+)
+
+// reserveItem is one product of a reservation request
+type reserveItem struct {
+	ProductID int `json:"productId"`
+	Quantity  int `json:"quantity"`
+}
+
+// reserveRequest is the request body of the reserve endpoint
+type reserveRequest struct {
+	Items []reserveItem `json:"items"`
+}
+
+// HandleReserveStock reserves items picked from search results
+// @Summary Reserve Stock
+// @Description Reserves the given quantities of products from search results. Either all items are reserved or none (requires -stateful)
+// @Tags stock
+// @Accept json
+// @Param reservation body reserveRequest true "Products and quantities to reserve"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Router /api/stock/reserve [post]
+func (s *Server) HandleReserveStock(w http.ResponseWriter, r *http.Request) {
+	if s.services.StockStore == nil {
+		writeStoreDisabled(w, fault.ServiceStock, s.services.remoteStock)
+		return
+	}
+	var req reserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Items) == 0 {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid request body"})
+		return
+	}
+
+	reservations := make([]*stock.Reservation, 0, len(req.Items))
+	for _, item := range req.Items {
+		res, err := s.services.StockStore.Reserve(item.ProductID, item.Quantity)
+		if err != nil {
+			// All or nothing: give back what was already reserved
+			for _, done := range reservations {
+				s.services.StockStore.Release(done.ID)
+			}
+			writeJSON(w, reservationErrorStatus(err), Response{Success: false, Message: err.Error()})
+			return
+		}
+		reservations = append(reservations, res)
+	}
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Items reserved",
+		Data: map[string]interface{}{
+			"reservations": reservations,
+		},
+	})
+}
+
+// HandleCommitReservation commits a reservation
+// @Summary Commit Reservation
+// @Description Commits a reservation; the reserved items are sold and stay out of stock (requires -stateful)
+// @Tags stock
+// @Param id path string true "Reservation ID"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Router /api/stock/reservations/{id}/commit [post]
+func (s *Server) HandleCommitReservation(w http.ResponseWriter, r *http.Request) {
+	s.finishReservation(w, r, "Reservation committed", func(id string) error { return s.services.StockStore.Commit(id) })
+}
+
+// HandleReleaseReservation releases a reservation
+// @Summary Release Reservation
+// @Description Releases a reservation; the reserved items are returned to stock (requires -stateful)
+// @Tags stock
+// @Param id path string true "Reservation ID"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 404 {object} Response
+// @Failure 409 {object} Response
+// @Router /api/stock/reservations/{id}/release [post]
+func (s *Server) HandleReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	s.finishReservation(w, r, "Reservation released", func(id string) error { return s.services.StockStore.Release(id) })
+}
+
+func (s *Server) finishReservation(w http.ResponseWriter, r *http.Request, message string, finish func(id string) error) {
+	if s.services.StockStore == nil {
+		writeStoreDisabled(w, fault.ServiceStock, s.services.remoteStock)
+		return
+	}
+	if err := finish(chi.URLParam(r, "id")); err != nil {
+		writeJSON(w, reservationErrorStatus(err), Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: message})
+}
+
+// reservationErrorStatus maps stock store errors to HTTP status codes
+func reservationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, stock.ErrStockNotFound), errors.Is(err, stock.ErrReservationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, stock.ErrInsufficientStock):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
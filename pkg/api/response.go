@@ -1,25 +1,32 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+)
 
 // Response holds the unified API response structure
 type Response struct {
-	Success   bool        `json:"success"`
-	Message   string      `json:"message,omitempty"`
-	Data      interface{} `json:"data,omitempty"`
-	Duration  string      `json:"duration,omitempty"`
-	StartTime time.Time   `json:"startTime,omitempty"`
-	EndTime   time.Time   `json:"endTime,omitempty"`
+	Success   bool          `json:"success"`
+	Message   string        `json:"message,omitempty"`
+	Data      interface{}   `json:"data,omitempty"`
+	Duration  string        `json:"duration,omitempty"`
+	StartTime time.Time     `json:"startTime,omitzero"`
+	EndTime   time.Time     `json:"endTime,omitzero"`
+	Timings   []StageTiming `json:"timings,omitempty"` // Only with debug=timings
 }
 
 // EnrichedProduct holds both the search score and detailed product info
 // This struct is used to return all relevant info in the response
 // (score + product details)
 type EnrichedProduct struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       string  `json:"price"` // Price formatted as string with currency
-	Score       float64 `json:"score"`
-	Stock       int     `json:"stock"` // Stock quantity for the product
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       string      `json:"price"`      // Price formatted for the request's locale, e.g. "₺1.234,50"
+	PriceValue  money.Money `json:"priceValue"` // Price in minor units of the requested currency
+	Score       float64     `json:"score"`
+	Stock       int         `json:"stock"`     // Stock quantity for the product
+	Sponsored   bool        `json:"sponsored"` // True if the product was injected by AdsService
 }
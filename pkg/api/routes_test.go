@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/debugserver"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	svc, err := NewServices(benchSim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	return NewServer(svc, search.SearchProductsHeapOptimizedByVector, EnrichSequential, ServerOptions{})
+}
+
+// serve sends a request without a body to h and returns the recorded response.
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestDebugAndAdminRoutesOnlyOnDebugServer(t *testing.T) {
+	s := newTestServer(t)
+	public := chi.NewRouter()
+	s.Routes(public)
+	debug := debugserver.Handler(debugserver.Options{Username: "admin", Password: "secret", Routes: s.DebugRoutes, Admin: s.AdminRoutes})
+
+	for _, target := range []string{"/debug/config", "/debug/trace", "/debug/profiles", "/debug/pprof/", "/admin/faults"} {
+		if got := serve(public, "GET", target).Code; got != http.StatusNotFound {
+			t.Errorf("public GET %s = %d, want 404", target, got)
+		}
+		if got := serve(debug, "GET", target).Code; got != http.StatusUnauthorized {
+			t.Errorf("debug server GET %s without credentials = %d, want 401", target, got)
+		}
+	}
+	for _, target := range []string{"/debug/trace/start", "/debug/trace/stop", "/debug/trace/flight", "/admin/faults"} {
+		if got := serve(public, "POST", target).Code; got != http.StatusNotFound {
+			t.Errorf("public POST %s = %d, want 404", target, got)
+		}
+		if got := serve(debug, "POST", target).Code; got != http.StatusUnauthorized {
+			t.Errorf("debug server POST %s without credentials = %d, want 401", target, got)
+		}
+	}
+	for _, target := range []string{"/admin/products/1", "/admin/stock/1"} {
+		if got := serve(public, "PUT", target).Code; got != http.StatusNotFound {
+			t.Errorf("public PUT %s = %d, want 404", target, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/debug/config", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec := httptest.NewRecorder()
+	debug.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("debug server GET /debug/config = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/logging"
+	"github.com/idagdelen/go-bottlenecks/internal/metrics"
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/replay"
+	"github.com/idagdelen/go-bottlenecks/internal/runtimetrace"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+	"github.com/idagdelen/go-bottlenecks/internal/tracing"
+)
+
+// Searcher returns the best pageSize products for a query embedded with search.Embed and the sum
+// of their scores.
+type Searcher func(query []float64, pageSize int) ([]search.ScoredProduct, float64)
+
+// SearcherByName returns the search backend with the given name (see config.SearchBackends).
+// qdrantURL is only used by the qdrant backend.
+func SearcherByName(name, qdrantURL string) (Searcher, error) {
+	switch name {
+	case config.BackendHeap:
+		return search.SearchProductsHeapOptimizedByVector, nil
+	case config.BackendBruteForce:
+		return search.SearchProductsByVector, nil
+	case config.BackendQdrant:
+		return search.NewQdrantSearcher(qdrantURL), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", name)
+	}
+}
+
+// Enricher adds product details and stock levels from svc to search hits and picks up to
+// adSlots sponsored products. ctx is passed on to every service call.
+type Enricher func(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct)
+
+// Enrichment strategies, selected by name with the search.strategy setting
+var (
+	EnrichSequential          Enricher = enrichProductsWithDetailsAndAd
+	EnrichParallel            Enricher = enrichProductsWithDetailsParallelAndIndexBased
+	EnrichParallelPrefetchAds Enricher = enrichProductsWithDetailsParallelAndIndexBased_v2
+	EnrichBatch               Enricher = enrichProductsWithDetailsBatch
+)
+
+// EnrichWorkerPool returns the worker pool strategy with the given number of workers.
+func EnrichWorkerPool(workers int) Enricher {
+	return func(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
+		return enrichProductsWithDetailsAndAdWorkerPool(ctx, svc, products, adSlots, workers)
+	}
+}
+
+// EnricherByName returns the enrichment strategy with the given name (see config.EnrichStrategies).
+// workers is only used by the worker pool.
+func EnricherByName(name string, workers int) (Enricher, error) {
+	switch name {
+	case config.StrategySequential:
+		return EnrichSequential, nil
+	case config.StrategyWorkerPool:
+		return EnrichWorkerPool(workers), nil
+	case config.StrategyParallel:
+		return EnrichParallel, nil
+	case config.StrategyParallelPrefetchAds:
+		return EnrichParallelPrefetchAds, nil
+	case config.StrategyBatch:
+		return EnrichBatch, nil
+	default:
+		return nil, fmt.Errorf("unknown enrichment strategy %q", name)
+	}
+}
+
+// funcName returns the name of fn without its package path, e.g. "api.fakeSearcher". It names a
+// searcher or enricher that was given no name in ServerOptions, such as a fake in a test.
+func funcName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return path.Base(f.Name())
+}
+
+// Server serves the HTTP API. Its handlers are methods, so that every Server has its own services;
+// tests and benchmarks can build as many differently configured servers as they need.
+type Server struct {
+	services *Services
+	searcher Searcher
+	enricher Enricher
+	backend  string // Name of searcher, see ServerOptions.Backend
+	strategy string // Name of enricher, see ServerOptions.Strategy
+	config   *config.Config
+	adEvents *ads.EventLog
+	rates    *money.RateTable
+	traces   *runtimetrace.Controller
+	metrics  *Metrics
+	logger   *slog.Logger
+	tracer   *tracing.Tracer
+	recorder *replay.Recorder
+}
+
+// ServerOptions are the optional collaborators of a Server.
+type ServerOptions struct {
+	Config   *config.Config           // Settings read by the handlers and shown by /debug/config (config.Default() if nil)
+	AdEvents *ads.EventLog            // Ad event log, e.g. with file spillover (in memory with Config.Ads.EventsCapacity if nil)
+	Rates    *money.RateTable         // Exchange rates used to show prices in other currencies (money.DefaultRates() if nil)
+	Traces   *runtimetrace.Controller // Runtime trace captures (in Config.Debug.TraceDir if nil)
+	Metrics  *Metrics                 // Instruments served at /metrics (on a new registry if nil); see also Services.Instrument
+	Logger   *slog.Logger             // Request logger of Router (slog.Default() if nil)
+	Tracer   *tracing.Tracer          // Request spans started by Router (none if nil)
+	Recorder *replay.Recorder         // Records requests served by Router for cmd/replay (none if nil)
+
+	// Names of the searcher and enricher in metrics, spans, logs and profiler labels, e.g. the names
+	// they were selected by with SearcherByName and EnricherByName (their Go function names if empty)
+	Backend  string
+	Strategy string
+}
+
+// NewServer returns a Server searching with searcher and enriching the hits from services with enricher.
+func NewServer(services *Services, searcher Searcher, enricher Enricher, opts ServerOptions) *Server {
+	s := &Server{
+		services: services,
+		searcher: searcher,
+		enricher: enricher,
+		backend:  opts.Backend,
+		strategy: opts.Strategy,
+		config:   opts.Config,
+		adEvents: opts.AdEvents,
+		rates:    opts.Rates,
+		traces:   opts.Traces,
+		metrics:  opts.Metrics,
+		logger:   opts.Logger,
+		tracer:   opts.Tracer,
+		recorder: opts.Recorder,
+	}
+	if s.config == nil {
+		s.config = config.Default()
+	}
+	if s.backend == "" {
+		s.backend = funcName(searcher)
+	}
+	if s.strategy == "" {
+		s.strategy = funcName(enricher)
+	}
+	if s.adEvents == nil {
+		s.adEvents = ads.NewEventLog(s.config.Ads.EventsCapacity, nil)
+	}
+	if s.rates == nil {
+		s.rates = money.DefaultRates()
+	}
+	if s.traces == nil {
+		s.traces = runtimetrace.NewController(s.config.Debug.TraceDir, s.config.Debug.TraceMaxFiles)
+	}
+	if s.metrics == nil {
+		s.metrics = NewMetrics(metrics.NewRegistry())
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	return s
+}
+
+// Router returns a router serving Routes behind the middleware of the service: request IDs, metrics,
+// spans, request logs, request recording, panic recovery and Config.Server.RequestTimeout.
+// More routes may be added to it.
+func (s *Server) Router() chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(s.metrics.Middleware)
+	r.Use(s.tracer.Middleware)
+	r.Use(logging.Middleware(s.logger))
+	r.Use(s.recorder.Middleware)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Timeout(time.Duration(s.config.Server.RequestTimeout)))
+	s.Routes(r)
+	return r
+}
+
+// Routes registers the API and metrics endpoints on r. The debug and admin endpoints are not among
+// them, they are only served by the debug server (see DebugRoutes and AdminRoutes).
+func (s *Server) Routes(r chi.Router) {
+	r.Get("/metrics", s.HandleMetrics)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/health", s.HandleHealthCheck)
+		r.Get("/search", s.HandleSearch)
+
+		r.Route("/ads", func(r chi.Router) {
+			r.Post("/impression", s.HandleAdImpression)
+			r.Post("/click", s.HandleAdClick)
+			r.Get("/stats", s.HandleAdStats)
+		})
+
+		r.Route("/stock", func(r chi.Router) {
+			r.Post("/reserve", s.HandleReserveStock)
+			r.Post("/reservations/{id}/commit", s.HandleCommitReservation)
+			r.Post("/reservations/{id}/release", s.HandleReleaseReservation)
+		})
+	})
+
+}
+
+// DebugRoutes registers the configuration, trace control and profiling snapshot endpoints on r. Only the
+// debug server (see internal/debugserver) mounts them, under /debug and behind its basic auth.
+func (s *Server) DebugRoutes(r chi.Router) {
+	r.Get("/config", s.HandleDebugConfig)
+
+	r.Get("/trace", s.HandleTraceStatus)
+	r.Post("/trace/start", s.HandleStartTrace)
+	r.Post("/trace/stop", s.HandleStopTrace)
+	r.Post("/trace/flight", s.HandleDumpFlightRecorder)
+
+	r.Get("/profiles", s.HandleListProfiles)
+	r.Get("/profiles/{snapshot}/{file}", s.HandleGetProfile)
+}
+
+// AdminRoutes registers the fault injection and store update endpoints on r. They change what the
+// API serves, so only the debug server mounts them, under /admin and behind its basic auth.
+func (s *Server) AdminRoutes(r chi.Router) {
+	r.Get("/faults", s.HandleListFaults)
+	r.Post("/faults", s.HandleAddFault)
+	r.Delete("/faults", s.HandleClearFaults)
+	r.Delete("/faults/{id}", s.HandleDeleteFault)
+
+	r.Put("/products/{id}", s.HandleUpdateProduct)
+	r.Put("/stock/{id}", s.HandleUpdateStock)
+}
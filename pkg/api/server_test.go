@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+)
+
+// fakeCatalog serves products and stock levels for IDs the search catalog does not have.
+type fakeCatalog struct {
+	calls atomic.Int64
+}
+
+func (c *fakeCatalog) GetProductByID(ctx context.Context, id int) (*product.Product, error) {
+	c.calls.Add(1)
+	return &product.Product{ID: id, Name: "Fake", Price: money.Money{Amount: 100 * int64(id), Currency: "TRY"}}, nil
+}
+
+func (c *fakeCatalog) GetStockByProductID(ctx context.Context, id int) (*stock.Stock, error) {
+	c.calls.Add(1)
+	return &stock.Stock{ProductID: id, Quantity: id % 7}, nil
+}
+
+func fakeHits(query []float64, pageSize int) ([]search.ScoredProduct, float64) {
+	return []search.ScoredProduct{{Product: &search.Product{ID: 1001}, Score: 0.9}, {Product: &search.Product{ID: 1002}, Score: 0.5}}, 1.4
+}
+
+func fakeEnricher(ctx context.Context, svc *Services, products []search.ScoredProduct, adSlots int) ([]EnrichedProduct, []ads.SponsoredProduct) {
+	eps := make([]EnrichedProduct, len(products))
+	for i, p := range products {
+		eps[i] = EnrichedProduct{ID: p.ID, Name: "Enriched", Score: p.Score, Stock: 1}
+	}
+	return eps, nil
+}
+
+func TestServerUsesInjectedImplementations(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		enricher  Enricher
+		wantName  string
+		wantStock map[int]int
+		wantCalls bool // whether the fake services are called
+	}{
+		{"services", EnrichSequential, "Fake", map[int]int{1001: 1001 % 7, 1002: 1002 % 7}, true},
+		{"enricher", fakeEnricher, "Enriched", map[int]int{1001: 1, 1002: 1}, false},
+	} {
+		svc, err := NewServices(benchSim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		fake := &fakeCatalog{}
+		svc.Products, svc.Stock = fake, fake
+		svc.Ads.ProductService, svc.Ads.StockService = fake, fake
+		r := chi.NewRouter()
+		NewServer(svc, fakeHits, tc.enricher, ServerOptions{}).Routes(r)
+
+		rec := serve(r, "GET", "/api/search?term=phone&adSlots=0")
+		var resp struct {
+			Data struct {
+				Result   []EnrichedProduct `json:"result"`
+				TotalSum float64           `json:"totalSum"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v in %s", tc.name, err, rec.Body)
+		}
+		if resp.Data.TotalSum != 1.4 || len(resp.Data.Result) != 2 {
+			t.Fatalf("%s: totalSum %v and %d results, want the fake searcher's 1.4 and 2", tc.name, resp.Data.TotalSum, len(resp.Data.Result))
+		}
+		for i, p := range resp.Data.Result {
+			if want := 1001 + i; p.ID != want || p.Name != tc.wantName || p.Stock != tc.wantStock[want] {
+				t.Errorf("%s: result %d is %+v, want product %d named %q with stock %d", tc.name, i, p, want, tc.wantName, tc.wantStock[want])
+			}
+		}
+		if called := fake.calls.Load() > 0; called != tc.wantCalls {
+			t.Errorf("%s: fake services called: %v, want %v", tc.name, called, tc.wantCalls)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/catalog"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+	"github.com/idagdelen/go-bottlenecks/internal/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Services are the backends a Server calls. Products, Stock and Ads apply the rules of Faults.
+// The in-memory stores are nil unless the services run in-process with SimulationConfig.Stateful set.
+// Close releases the connections to remote services.
+type Services struct {
+	Products     product.ProductService
+	Stock        stock.StockService
+	Ads          *ads.AdsService
+	Faults       *fault.Injector
+	ProductStore *product.InMemoryProductService
+	StockStore   stock.Store
+
+	metrics *Metrics           // See Instrument
+	conns   []*grpc.ClientConn // Connections to remote gRPC services, see Close
+
+	remoteProducts, remoteStock bool // Set for services called remotely, which own their data
+}
+
+// Close closes the connections to remote gRPC services. The Services must not be used afterwards.
+func (svc *Services) Close() error {
+	var errs []error
+	for _, conn := range svc.conns {
+		errs = append(errs, conn.Close())
+	}
+	svc.conns = nil
+	return errors.Join(errs...)
+}
+
+// SimulationConfig controls how the simulated product, stock and ads services behave.
+// A nil latency model keeps the default uniform 0-40ms delay.
+// If Stateful is set, product and stock data come from in-memory stores seeded from the
+// search catalog instead of being generated randomly on every call. StockShards selects the
+// stock store's locking: 0 uses a mutex per product, a positive value a sharded store, and
+// ReservationTTL how long its reservations hold their items.
+// ErrorRate is the probability that a simulated call fails (zero disables generic errors)
+// and AdSlotInterval the number of organic results between two sponsored slots (0 = ads.DefaultSlotInterval).
+//
+// If ProductServiceURL or StockServiceURL is set, that service is called remotely (see
+// cmd/productsvc and cmd/stocksvc) instead of running in-process; its latency and data are
+// then configured on the remote process. The URL scheme picks the transport: http:// or
+// https:// for JSON over HTTP, grpc://host:port for gRPC. Fault rules still apply on the client side.
+type SimulationConfig struct {
+	Seed           int64
+	Stateful       bool
+	StockShards    int
+	ReservationTTL time.Duration // 0 = stock.DefaultReservationTTL
+	ErrorRate      float64
+	AdSlotInterval int
+	ProductLatency util.LatencyModel
+	StockLatency   util.LatencyModel
+	AdsLatency     util.LatencyModel
+
+	ProductServiceURL   string
+	StockServiceURL     string
+	ServiceMaxIdleConns int           // idle HTTP connections kept per remote service (0 = 100)
+	ServiceTimeout      time.Duration // timeout of a remote service call (0 = 5s)
+}
+
+// SetLatencies assigns latency models by service name ("product", "stock" or "ads").
+func (c *SimulationConfig) SetLatencies(models map[string]util.LatencyModel) error {
+	for name, m := range models {
+		switch name {
+		case "product":
+			c.ProductLatency = m
+		case "stock":
+			c.StockLatency = m
+		case "ads":
+			c.AdsLatency = m
+		default:
+			return fmt.Errorf("unknown simulated service %q", name)
+		}
+	}
+	return nil
+}
+
+// NewServices creates the simulated services from cfg. RNGs are derived from cfg.Seed,
+// so that a run replays the same latencies, errors, product data and stock levels.
+// Concurrent enrichment strategies still interleave RNG draws in scheduling order.
+// util's default RNG is left alone; callers that use it seed it with util.SetSeed.
+func NewServices(cfg SimulationConfig) (*Services, error) {
+	latencyOrDefault := func(m util.LatencyModel) util.LatencyModel {
+		if m == nil {
+			return util.DefaultLatency
+		}
+		return m
+	}
+
+	svc := &Services{}
+	var productBackend product.ProductService
+	var stockBackend stock.StockService
+	if cfg.Stateful {
+		svc.ProductStore, svc.StockStore = newCatalogStores(cfg.Seed, cfg.StockShards)
+		if cfg.ReservationTTL > 0 {
+			if err := svc.StockStore.SetReservationTTL(cfg.ReservationTTL); err != nil {
+				return nil, err
+			}
+		}
+		productBackend, stockBackend = svc.ProductStore, svc.StockStore
+	}
+
+	var prodSvc product.ProductService = product.NewSimulatedProductServiceWithErrorRate(
+		util.NewRand(util.DeriveSeed(cfg.Seed, "product")), latencyOrDefault(cfg.ProductLatency), productBackend, cfg.ErrorRate)
+	var stockSvc stock.StockService = stock.NewSimulatedStockServiceWithErrorRate(
+		util.NewRand(util.DeriveSeed(cfg.Seed, "stock")), latencyOrDefault(cfg.StockLatency), stockBackend, cfg.ErrorRate)
+
+	// Remote services own their data, so the local stores are not used for them
+	if cfg.ProductServiceURL != "" {
+		transport, target, err := parseServiceURL(cfg.ProductServiceURL)
+		if err != nil {
+			return nil, err
+		}
+		if transport == "grpc" {
+			conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				return nil, fmt.Errorf("product service: %w", err)
+			}
+			svc.conns = append(svc.conns, conn)
+			prodSvc = product.NewGRPCProductService(conn, cfg.ServiceTimeout)
+		} else {
+			prodSvc = product.NewHTTPProductService(target, util.NewHTTPClient(cfg.ServiceMaxIdleConns, cfg.ServiceTimeout))
+		}
+		svc.ProductStore, svc.remoteProducts = nil, true
+	}
+	if cfg.StockServiceURL != "" {
+		transport, target, err := parseServiceURL(cfg.StockServiceURL)
+		if err != nil {
+			svc.Close()
+			return nil, err
+		}
+		if transport == "grpc" {
+			conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			if err != nil {
+				svc.Close()
+				return nil, fmt.Errorf("stock service: %w", err)
+			}
+			svc.conns = append(svc.conns, conn)
+			stockSvc = stock.NewGRPCStockService(conn, cfg.ServiceTimeout)
+		} else {
+			stockSvc = stock.NewHTTPStockService(target, util.NewHTTPClient(cfg.ServiceMaxIdleConns, cfg.ServiceTimeout))
+		}
+		svc.StockStore, svc.remoteStock = nil, true
+	}
+
+	svc.Faults = fault.NewInjector(util.NewRand(util.DeriveSeed(cfg.Seed, "fault")))
+	svc.Products = fault.WrapProductService(prodSvc, svc.Faults)
+	svc.Stock = fault.WrapStockService(stockSvc, svc.Faults)
+	svc.Ads = ads.NewAdsServiceWithLatency(svc.Products, svc.Stock,
+		util.NewRand(util.DeriveSeed(cfg.Seed, "ads")), latencyOrDefault(cfg.AdsLatency))
+	svc.Ads.Faults = svc.Faults
+	svc.Ads.ErrorRate = cfg.ErrorRate
+	svc.Ads.Inventory = catalog.AdInventory(cfg.Seed)
+	if cfg.AdSlotInterval > 0 {
+		svc.Ads.SlotInterval = cfg.AdSlotInterval
+	}
+	return svc, nil
+}
+
+// parseServiceURL splits a remote service URL into its transport ("http" or "grpc") and the
+// target to dial: the URL itself for HTTP, host:port for gRPC.
+func parseServiceURL(raw string) (transport, target string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("service url %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return "http", raw, nil
+	case "grpc":
+		return "grpc", u.Host, nil
+	default:
+		return "", "", fmt.Errorf("service url %q: scheme must be http, https or grpc", raw)
+	}
+}
+
+// newCatalogStores seeds product and stock stores from the search catalog.
+// Prices, descriptions and stock levels are generated once from seed, so they are stable for the whole run.
+func newCatalogStores(seed int64, stockShards int) (*product.InMemoryProductService, stock.Store) {
+	products, quantities := catalog.Generate(seed)
+	if stockShards > 0 {
+		return product.NewInMemoryProductService(products), stock.NewShardedStockService(quantities, stockShards)
+	}
+	return product.NewInMemoryProductService(products), stock.NewInMemoryStockService(quantities)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+)
+
+// parseAdSlots parses the adSlots query parameter, falling back to ads.defaultSlots
+// and capping the value at ads.maxSlots of the server configuration.
+func (s *Server) parseAdSlots(raw string) int {
+	slots, err := strconv.Atoi(raw)
+	if err != nil || slots < 0 {
+		return s.config.Ads.DefaultSlots
+	}
+	if slots > s.config.Ads.MaxSlots {
+		return s.config.Ads.MaxSlots
+	}
+	return slots
+}
+
+// injectSponsoredProducts interleaves sponsored products into the organic results at their positions.
+// Organic hits that are also sponsored are dropped so a product never appears twice.
+func injectSponsoredProducts(organic []EnrichedProduct, sponsored []ads.SponsoredProduct) []EnrichedProduct {
+	if len(sponsored) == 0 {
+		return organic
+	}
+
+	sponsoredIDs := make(map[int]struct{}, len(sponsored))
+	for _, sp := range sponsored {
+		sponsoredIDs[sp.Product.ID] = struct{}{}
+	}
+	deduped := make([]EnrichedProduct, 0, len(organic))
+	for _, p := range organic {
+		if _, ok := sponsoredIDs[p.ID]; !ok {
+			deduped = append(deduped, p)
+		}
+	}
+
+	merged := make([]EnrichedProduct, 0, len(deduped)+len(sponsored))
+	next := 0
+	for _, sp := range sponsored {
+		for len(merged) < sp.Position && next < len(deduped) {
+			merged = append(merged, deduped[next])
+			next++
+		}
+		merged = append(merged, EnrichedProduct{
+			ID:          sp.Product.ID,
+			Name:        sp.Product.Name,
+			Description: sp.Product.Description,
+			Price:       sp.Product.FormatPrice(),
+			PriceValue:  sp.Product.Price,
+			Score:       0, // Not relevant for ad
+			Stock:       sp.Stock.Quantity,
+			Sponsored:   true,
+		})
+	}
+	return append(merged, deduped[next:]...)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
+	"github.com/idagdelen/go-bottlenecks/internal/config"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+)
+
+func sponsoredAt(id, position int) ads.SponsoredProduct {
+	return ads.SponsoredProduct{
+		RecommendedProduct: ads.RecommendedProduct{Product: &product.Product{ID: id}, Stock: &stock.Stock{ProductID: id, Quantity: 3}},
+		Position:           position,
+	}
+}
+
+func TestInjectSponsoredProducts(t *testing.T) {
+	organic := make([]EnrichedProduct, 10)
+	for i := range organic {
+		organic[i] = EnrichedProduct{ID: i + 1}
+	}
+	ids := func(products []EnrichedProduct) (ids []int, sponsored []int) {
+		for i, p := range products {
+			ids = append(ids, p.ID)
+			if p.Sponsored {
+				sponsored = append(sponsored, i)
+			}
+		}
+		return ids, sponsored
+	}
+
+	for _, tc := range []struct {
+		name          string
+		organic       []EnrichedProduct
+		sponsored     []ads.SponsoredProduct
+		wantIDs       []int
+		wantPositions []int
+	}{
+		{"no ads", organic[:3], nil, []int{1, 2, 3}, nil},
+		// With ads.DefaultSlotInterval, 4 organic results separate two slots
+		{"interval", organic, []ads.SponsoredProduct{sponsoredAt(100, 0), sponsoredAt(101, 5), sponsoredAt(102, 10)},
+			[]int{100, 1, 2, 3, 4, 101, 5, 6, 7, 8, 102, 9, 10}, []int{0, 5, 10}},
+		{"sponsored hit dropped", organic[:6], []ads.SponsoredProduct{sponsoredAt(3, 0), sponsoredAt(100, 5)},
+			[]int{3, 1, 2, 4, 5, 100, 6}, []int{0, 5}},
+		{"too few results", organic[:2], []ads.SponsoredProduct{sponsoredAt(100, 0), sponsoredAt(101, 5)},
+			[]int{100, 1, 2, 101}, []int{0, 3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			merged := injectSponsoredProducts(tc.organic, tc.sponsored)
+			gotIDs, gotPositions := ids(merged)
+			if !reflect.DeepEqual(gotIDs, tc.wantIDs) || !reflect.DeepEqual(gotPositions, tc.wantPositions) {
+				t.Errorf("got IDs %v with sponsored at %v, want %v at %v", gotIDs, gotPositions, tc.wantIDs, tc.wantPositions)
+			}
+		})
+	}
+}
+
+func TestParseAdSlots(t *testing.T) {
+	cfg := config.Default()
+	cfg.Ads.DefaultSlots, cfg.Ads.MaxSlots = 1, 5
+	s := &Server{config: cfg}
+	for raw, want := range map[string]int{"": 1, "abc": 1, "-2": 1, "0": 0, "3": 3, "5": 5, "9": 5} {
+		if got := s.parseAdSlots(raw); got != want {
+			t.Errorf("parseAdSlots(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/fault"
+	"github.com/idagdelen/go-bottlenecks/internal/money"
+	"github.com/idagdelen/go-bottlenecks/internal/product"
+	"github.com/idagdelen/go-bottlenecks/internal/stock"
+)
+
+// productUpdateRequest is the request body of the product update endpoint
+type productUpdateRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`    // Price in major units, e.g. 12.5
+	Currency    string  `json:"currency"` // ISO 4217 code of the price (default: TRY)
+}
+
+// stockUpdateRequest is the request body of the stock update endpoint
+type stockUpdateRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// HandleUpdateProduct replaces a product in the in-memory product store
+// @Summary Update Product
+// @Description Inserts or replaces a product in the in-memory product store (requires -stateful)
+// @Tags admin
+// @Accept json
+// @Param id path int true "Product ID"
+// @Param product body productUpdateRequest true "Product data"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 409 {object} Response
+// @Router /admin/products/{id} [put]
+func (s *Server) HandleUpdateProduct(w http.ResponseWriter, r *http.Request) {
+	if s.services.ProductStore == nil {
+		writeStoreDisabled(w, fault.ServiceProduct, s.services.remoteProducts)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid product id"})
+		return
+	}
+	var req productUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Price < 0 {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid request body"})
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = money.DefaultCurrency
+	}
+	if _, err := money.LookupCurrency(req.Currency); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: err.Error()})
+		return
+	}
+
+	p := product.Product{ID: id, Name: req.Name, Description: req.Description, Price: money.FromMajor(req.Price, req.Currency)}
+	s.services.ProductStore.PutProduct(p)
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Product updated", Data: p})
+}
+
+// HandleUpdateStock sets the quantity of a product in the in-memory stock store
+// @Summary Update Stock
+// @Description Sets the stock quantity of a product in the in-memory stock store (requires -stateful)
+// @Tags admin
+// @Accept json
+// @Param id path int true "Product ID"
+// @Param stock body stockUpdateRequest true "Stock quantity"
+// @Produce json
+// @Success 200 {object} Response
+// @Failure 400 {object} Response
+// @Failure 409 {object} Response
+// @Router /admin/stock/{id} [put]
+func (s *Server) HandleUpdateStock(w http.ResponseWriter, r *http.Request) {
+	if s.services.StockStore == nil {
+		writeStoreDisabled(w, fault.ServiceStock, s.services.remoteStock)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid product id"})
+		return
+	}
+	var req stockUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, Response{Success: false, Message: "invalid request body"})
+		return
+	}
+
+	if err := s.services.StockStore.SetQuantity(id, req.Quantity); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, stock.ErrInsufficientStock) {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, Response{Success: false, Message: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Message: "Stock updated", Data: map[string]int{"productId": id, "quantity": req.Quantity}})
+}
+
+// writeStoreDisabled answers a request that needs the in-memory store of service ("product" or
+// "stock") when there is none: either -stateful is off, or the service is remote and its data
+// lives in another process, where this server cannot change it.
+func writeStoreDisabled(w http.ResponseWriter, service string, remote bool) {
+	message := "stateful stores are disabled, start the server with -stateful"
+	if remote {
+		message = fmt.Sprintf("the %s service is remote and owns its data, it cannot be changed through this server", service)
+	}
+	writeJSON(w, http.StatusConflict, Response{Success: false, Message: message})
+}
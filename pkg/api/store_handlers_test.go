@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+func TestStoreEndpointsExplainMissingStores(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		sim         SimulationConfig
+		wantProduct int
+		wantStock   int
+		wantMessage string
+	}{
+		{"stateless", SimulationConfig{Seed: 1}, http.StatusConflict, http.StatusConflict, "start the server with -stateful"},
+		// The remote product service is never called, only the local stock store is updated
+		{"remote products", SimulationConfig{Seed: 1, Stateful: true, ProductServiceURL: "http://127.0.0.1:1"}, http.StatusConflict, http.StatusOK, "product service is remote"},
+	} {
+		svc, err := NewServices(tc.sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { svc.Close() })
+		r := chi.NewRouter()
+		NewServer(svc, search.SearchProductsHeapOptimizedByVector, EnrichSequential, ServerOptions{}).AdminRoutes(r)
+
+		for _, req := range []struct {
+			target, body string
+			want         int
+		}{
+			{"/products/1", `{"name": "Kalem", "price": 12.5}`, tc.wantProduct},
+			{"/stock/1", `{"quantity": 3}`, tc.wantStock},
+		} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("PUT", req.target, strings.NewReader(req.body)))
+			if rec.Code != req.want {
+				t.Errorf("%s: PUT %s = %d, want %d: %s", tc.name, req.target, rec.Code, req.want, rec.Body)
+			}
+			if rec.Code == http.StatusConflict && !strings.Contains(rec.Body.String(), tc.wantMessage) {
+				t.Errorf("%s: PUT %s says %s, want it to mention %q", tc.name, req.target, rec.Body, tc.wantMessage)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages of a search request, in the order they run
+const (
+	stageParse  = "parse"
+	stageEmbed  = "embed"
+	stageSearch = "search"
+	stageEnrich = "enrich"
+	stageAds    = "ads" // Runs inside enrich, next to product lookups in the parallel strategies
+	stageEncode = "encode"
+	stageTotal  = "total"
+)
+
+// StageTiming is the time a request spent in one stage.
+type StageTiming struct {
+	Stage      string  `json:"stage"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// stageTimings collects the stage timings of a request. Stages may be recorded concurrently,
+// and a stage recorded twice adds up. A nil *stageTimings records nothing.
+type stageTimings struct {
+	mu     sync.Mutex
+	stages []StageTiming
+}
+
+type stageTimingsKey struct{}
+
+func withStageTimings(ctx context.Context, t *stageTimings) context.Context {
+	return context.WithValue(ctx, stageTimingsKey{}, t)
+}
+
+// stageTimingsFrom returns the timings of the request ctx belongs to, or nil.
+func stageTimingsFrom(ctx context.Context) *stageTimings {
+	t, _ := ctx.Value(stageTimingsKey{}).(*stageTimings)
+	return t
+}
+
+// since records the time from start until now for stage and returns now.
+func (t *stageTimings) since(stage string, start time.Time) time.Time {
+	now := time.Now()
+	t.add(stage, now.Sub(start))
+	return now
+}
+
+func (t *stageTimings) add(stage string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	ms := float64(d.Microseconds()) / 1000
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.stages {
+		if t.stages[i].Stage == stage {
+			t.stages[i].DurationMs += ms
+			return
+		}
+	}
+	t.stages = append(t.stages, StageTiming{Stage: stage, DurationMs: ms})
+}
+
+// list returns a copy of the stages recorded so far.
+func (t *stageTimings) list() []StageTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]StageTiming(nil), t.stages...)
+}
+
+// serverTiming formats the stages as a Server-Timing header value, e.g.
+// "parse;dur=0.02, search;dur=1.3, enrich;dur=48.1".
+func (t *stageTimings) serverTiming() string {
+	var b strings.Builder
+	for i, s := range t.list() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(s.Stage)
+		b.WriteString(";dur=")
+		b.WriteString(strconv.FormatFloat(s.DurationMs, 'f', -1, 64))
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/search"
+)
+
+func TestSearchStageTimings(t *testing.T) {
+	svc, err := NewServices(benchSim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	s := NewServer(svc, search.SearchProductsHeapOptimizedByVector, EnrichSequential, ServerOptions{})
+
+	for _, debug := range []bool{false, true} {
+		target := "/api/search?term=phone&itemCount=3"
+		if debug {
+			target += "&debug=timings"
+		}
+		w := httptest.NewRecorder()
+		s.HandleSearch(w, httptest.NewRequest("GET", target, nil))
+
+		header := w.Header().Get("Server-Timing")
+		for _, stage := range []string{stageParse, stageEmbed, stageSearch, stageEnrich, stageAds, stageEncode, stageTotal} {
+			if !strings.Contains(header, stage+";dur=") {
+				t.Errorf("Server-Timing %q lacks %s", header, stage)
+			}
+		}
+
+		var resp Response
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Duration == "" || resp.StartTime.IsZero() || resp.EndTime.Before(resp.StartTime) {
+			t.Errorf("duration %q, start %v, end %v", resp.Duration, resp.StartTime, resp.EndTime)
+		}
+		if got := len(resp.Timings); debug != (got > 0) {
+			t.Errorf("debug=%v: %d timings in the body", debug, got)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/idagdelen/go-bottlenecks/internal/ads"
 	"github.com/idagdelen/go-bottlenecks/internal/search"
 )
 
@@ -10,8 +11,8 @@ type searchResult struct {
 }
 
 type enrichResult struct {
-	enrichedProducts  []EnrichedProduct
-	recommendedAdResp *EnrichedProduct
+	enrichedProducts []EnrichedProduct
+	sponsored        []ads.SponsoredProduct
 }
 
 type job struct {
@@ -15,16 +15,582 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/faults": {
+            "get": {
+                "description": "Returns the fault rules currently applied to the simulated services",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List Faults",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a fault rule (error, timeout, slow or panic) for a simulated service (product, stock, ads or *), optionally limited to product IDs and a brownout window",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Add Fault",
+                "parameters": [
+                    {
+                        "description": "Fault rule, e.g. {\"service\":\"stock\",\"kind\":\"slow\",\"probability\":0.5,\"delay\":\"200ms\",\"brownout\":{\"on\":\"10s\",\"off\":\"20s\"}}",
+                        "name": "rule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/fault.Rule"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes all fault rules",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Clear Faults",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/faults/{id}": {
+            "delete": {
+                "description": "Removes the fault rule with the given ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete Fault",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Fault rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/products/{id}": {
+            "put": {
+                "description": "Inserts or replaces a product in the in-memory product store (requires -stateful)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Update Product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Product data",
+                        "name": "product",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.productUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/stock/{id}": {
+            "put": {
+                "description": "Sets the stock quantity of a product in the in-memory stock store (requires -stateful)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Update Stock",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Stock quantity",
+                        "name": "stock",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.stockUpdateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ads/click": {
+            "post": {
+                "description": "Records a click on a sponsored product served by the given search request; 404 if that request did not serve it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Record Ad Click",
+                "parameters": [
+                    {
+                        "description": "Search request ID and sponsored product ID",
+                        "name": "event",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.adEventRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ads/impression": {
+            "post": {
+                "description": "Records an impression of a sponsored product served by the given search request; 404 if that request did not serve it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Record Ad Impression",
+                "parameters": [
+                    {
+                        "description": "Search request ID and sponsored product ID",
+                        "name": "event",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.adEventRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/ads/stats": {
+            "get": {
+                "description": "Returns impressions, clicks and CTR per sponsored product",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ads"
+                ],
+                "summary": "Ad Stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
         "/api/health": {
             "get": {
-                "description": "Returns API health status",
+                "description": "Returns API health status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Health Check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/search": {
+            "get": {
+                "description": "Searches the vectorized database with the given keyword and enriches the results with external services",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "search"
+                ],
+                "summary": "Search Demo",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Keyword to search for",
+                        "name": "term",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of products to return (default: 10, see search.defaultItemCount)",
+                        "name": "itemCount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of sponsored products to inject into the results (default: 1, max: 5)",
+                        "name": "adSlots",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency to show prices in: TRY, USD, EUR, GBP or JPY (default: the locale's currency)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale used to format prices, e.g. tr-TR or en-US (default: tr-TR)",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "timings adds the parse, embed, search, enrich and ads stage timings to the body (all stages are always in the Server-Timing header)",
+                        "name": "debug",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        },
+                        "headers": {
+                            "Server-Timing": {
+                                "type": "string",
+                                "description": "Stage timings, e.g. parse;dur=0.02, embed;dur=0.01, search;dur=1.3, enrich;dur=48.1, ads;dur=21.7, encode;dur=0.1, total;dur=49.6"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stock/reservations/{id}/commit": {
+            "post": {
+                "description": "Commits a reservation; the reserved items are sold and stay out of stock (requires -stateful)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "summary": "Commit Reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stock/reservations/{id}/release": {
+            "post": {
+                "description": "Releases a reservation; the reserved items are returned to stock (requires -stateful)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "summary": "Release Reservation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reservation ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stock/reserve": {
+            "post": {
+                "description": "Reserves the given quantities of products from search results. Either all items are reserved or none (requires -stateful)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock"
+                ],
+                "summary": "Reserve Stock",
+                "parameters": [
+                    {
+                        "description": "Products and quantities to reserve",
+                        "name": "reservation",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.reserveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/config": {
+            "get": {
+                "description": "Returns the configuration the server runs with, after merging defaults, the config file, environment variables and flags. Secrets are masked",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "system"
+                    "debug"
                 ],
-                "summary": "Health Check",
+                "summary": "Effective Configuration",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -35,28 +601,135 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/search": {
+        "/debug/profiles": {
             "get": {
-                "description": "Searches the vectorized database with the given keyword and enriches the results with external services",
+                "description": "Lists the snapshots of the continuous profiler (debug.profileInterval), oldest first, with their labels (git revision, configuration hash, ...) and profile files. Download two with /debug/profiles/{snapshot}/{file} and compare them with go tool pprof -diff_base",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "search"
+                    "debug"
                 ],
-                "summary": "Search Demo",
+                "summary": "Profiling Snapshots",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/profiles/{snapshot}/{file}": {
+            "get": {
+                "description": "Returns a profile file of a snapshot listed by /debug/profiles, e.g. go tool pprof http://localhost:6060/debug/profiles/20260101T120000Z/heap.pprof",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Download Profile",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Keyword to search for",
-                        "name": "term",
-                        "in": "query",
+                        "description": "Snapshot name, e.g. 20260101T120000Z",
+                        "name": "snapshot",
+                        "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "Profile file, e.g. heap.pprof",
+                        "name": "file",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/trace": {
+            "get": {
+                "description": "Returns the running runtime trace capture, whether the flight recorder is on and the trace files kept in the trace directory",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Trace Status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/trace/flight": {
+            "post": {
+                "description": "Writes the last seconds of runtime trace kept by the flight recorder (-flight-recorder) to a new timestamped file in the trace directory",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Dump Flight Recorder",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/trace/start": {
+            "post": {
+                "description": "Captures a runtime trace into a new timestamped file in the trace directory for the given number of seconds. Open it with go tool trace",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Start Trace",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "Number of products to return (default: 10)",
-                        "name": "itemCount",
+                        "description": "Length of the capture in seconds (default: 10, max: 300)",
+                        "name": "seconds",
                         "in": "query"
                     }
                 ],
@@ -72,6 +745,58 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/api.Response"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/trace/stop": {
+            "post": {
+                "description": "Stops the running runtime trace capture before its scheduled end",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "debug"
+                ],
+                "summary": "Stop Trace",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/api.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Returns request, search, enrichment and dependency latency histograms, dependency error counts, the worker pool's queue depth, pool hits and Go runtime metrics in the Prometheus text exposition format",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Prometheus Metrics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
@@ -96,6 +821,129 @@ const docTemplate = `{
                 },
                 "success": {
                     "type": "boolean"
+                },
+                "timings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.StageTiming"
+                    }
+                }
+            }
+        },
+        "api.StageTiming": {
+            "type": "object",
+            "properties": {
+                "durationMs": {
+                    "type": "number"
+                },
+                "stage": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.adEventRequest": {
+            "type": "object",
+            "properties": {
+                "productId": {
+                    "type": "integer"
+                },
+                "requestId": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.productUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "description": "ISO 4217 code of the price (default: TRY)",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "description": "Price in major units, e.g. 12.5",
+                    "type": "number"
+                }
+            }
+        },
+        "api.reserveItem": {
+            "type": "object",
+            "properties": {
+                "productId": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.reserveRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.reserveItem"
+                    }
+                }
+            }
+        },
+        "api.stockUpdateRequest": {
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fault.Brownout": {
+            "type": "object",
+            "properties": {
+                "off": {
+                    "type": "integer"
+                },
+                "on": {
+                    "type": "integer"
+                }
+            }
+        },
+        "fault.Rule": {
+            "type": "object",
+            "properties": {
+                "brownout": {
+                    "$ref": "#/definitions/fault.Brownout"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "delay": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "probability": {
+                    "type": "number"
+                },
+                "productIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "service": {
+                    "type": "string"
                 }
             }
         }
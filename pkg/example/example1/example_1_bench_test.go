@@ -3,8 +3,13 @@ package example1
 import (
 	"math/rand"
 	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
 )
 
+// TestMain fails the run if a test or benchmark leaves goroutines behind.
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+
 // generateMatrix generates a 2D slice with given dimensions and random values
 func generateMatrix(rows, cols int) [][]int {
 	matrix := make([][]int, rows)
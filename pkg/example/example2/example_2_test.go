@@ -0,0 +1,32 @@
+package example2
+
+import (
+	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
+)
+
+func TestSumsAgreeWithoutLeaking(t *testing.T) {
+	matrix := generateMatrix(100, 50)
+	want := 0
+	for _, row := range matrix {
+		for _, v := range row {
+			want += v
+		}
+	}
+
+	sums := map[string]func([][]int) int{
+		"parallel":           ParallelRowBasedSum,
+		"worker pool":        func(m [][]int) int { return WorkerPoolRowBasedSum(m, 4) },
+		"worker pool nolock": func(m [][]int) int { return WorkerPoolRowBasedSumNoLock(m, 4) },
+		"chunked":            func(m [][]int) int { return ChunkedRowBasedSum(m, 10) },
+	}
+	for name, sum := range sums {
+		t.Run(name, func(t *testing.T) {
+			leakcheck.Check(t)
+			if got := sum(matrix); got != want {
+				t.Errorf("sum = %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -3,8 +3,13 @@ package example3
 import (
 	"encoding/json"
 	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
 )
 
+// TestMain fails the run if a test or benchmark leaves goroutines behind.
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+
 var exampleStruct = Product{
 	ID:     1,
 	Name:   "Test User",
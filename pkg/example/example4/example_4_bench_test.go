@@ -2,8 +2,13 @@ package example4
 
 import (
 	"testing"
+
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
 )
 
+// TestMain fails the run if a test or benchmark leaves goroutines behind.
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+
 // Benchmark for value slice
 func BenchmarkSumScoresValue(b *testing.B) {
 	for i := 0; i < b.N; i++ {
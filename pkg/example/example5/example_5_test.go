@@ -3,34 +3,74 @@ package example5
 import (
 	"context"
 	"fmt"
-	"runtime"
 	"testing"
 	"time"
+
+	"github.com/idagdelen/go-bottlenecks/internal/leakcheck"
 )
 
+// TestMain fails the run if a test, benchmark or example leaves goroutines behind.
+func TestMain(m *testing.M) { leakcheck.VerifyTestMain(m) }
+
+const doWorkFunc = "github.com/idagdelen/go-bottlenecks/pkg/example/example5.DoWork.func1"
+
+// leaked runs doWork with a timeout shorter than the work and reports the goroutines left behind.
+func leaked(ch chan string) []leakcheck.Goroutine {
+	s := leakcheck.Take()
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
+	defer cancel()
+	DoWork(ch)
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+	return s.Leaked(leakcheck.Timeout(200 * time.Millisecond))
+}
+
+func TestDoWorkUnbufferedLeaks(t *testing.T) {
+	ch := make(chan string)
+	gs := leaked(ch)
+	if len(gs) != 1 || gs[0].Function != doWorkFunc || gs[0].State != "chan send" {
+		t.Fatalf("leaked = %v, want DoWork's sender blocked on the channel", gs)
+	}
+	<-ch // Lets the sender finish, so that TestMain does not report it
+}
+
+func TestDoWorkBufferedDoesNotLeak(t *testing.T) {
+	leakcheck.Check(t)
+	ch := make(chan string, 1)
+	if gs := leaked(ch); len(gs) != 0 {
+		t.Fatal(leakcheck.Report(gs))
+	}
+}
+
+// BenchmarkDoWorkUnbuffered reports the senders left blocked on their channels, one per
+// cancelled iteration, then lets them finish so that TestMain does not fail the run.
 func BenchmarkDoWorkUnbuffered(b *testing.B) {
-	before := runtime.NumGoroutine()
+	s := leakcheck.Take()
+	var chans []chan string
 
 	for i := 0; i < b.N; i++ {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
-		ch := make(chan string, 1)
+		ch := make(chan string)
 		DoWork(ch)
 		select {
 		case <-ch:
 			// work done
 		case <-ctx.Done():
 			// work cancelled
+			chans = append(chans, ch)
 		}
 		cancel()
 	}
-	time.Sleep(100 * time.Millisecond)
-	after := runtime.NumGoroutine()
-	fmt.Println("\n\nGoroutine sayısı (before):", before)
-	fmt.Println("Goroutine sayısı (after):", after)
+	b.ReportMetric(float64(len(s.Leaked(leakcheck.Timeout(100*time.Millisecond)))), "leaked-goroutines")
+	for _, ch := range chans {
+		<-ch
+	}
 }
 
 func BenchmarkDoWorkBuffered(b *testing.B) {
-	before := runtime.NumGoroutine()
+	s := leakcheck.Take()
 
 	for i := 0; i < b.N; i++ {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
@@ -44,8 +84,23 @@ func BenchmarkDoWorkBuffered(b *testing.B) {
 		}
 		cancel()
 	}
-	time.Sleep(100 * time.Millisecond)
-	after := runtime.NumGoroutine()
-	fmt.Println("\n\nGoroutine sayısı (before):", before)
-	fmt.Println("Goroutine sayısı (after):", after)
+	b.ReportMetric(float64(len(s.Leaked(leakcheck.Timeout(100*time.Millisecond)))), "leaked-goroutines")
+}
+
+// Wait for work to finish, but cancel if it takes longer than 10ms. The channel has room for the
+// result, so DoWork's sender can still deliver it after nobody waits any more.
+func ExampleDoWork() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ch := make(chan string, 1)
+
+	DoWork(ch)
+
+	select {
+	case result := <-ch:
+		fmt.Println("[Main] result:", result)
+	case <-ctx.Done():
+		fmt.Println("[Main] result: work cancelled")
+	}
+	// Output: [Main] result: work cancelled
 }